@@ -0,0 +1,125 @@
+// Package pooltest provides account data builders and checks shared by the tests of pool implementations.
+package pooltest
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"testing"
+
+	"cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// Update is the data of one of a pool's dependent accounts
+type Update struct {
+	Account solana.PublicKey
+	Data    []byte
+}
+
+// TokenAccountData returns the data of an SPL token account holding amount
+func TokenAccountData(amount uint64) []byte {
+	data := make([]byte, 165)
+	binary.LittleEndian.PutUint64(data[64:], amount)
+	return data
+}
+
+// MintData returns the data of an SPL mint without extensions
+func MintData(decimals uint8) []byte {
+	data := make([]byte, sol.MintAccountDataSize)
+	data[44] = decimals
+	data[45] = 1 // is_initialized
+	return data
+}
+
+// ClockData returns the data of the clock sysvar at slot and epoch
+func ClockData(slot, epoch uint64) []byte {
+	data := make([]byte, sol.ClockAccountDataSize)
+	binary.LittleEndian.PutUint64(data[0:], slot)
+	binary.LittleEndian.PutUint64(data[16:], epoch)
+	return data
+}
+
+// Fixture is a pool under test and the data of its dependent accounts in every round
+type Fixture struct {
+	Name   string
+	Pool   pkg.Pool
+	Amount math.Int
+	Rounds int
+	// State returns the updates of round i. Round 0 is applied before quoting starts and must
+	// make the pool quotable; the pool must stay quotable after every later round.
+	State func(i int) []Update
+}
+
+// QuoteDuringUpdates runs every fixture as a subtest that quotes its pool in both directions
+// from several goroutines while a single writer, as a PoolFeed does, applies the rounds of
+// updates. Run it with -race.
+func QuoteDuringUpdates(t *testing.T, fixtures ...Fixture) {
+	t.Helper()
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			for _, update := range fixture.State(0) {
+				if err := fixture.Pool.ApplyUpdate(update.Account, update.Data, 1); err != nil {
+					t.Fatalf("ApplyUpdate(%s): %v", update.Account, err)
+				}
+			}
+			quoteDuringUpdates(t, fixture)
+		})
+	}
+}
+
+func quoteDuringUpdates(t *testing.T, fixture Fixture) {
+	ctx := context.Background()
+	pool := fixture.Pool
+	baseMint, quoteMint := pool.GetTokens()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for _, inputMint := range []string{baseMint, quoteMint} {
+					result, err := pool.Quote(ctx, inputMint, fixture.Amount)
+					if err != nil {
+						t.Errorf("Quote(%s): %v", inputMint, err)
+						return
+					}
+					if !result.AmountOut.IsPositive() {
+						t.Errorf("Quote(%s) = %v, want a positive output", inputMint, result.AmountOut)
+						return
+					}
+					snapshot := pool.Snapshot()
+					if snapshot == nil {
+						t.Errorf("Snapshot returned nil after an update")
+						return
+					}
+					if _, err := snapshot.QuoteExactOut(ctx, inputMint, result.AmountOut); err != nil {
+						t.Errorf("QuoteExactOut(%s, %v): %v", inputMint, result.AmountOut, err)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	var slot uint64 = 1
+	for i := 1; i <= fixture.Rounds; i++ {
+		for _, update := range fixture.State(i) {
+			slot++
+			if err := pool.ApplyUpdate(update.Account, update.Data, slot); err != nil {
+				t.Errorf("ApplyUpdate(%s) in round %d: %v", update.Account, i, err)
+			}
+		}
+	}
+	close(done)
+	wg.Wait()
+}
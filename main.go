@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 
 	"cosmossdk.io/math"
 	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
 	"github.com/solana-zh/solroute/pkg/protocol"
 	"github.com/solana-zh/solroute/pkg/router"
 	"github.com/solana-zh/solroute/pkg/sol"
@@ -58,7 +60,7 @@ func main() {
 	}
 	log.Printf("😈Your token account: %v", outTokenAccount.String())
 
-	router := router.NewSimpleRouter(
+	simpleRouter := router.NewSimpleRouter(
 		protocol.NewPumpAmm(solClient),
 		protocol.NewRaydiumAmm(solClient),
 		protocol.NewRaydiumClmm(solClient),
@@ -68,25 +70,33 @@ func main() {
 
 	// Query available pools
 	log.Printf("⌛️Querying available pools...")
-	err = router.QueryAllPools(ctx, inTokenAddr.String(), outTokenAddr.String())
-	if err != nil {
+	err = simpleRouter.QueryAllPools(ctx, inTokenAddr.String(), outTokenAddr.String())
+	if errors.Is(err, router.ErrTooFewProtocols) {
 		log.Fatalf("Failed to query all pools: %v", err)
+	} else if err != nil {
+		log.Printf("Some protocols failed: %v", err)
 	}
-	log.Printf("👌Found %d pools", len(router.Pools))
+	log.Printf("👌Found %d pools", len(simpleRouter.Pools))
 
 	signers := []solana.PrivateKey{}
 	instructions := make([]solana.Instruction, 0)
 
 	amountIn := math.NewInt(defaultAmountIn)
-	bestPool, amountOut, err := router.GetBestPool(ctx, solClient, inTokenAddr.String(), amountIn)
+	bestPool, quote, err := simpleRouter.GetBestPool(ctx, solClient, inTokenAddr.String(), amountIn)
 	if err != nil {
 		log.Fatalf("Failed to get best pool: %v", err)
 	}
-	log.Printf("Selected best pool: %v, amountOut: %v", bestPool.GetID(), amountOut)
+	log.Printf("Selected best pool: %v, amountOut: %v, fee: %v, price impact: %d bps",
+		bestPool.GetID(), quote.AmountOut, quote.FeeAmount, quote.PriceImpactBps)
 
-	minAmountOut := amountOut.Mul(math.NewInt(int64(10000 - slippageBps))).Quo(math.NewInt(10000))
+	minAmountOut := quote.AmountOut.Mul(math.NewInt(int64(10000 - slippageBps))).Quo(math.NewInt(10000))
+	// Pools hold their mints in on-chain order, which may be either way round
+	userBaseAccount, userQuoteAccount := inTokenAccount, outTokenAccount
+	if baseMint, _ := bestPool.GetTokens(); baseMint != inTokenAddr.String() {
+		userBaseAccount, userQuoteAccount = outTokenAccount, inTokenAccount
+	}
 	instructionsBuy, err := bestPool.BuildSwapInstructions(ctx, solClient,
-		privateKey.PublicKey(), inTokenAddr.String(), amountIn, minAmountOut, inTokenAccount, outTokenAccount)
+		privateKey.PublicKey(), inTokenAddr.String(), amountIn, minAmountOut, pkg.SwapModeExactIn, userBaseAccount, userQuoteAccount)
 	if err != nil {
 		log.Fatalf("Failed to build swap instructions: %v", err)
 	}
@@ -104,15 +114,29 @@ func main() {
 		}
 	}
 	if useJito {
-		_, err = solClient.SendTxWithJito(ctx, 1000000, signers, tx)
+		bundleId, err := solClient.SendTxWithJito(ctx, 1000000, signers, tx)
 		if err != nil {
 			log.Fatalf("Failed to SendTxWithJito: %v", err)
 		}
+		results, err := solClient.WatchBundle(ctx, bundleId)
+		if err != nil {
+			log.Fatalf("Failed to WatchBundle: %v", err)
+		}
+		for result := range results {
+			log.Printf("Bundle %s: %s %s at slot %d", result.BundleID, result.Status, result.ConfirmationStatus, result.Slot)
+		}
 	} else {
-		sig, err := solClient.SendTx(ctx, tx)
+		outcome, err := solClient.SendAndConfirmTx(ctx, tx)
 		if err != nil {
-			log.Fatalf("Failed to SendTx: %v", err)
+			log.Fatalf("Failed to SendAndConfirmTx: %v", err)
+		}
+		switch outcome.Status {
+		case sol.TxConfirmed:
+			log.Printf("Transaction confirmed: https://solscan.io/tx/%v", outcome.Signature)
+		case sol.TxFailed:
+			log.Fatalf("Transaction failed: %v https://solscan.io/tx/%v", outcome.Err, outcome.Signature)
+		default:
+			log.Fatalf("Transaction %v expired after %d sends", outcome.Signature, outcome.Sends)
 		}
-		log.Printf("Transaction successful: https://solscan.io/tx/%v", sig)
 	}
 }
@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"context"
+	"fmt"
 
 	"cosmossdk.io/math"
 	"github.com/gagliardetto/solana-go"
@@ -19,26 +20,77 @@ const (
 	ProtocolNamePumpAmm     ProtocolName = "pump_amm"
 )
 
+// SwapMode selects which side of a swap is fixed
+type SwapMode int
+
+const (
+	// SwapModeExactIn spends exactly the input amount and requires at least the output amount
+	SwapModeExactIn SwapMode = iota
+	// SwapModeExactOut receives exactly the output amount and spends at most the input amount
+	SwapModeExactOut
+)
+
+func (m SwapMode) String() string {
+	switch m {
+	case SwapModeExactIn:
+		return "ExactIn"
+	case SwapModeExactOut:
+		return "ExactOut"
+	default:
+		return fmt.Sprintf("SwapMode(%d)", int(m))
+	}
+}
+
 type Pool interface {
 	ProtocolName() ProtocolName
 	GetProgramID() solana.PublicKey
 	GetID() string
 	GetTokens() (baseMint, quoteMint string)
-	Quote(ctx context.Context, solClient *sol.Client, inputMint string, inputAmount math.Int) (math.Int, error)
+	// DependentAccounts lists the accounts the pool's quotes are computed from.
+	// The list can change after ApplyUpdate, e.g. when the price moves to other tick arrays.
+	DependentAccounts() []solana.PublicKey
+	// ApplyUpdate updates the pool state from the data of one of its dependent accounts at slot
+	// and publishes a new snapshot. Calls to ApplyUpdate must not run concurrently with each other;
+	// Snapshot, Quote and QuoteExactOut may run at any time.
+	ApplyUpdate(account solana.PublicKey, data []byte, slot uint64) error
+	// Snapshot returns the pool state published by the latest ApplyUpdate, or nil before the first one
+	Snapshot() PoolSnapshot
+	// Quote returns the result of swapping exactly inputAmount of inputMint for the other token.
+	// It is computed from the latest snapshot and makes no network calls.
+	Quote(ctx context.Context, inputMint string, inputAmount math.Int) (*QuoteResult, error)
+	// QuoteExactOut returns the amount of inputMint needed to receive exactly outputAmount of the other token
+	QuoteExactOut(ctx context.Context, inputMint string, outputAmount math.Int) (*QuoteResult, error)
+	// BuildSwapInstructions builds the swap instructions for the given mode.
+	// In SwapModeExactIn outputAmount is the minimum accepted output;
+	// in SwapModeExactOut inputAmount is the maximum accepted input.
 	BuildSwapInstructions(
 		ctx context.Context,
 		solClient *sol.Client,
 		user solana.PublicKey,
 		inputMint string,
 		inputAmount math.Int,
-		minOut math.Int,
+		outputAmount math.Int,
+		mode SwapMode,
 		userBaseAccount solana.PublicKey,
 		userQuoteAccount solana.PublicKey,
 	) ([]solana.Instruction, error)
 }
 
+// PoolSnapshot is an immutable copy of a pool's state. Quoting never modifies a snapshot, so one
+// snapshot can be quoted from any number of goroutines while the pool keeps receiving updates.
+type PoolSnapshot interface {
+	// Slot returns the highest slot of the account data the snapshot was built from
+	Slot() uint64
+	Quote(ctx context.Context, inputMint string, inputAmount math.Int) (*QuoteResult, error)
+	QuoteExactOut(ctx context.Context, inputMint string, outputAmount math.Int) (*QuoteResult, error)
+}
+
 type Protocol interface {
 	ProtocolName() ProtocolName
 	FetchPoolsByPair(ctx context.Context, baseMint, quoteMint string) ([]Pool, error)
 	FetchPoolByID(ctx context.Context, poolID string) (Pool, error)
+	// FetchAllPools enumerates and decodes every pool of the protocol
+	FetchAllPools(ctx context.Context) ([]Pool, error)
+	// DecodePool builds a fully initialized pool from pool account data that was already fetched
+	DecodePool(ctx context.Context, poolID solana.PublicKey, data []byte) (Pool, error)
 }
@@ -18,6 +18,9 @@ const (
 	ExtensionBinArrayBitmapSize  = 12
 )
 
+// PoolDataSize is the size of a DLMM pool account
+const PoolDataSize = 904
+
 // Tick and bin ID range constants
 const (
 	MaxTick  = 443636
@@ -55,6 +58,9 @@ var (
 
 	// Swap2IxDiscm is the instruction discriminator for swap2 instruction
 	Swap2IxDiscm = [8]byte{65, 75, 63, 76, 235, 91, 91, 136}
+
+	// SwapExactOut2IxDiscm is the instruction discriminator for swap_exact_out2 instruction
+	SwapExactOut2IxDiscm = [8]byte{43, 215, 247, 132, 137, 60, 243, 81}
 )
 
 // PairStatus represents the status of a trading pair
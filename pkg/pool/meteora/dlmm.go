@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/gagliardetto/solana-go"
@@ -88,6 +89,12 @@ type MeteoraDlmmPool struct {
 	bitmapExtension    *BinArrayBitmapExtension
 	Clock              sol.Clock
 	orgActiveId        int32
+	// transferFees holds the Token-2022 transfer fees of the pool's mints
+	transferFees pkg.TransferFees
+	// slot is the highest slot of the account data applied with ApplyUpdate
+	slot uint64
+	// snapshot holds the *MeteoraDlmmPool published by the latest ApplyUpdate
+	snapshot atomic.Value
 }
 
 func (pool *MeteoraDlmmPool) ProtocolName() pkg.ProtocolName {
@@ -332,6 +339,7 @@ func (pool *MeteoraDlmmPool) Decode(data []byte) error {
 	// Skip final padding
 	offset += 16
 
+	pool.transferFees.Track(pool.TokenXMint, pool.TokenYMint)
 	return nil
 }
 
@@ -384,10 +392,102 @@ func (pool *MeteoraDlmmPool) UpdateClock(ctx context.Context, client *sol.Client
 	return nil
 }
 
+// DependentAccounts returns the pool account, the clock sysvar used for fee decay, activation
+// checks and transfer fee schedules, the accounts of the mints' transfer fees and the bin arrays
+// around the active bin in both directions
+func (pool *MeteoraDlmmPool) DependentAccounts() []solana.PublicKey {
+	accounts := []solana.PublicKey{pool.PoolId, solana.SysVarClockPubkey}
+	for _, account := range pool.transferFees.DependentAccounts() {
+		if account != solana.SysVarClockPubkey {
+			accounts = append(accounts, account)
+		}
+	}
+	for _, swapForY := range []bool{true, false} {
+		binArrayPubkeys, err := pool.GetBinArrayPubkeysForSwap(swapForY, 4)
+		if err != nil {
+			continue
+		}
+		accounts = append(accounts, binArrayPubkeys...)
+	}
+	return accounts
+}
+
+// ApplyUpdate applies the data of the pool account, the clock sysvar, one of the pool's mints
+// or one of its bin arrays
+func (pool *MeteoraDlmmPool) ApplyUpdate(account solana.PublicKey, data []byte, slot uint64) error {
+	switch account {
+	case pool.PoolId:
+		if len(data) < PoolDataSize {
+			return fmt.Errorf("pool data too short: %d bytes", len(data))
+		}
+		if err := pool.Decode(data); err != nil {
+			return fmt.Errorf("failed to decode pool: %w", err)
+		}
+	case solana.SysVarClockPubkey:
+		clock, err := sol.ParseClock(data)
+		if err != nil {
+			return err
+		}
+		pool.Clock = *clock
+		pool.transferFees.SetEpoch(clock.Epoch)
+	case pool.TokenXMint, pool.TokenYMint:
+		if err := pool.transferFees.ApplyMint(account, data); err != nil {
+			return err
+		}
+	default:
+		binArray, err := ParseBinArray(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse bin array %s: %w", account, err)
+		}
+		if binArray.LbPair != pool.PoolId {
+			return fmt.Errorf("account %s is not a dependent account of pool %s", account, pool.PoolId)
+		}
+		// Copy the bin arrays so published snapshots keep their own
+		binArrays := make(map[string]BinArray, len(pool.BinArrays)+1)
+		for key, cached := range pool.BinArrays {
+			binArrays[key] = cached
+		}
+		binArrays[account.String()] = binArray
+		pool.BinArrays = binArrays
+	}
+	pool.slot = max(pool.slot, slot)
+	pool.publish()
+	return nil
+}
+
+// publish stores a copy of the current state as the pool's snapshot
+func (pool *MeteoraDlmmPool) publish() {
+	s := *pool
+	s.snapshot = atomic.Value{}
+	s.snapshot.Store(&s)
+	pool.snapshot.Store(&s)
+}
+
+// latest returns the published snapshot, or nil before the first ApplyUpdate
+func (pool *MeteoraDlmmPool) latest() *MeteoraDlmmPool {
+	s, _ := pool.snapshot.Load().(*MeteoraDlmmPool)
+	return s
+}
+
+// Snapshot returns the state published by the latest ApplyUpdate
+func (pool *MeteoraDlmmPool) Snapshot() pkg.PoolSnapshot {
+	if s := pool.latest(); s != nil {
+		return s
+	}
+	return nil
+}
+
+// Slot returns the highest slot of the account data applied to the pool
+func (pool *MeteoraDlmmPool) Slot() uint64 {
+	return pool.slot
+}
+
 // GetBinArrayForSwap retrieves bin arrays needed for swap operations
 func (pool *MeteoraDlmmPool) GetBinArrayForSwap(ctx context.Context, client *sol.Client) error {
-	if pool.BinArrays == nil {
-		pool.BinArrays = make(map[string]BinArray) // Initialize bin array map
+	// Fill a copy of the bin array map so published snapshots keep their own
+	binArrays := make(map[string]BinArray, len(pool.BinArrays))
+	for key, cached := range pool.BinArrays {
+		binArrays[key] = cached
 	}
 
 	// Get active bin array public keys for both positive and negative orders
@@ -422,7 +522,8 @@ func (pool *MeteoraDlmmPool) GetBinArrayForSwap(ctx context.Context, client *sol
 		if err != nil {
 			return fmt.Errorf("failed to parse bin array for account %s: %w", accountKey, err)
 		}
-		pool.BinArrays[accountKey] = binArray
+		binArrays[accountKey] = binArray
 	}
+	pool.BinArrays = binArrays
 	return nil
 }
@@ -0,0 +1,63 @@
+package meteora
+
+import (
+	"encoding/binary"
+	"testing"
+
+	cosmosmath "cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/internal/pooltest"
+)
+
+// dlmmPoolData returns the account data of a DLMM pair of pool's mints with bin step 10 at bin 0,
+// with the bin arrays -1 and 0 initialized
+func dlmmPoolData(pool *MeteoraDlmmPool, baseFactor uint16) []byte {
+	data := make([]byte, PoolDataSize)
+	binary.LittleEndian.PutUint16(data[8:], baseFactor)
+	binary.LittleEndian.PutUint16(data[80:], 10)
+	copy(data[pool.Offset("TokenXMint"):], pool.TokenXMint[:])
+	copy(data[pool.Offset("TokenYMint"):], pool.TokenYMint[:])
+	// The bit of a bin array is its index offset by 512
+	binary.LittleEndian.PutUint64(data[584+7*8:], 1<<63)
+	binary.LittleEndian.PutUint64(data[584+8*8:], 1)
+	return data
+}
+
+// binArrayData returns the account data of pool's bin array at index with amount of both tokens in every bin
+func binArrayData(pool *MeteoraDlmmPool, index int64, amount uint64) []byte {
+	data := make([]byte, 56+MaxBinPerArray*144)
+	binary.LittleEndian.PutUint64(data[8:], uint64(index))
+	copy(data[24:], pool.PoolId[:])
+	for i := 0; i < MaxBinPerArray; i++ {
+		bin := data[56+i*144:]
+		binary.LittleEndian.PutUint64(bin, amount)
+		binary.LittleEndian.PutUint64(bin[8:], amount)
+	}
+	return data
+}
+
+func TestMeteoraDlmmPoolQuoteDuringUpdates(t *testing.T) {
+	pool := &MeteoraDlmmPool{
+		PoolId:     solana.NewWallet().PublicKey(),
+		TokenXMint: solana.NewWallet().PublicKey(),
+		TokenYMint: solana.NewWallet().PublicKey(),
+	}
+	lowerBinArray, _ := DeriveBinArrayPDA(pool.PoolId, -1)
+	upperBinArray, _ := DeriveBinArrayPDA(pool.PoolId, 0)
+	pooltest.QuoteDuringUpdates(t, pooltest.Fixture{
+		Name:   "meteora dlmm",
+		Pool:   pool,
+		Amount: cosmosmath.NewInt(1_000_000),
+		Rounds: 200,
+		State: func(i int) []pooltest.Update {
+			return []pooltest.Update{
+				{Account: pool.PoolId, Data: dlmmPoolData(pool, uint16(10000+i))},
+				{Account: solana.SysVarClockPubkey, Data: pooltest.ClockData(uint64(100+i), 500)},
+				{Account: pool.TokenXMint, Data: pooltest.MintData(6)},
+				{Account: pool.TokenYMint, Data: pooltest.MintData(9)},
+				{Account: lowerBinArray, Data: binArrayData(pool, -1, uint64(1_000_000_000+i))},
+				{Account: upperBinArray, Data: binArrayData(pool, 0, uint64(1_000_000_000-i))},
+			}
+		},
+	})
+}
@@ -10,74 +10,283 @@ import (
 
 	cosmosmath "cosmossdk.io/math"
 	"github.com/gagliardetto/solana-go"
-	"github.com/solana-zh/solroute/pkg/sol"
+	"github.com/solana-zh/solroute/pkg"
 	"lukechampine.com/uint128"
 )
 
 // Quote calculates the output amount for a given input amount and token
-func (pool *MeteoraDlmmPool) Quote(ctx context.Context, solClient *sol.Client, inputMint string, inputAmount cosmosmath.Int) (cosmosmath.Int, error) {
+func (pool *MeteoraDlmmPool) Quote(ctx context.Context, inputMint string, inputAmount cosmosmath.Int) (*pkg.QuoteResult, error) {
+	s := pool.latest()
+	if s == nil || len(s.BinArrays) == 0 || s.Clock.Slot == 0 || !s.transferFees.Loaded() {
+		return nil, pkg.ErrPoolStateNotLoaded
+	}
+	// Simulate on a copy: the swap moves the active bin and the volatility parameters
+	work := *s
+	return work.transferFees.QuoteExactIn(inputMint, inputAmount, work.quote)
+}
+
+func (pool *MeteoraDlmmPool) quote(inputMint string, inputAmount cosmosmath.Int) (*pkg.QuoteResult, error) {
 	pool.orgActiveId = pool.activeId
 	totalAmountOut := cosmosmath.ZeroInt()
+	totalFee := cosmosmath.ZeroInt()
 
 	if err := pool.validateSwapActivation(); err != nil {
-		return cosmosmath.ZeroInt(), fmt.Errorf("swap activation validation failed: %w", err)
+		return nil, fmt.Errorf("swap activation validation failed: %w", err)
 	}
 	pool.UpdateReferences()
 
 	amountLeft := inputAmount
 	swapForY := inputMint == pool.TokenXMint.String()
+	lastBinId := pool.activeId
+	binArrays := make([]solana.PublicKey, 0)
 
 	// Process active bin arrays
 	for amountLeft.IsPositive() {
 		// Get the current active bin array
 		activeBinArray, err := pool.getCurrentActiveBinArray(swapForY)
 		if err != nil {
-			return cosmosmath.ZeroInt(), err
+			return nil, err
+		}
+		binArrays = appendBinArray(binArrays, pool.PoolId, activeBinArray)
+		if err := pool.enterBinArray(&activeBinArray, swapForY); err != nil {
+			return nil, err
 		}
 
 		// Process active bins
-		for {
+		for amountLeft.IsPositive() {
 			withinRange, err := activeBinArray.IsBinIDWithinRange(pool.activeId)
 			if err != nil {
-				return cosmosmath.ZeroInt(), fmt.Errorf("failed to check bin ID range: %w", err)
+				return nil, fmt.Errorf("failed to check bin ID range: %w", err)
+			}
+			if !withinRange {
+				break
+			}
+
+			// Update volatility accumulator
+			if err := pool.UpdateVolatilityAccumulator(); err != nil {
+				return nil, fmt.Errorf("failed to update volatility accumulator: %w", err)
+			}
+
+			activeBin, err := activeBinArray.GetBinMut(pool.activeId)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get active bin: %w", err)
 			}
-			if !withinRange || inputAmount.IsZero() {
+
+			if !activeBin.IsEmpty(!swapForY) {
+				swapResult, err := pool.Swap(
+					activeBin,
+					amountLeft.Uint64(),
+					swapForY,
+				)
+				if err != nil {
+					return nil, fmt.Errorf("swap failed: %w", err)
+				}
+				amountLeft = amountLeft.Sub(cosmosmath.NewIntFromUint64(swapResult.amountInWithFees))
+				totalAmountOut = totalAmountOut.Add(cosmosmath.NewIntFromUint64(swapResult.amountOut))
+				totalFee = totalFee.Add(cosmosmath.NewIntFromUint64(swapResult.fee))
+				lastBinId = pool.activeId
+			}
+			if amountLeft.IsPositive() {
 				if err := pool.AdvanceActiveBin(swapForY); err != nil {
-					return cosmosmath.ZeroInt(), fmt.Errorf("failed to advance active bin: %w", err)
+					return nil, fmt.Errorf("failed to advance active bin: %w", err)
 				}
+			}
+		}
+	}
+
+	return pool.quoteResult(inputMint, inputAmount, totalAmountOut, totalFee, lastBinId, binArrays)
+}
+
+// QuoteExactOut calculates the input amount, including fees, needed to receive exactly outputAmount
+func (pool *MeteoraDlmmPool) QuoteExactOut(ctx context.Context, inputMint string, outputAmount cosmosmath.Int) (*pkg.QuoteResult, error) {
+	s := pool.latest()
+	if s == nil || len(s.BinArrays) == 0 || s.Clock.Slot == 0 || !s.transferFees.Loaded() {
+		return nil, pkg.ErrPoolStateNotLoaded
+	}
+	work := *s
+	return work.transferFees.QuoteExactOut(inputMint, outputAmount, work.quoteExactOut)
+}
+
+func (pool *MeteoraDlmmPool) quoteExactOut(inputMint string, outputAmount cosmosmath.Int) (*pkg.QuoteResult, error) {
+	pool.orgActiveId = pool.activeId
+
+	if err := pool.validateSwapActivation(); err != nil {
+		return nil, fmt.Errorf("swap activation validation failed: %w", err)
+	}
+	pool.UpdateReferences()
+
+	if !outputAmount.IsUint64() {
+		return nil, fmt.Errorf("output amount %v exceeds uint64 range", outputAmount)
+	}
+	amountOutLeft := outputAmount.Uint64()
+	swapForY := inputMint == pool.TokenXMint.String()
+	totalAmountIn := new(big.Int)
+	totalFee := new(big.Int)
+	lastBinId := pool.activeId
+	binArrays := make([]solana.PublicKey, 0)
+
+	for amountOutLeft > 0 {
+		activeBinArray, err := pool.getCurrentActiveBinArray(swapForY)
+		if err != nil {
+			return nil, err
+		}
+		binArrays = appendBinArray(binArrays, pool.PoolId, activeBinArray)
+		if err := pool.enterBinArray(&activeBinArray, swapForY); err != nil {
+			return nil, err
+		}
+
+		for amountOutLeft > 0 {
+			withinRange, err := activeBinArray.IsBinIDWithinRange(pool.activeId)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check bin ID range: %w", err)
+			}
+			if !withinRange {
 				break
-			} else {
-				// Update volatility accumulator
-				if err := pool.UpdateVolatilityAccumulator(); err != nil {
-					return cosmosmath.ZeroInt(), fmt.Errorf("failed to update volatility accumulator: %w", err)
-				}
+			}
+
+			if err := pool.UpdateVolatilityAccumulator(); err != nil {
+				return nil, fmt.Errorf("failed to update volatility accumulator: %w", err)
+			}
+
+			activeBin, err := activeBinArray.GetBinMut(pool.activeId)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get active bin: %w", err)
+			}
+			price, err := activeBin.GetOrStoreBinPrice(pool.activeId, pool.binStep)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get bin price: %w", err)
+			}
 
-				activeBin, err := activeBinArray.GetBinMut(pool.activeId)
+			if !activeBin.IsEmpty(!swapForY) {
+				// Take the whole bin, or only what is still missing
+				binMaxAmountOut := activeBin.GetMaxAmountOut(swapForY)
+				var amountIn *big.Int
+				if amountOutLeft >= binMaxAmountOut {
+					amountIn, err = activeBin.GetMaxAmountIn(price, swapForY)
+					amountOutLeft -= binMaxAmountOut
+				} else {
+					amountIn, err = activeBin.GetAmountIn(amountOutLeft, price, swapForY)
+					amountOutLeft = 0
+				}
 				if err != nil {
-					return cosmosmath.ZeroInt(), fmt.Errorf("failed to get active bin: %w", err)
+					return nil, fmt.Errorf("failed to get amount in: %w", err)
 				}
-
-				if !activeBin.IsEmpty(!swapForY) {
-					swapResult, err := pool.Swap(
-						activeBin,
-						amountLeft.Uint64(),
-						swapForY,
-					)
-					if err != nil {
-						return cosmosmath.ZeroInt(), fmt.Errorf("swap failed: %w", err)
-					}
-					amountLeft = amountLeft.Sub(cosmosmath.NewInt(int64(swapResult.amountInWithFees)))
-					totalAmountOut = totalAmountOut.Add(cosmosmath.NewInt(int64(swapResult.amountOut)))
+				if !amountIn.IsUint64() {
+					return nil, fmt.Errorf("amount in exceeds uint64 range")
+				}
+				fee, err := pool.ComputeFee(amountIn.Uint64())
+				if err != nil {
+					return nil, fmt.Errorf("failed to compute fee: %w", err)
 				}
+				totalAmountIn.Add(totalAmountIn, amountIn)
+				totalAmountIn.Add(totalAmountIn, new(big.Int).SetUint64(fee))
+				totalFee.Add(totalFee, new(big.Int).SetUint64(fee))
+				lastBinId = pool.activeId
+			}
+
+			if amountOutLeft > 0 {
 				if err := pool.AdvanceActiveBin(swapForY); err != nil {
-					return cosmosmath.ZeroInt(), fmt.Errorf("failed to advance active bin: %w", err)
+					return nil, fmt.Errorf("failed to advance active bin: %w", err)
 				}
 			}
 		}
 	}
 
-	pool.activeId = pool.orgActiveId
-	return totalAmountOut, nil
+	return pool.quoteResult(inputMint, cosmosmath.NewIntFromBigInt(totalAmountIn), outputAmount,
+		cosmosmath.NewIntFromBigInt(totalFee), lastBinId, binArrays)
+}
+
+// enterBinArray moves the active bin to the first bin the swap reaches in binArray when the
+// bin arrays in between hold no liquidity
+func (pool *MeteoraDlmmPool) enterBinArray(binArray *BinArray, swapForY bool) error {
+	withinRange, err := binArray.IsBinIDWithinRange(pool.activeId)
+	if err != nil {
+		return fmt.Errorf("failed to check bin ID range: %w", err)
+	}
+	if withinRange {
+		return nil
+	}
+	lowerBinID, upperBinID, err := GetBinArrayLowerUpperBinID(int32(binArray.index))
+	if err != nil {
+		return fmt.Errorf("failed to get bin array bounds: %w", err)
+	}
+	// The next bin array with liquidity must lie in the swap direction
+	if swapForY && upperBinID < pool.activeId {
+		pool.activeId = upperBinID
+		return nil
+	}
+	if !swapForY && lowerBinID > pool.activeId {
+		pool.activeId = lowerBinID
+		return nil
+	}
+	return fmt.Errorf("insufficient liquidity: no bin array with liquidity past bin %d", pool.activeId)
+}
+
+// quoteResult builds the quote result of a swap that started at the original active bin
+// and ended in lastBinId
+func (pool *MeteoraDlmmPool) quoteResult(
+	inputMint string,
+	amountIn, amountOut, fee cosmosmath.Int,
+	lastBinId int32,
+	binArrays []solana.PublicKey,
+) (*pkg.QuoteResult, error) {
+	swapForY := inputMint == pool.TokenXMint.String()
+	outputMint := pool.TokenXMint.String()
+	if swapForY {
+		outputMint = pool.TokenYMint.String()
+	}
+
+	priceBefore, err := binPrice(pool.orgActiveId, pool.binStep, swapForY)
+	if err != nil {
+		return nil, err
+	}
+	priceAfter, err := binPrice(lastBinId, pool.binStep, swapForY)
+	if err != nil {
+		return nil, err
+	}
+
+	binsCrossed := int(lastBinId - pool.orgActiveId)
+	if binsCrossed < 0 {
+		binsCrossed = -binsCrossed
+	}
+
+	result := &pkg.QuoteResult{
+		InputMint:       inputMint,
+		OutputMint:      outputMint,
+		AmountIn:        amountIn,
+		AmountOut:       amountOut,
+		FeeAmount:       fee,
+		FeeMint:         inputMint,
+		SpotPriceBefore: priceBefore,
+		SpotPriceAfter:  priceAfter,
+		TicksCrossed:    binsCrossed,
+		Accounts:        append([]solana.PublicKey{pool.PoolId, pool.reserveX, pool.reserveY, pool.oracle}, binArrays...),
+	}
+	result.SetPriceImpact()
+	return result, nil
+}
+
+// binPrice returns the price of a bin in output token units per input token
+func binPrice(binId int32, binStep uint16, swapForY bool) (cosmosmath.LegacyDec, error) {
+	priceX64, err := GetPriceFromID(binId, binStep)
+	if err != nil {
+		return cosmosmath.LegacyDec{}, fmt.Errorf("failed to get bin price: %w", err)
+	}
+	// Bin prices are Y per X in Q64.64
+	price := pkg.DecFromFixedPoint(priceX64.Big(), ScaleOffset)
+	if swapForY {
+		return price, nil
+	}
+	return pkg.InvertPrice(price), nil
+}
+
+// appendBinArray appends the address of binArray unless it is already the last entry
+func appendBinArray(binArrays []solana.PublicKey, poolId solana.PublicKey, binArray BinArray) []solana.PublicKey {
+	key, _ := DeriveBinArrayPDA(poolId, binArray.index)
+	if len(binArrays) > 0 && binArrays[len(binArrays)-1] == key {
+		return binArrays
+	}
+	return append(binArrays, key)
 }
 
 // validateSwapActivation checks if the swap is allowed based on pair status and activation conditions
@@ -10,6 +10,7 @@ import (
 	"cosmossdk.io/math"
 	bin "github.com/gagliardetto/binary"
 	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
 	"github.com/solana-zh/solroute/pkg/sol"
 )
 
@@ -20,10 +21,22 @@ func (pool *MeteoraDlmmPool) BuildSwapInstructions(
 	user solana.PublicKey,
 	inputMint string,
 	inputAmount math.Int,
-	minOut math.Int,
+	outputAmount math.Int,
+	mode pkg.SwapMode,
 	userBaseAccount solana.PublicKey,
 	userQuoteAccount solana.PublicKey,
 ) ([]solana.Instruction, error) {
+	// Build from the published state, loading it first for pools from a full program scan
+	s := pool.latest()
+	if s == nil || len(s.BinArrays) == 0 {
+		if err := pkg.RefreshPool(ctx, solClient, pool); err != nil {
+			return nil, fmt.Errorf("failed to load bin arrays: %w", err)
+		}
+		if s = pool.latest(); s == nil {
+			return nil, pkg.ErrPoolStateNotLoaded
+		}
+	}
+	pool = s
 	instructions := []solana.Instruction{}
 
 	var userInTokenAccount solana.PublicKey
@@ -37,19 +50,44 @@ func (pool *MeteoraDlmmPool) BuildSwapInstructions(
 		userOutTokenAccount = userBaseAccount
 	}
 
+	if mode != pkg.SwapModeExactIn && mode != pkg.SwapModeExactOut {
+		return nil, fmt.Errorf("unsupported swap mode: %v", mode)
+	}
+	tokenXProgram, err := tokenProgramFromFlag(pool.tokenMintXProgramFlag)
+	if err != nil {
+		return nil, fmt.Errorf("token x of pool %s: %w", pool.PoolId, err)
+	}
+	tokenYProgram, err := tokenProgramFromFlag(pool.tokenMintYProgramFlag)
+	if err != nil {
+		return nil, fmt.Errorf("token y of pool %s: %w", pool.PoolId, err)
+	}
+	// Token X moves between the user and reserve X, in the direction of the swap; likewise token Y
+	swapForY := inputMint == pool.TokenXMint.String()
+	hookX, err := pool.transferHookAccounts(ctx, solClient, pool.TokenXMint, pool.tokenMintXProgramFlag,
+		pool.reserveX, swapForY, user, userInTokenAccount, userOutTokenAccount, inputAmount, outputAmount)
+	if err != nil {
+		return nil, err
+	}
+	hookY, err := pool.transferHookAccounts(ctx, solClient, pool.TokenYMint, pool.tokenMintYProgramFlag,
+		pool.reserveY, !swapForY, user, userInTokenAccount, userOutTokenAccount, inputAmount, outputAmount)
+	if err != nil {
+		return nil, err
+	}
+
 	instruction := SwapInstruction{
+		Mode:             mode,
 		AmountIn:         inputAmount.Uint64(),
-		MinAmountOut:     minOut.Uint64(),
-		AccountMetaSlice: make(solana.AccountMetaSlice, 16+len(pool.BinArrays)),
+		AmountOut:        outputAmount.Uint64(),
+		AccountMetaSlice: make(solana.AccountMetaSlice, 16, 16+len(hookX)+len(hookY)+len(pool.BinArrays)),
 		RemainingAccountsInfo: RemainingAccountsInfo{
 			Slices: []RemainingAccountsSlice{
 				{
 					AccountsType: AccountsTypeTransferHookX,
-					Length:       0, // Set as needed
+					Length:       uint8(len(hookX)),
 				},
 				{
 					AccountsType: AccountsTypeTransferHookY,
-					Length:       0, // Set as needed
+					Length:       uint8(len(hookY)),
 				},
 			},
 		},
@@ -58,7 +96,6 @@ func (pool *MeteoraDlmmPool) BuildSwapInstructions(
 		Impl: instruction,
 	}
 
-	// Ensure correct Token Program address is used
 	instruction.AccountMetaSlice[0] = solana.NewAccountMeta(pool.PoolId, true, false)
 	if pool.bitmapExtension != nil {
 		instruction.AccountMetaSlice[1] = solana.NewAccountMeta(pool.BitmapExtensionKey, false, false)
@@ -74,17 +111,17 @@ func (pool *MeteoraDlmmPool) BuildSwapInstructions(
 	instruction.AccountMetaSlice[8] = solana.NewAccountMeta(pool.oracle, true, false)
 	instruction.AccountMetaSlice[9] = solana.NewAccountMeta(MeteoraProgramID, false, false) // Host fee account - set to null in JS SDK but not in Rust SDK
 	instruction.AccountMetaSlice[10] = solana.NewAccountMeta(user, true, true)
-	tokenProgramID := solana.MustPublicKeyFromBase58("TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA")
-	instruction.AccountMetaSlice[11] = solana.NewAccountMeta(tokenProgramID, false, false)
-	instruction.AccountMetaSlice[12] = solana.NewAccountMeta(tokenProgramID, false, false)
+	instruction.AccountMetaSlice[11] = solana.NewAccountMeta(tokenXProgram, false, false)
+	instruction.AccountMetaSlice[12] = solana.NewAccountMeta(tokenYProgram, false, false)
 	instruction.AccountMetaSlice[13] = solana.NewAccountMeta(MemoProgramID, false, false)
 	instruction.AccountMetaSlice[14] = solana.NewAccountMeta(DeriveEventAuthorityPDA(), false, false)
 	instruction.AccountMetaSlice[15] = solana.NewAccountMeta(MeteoraProgramID, true, false)
 
-	index := 16
+	// The remaining accounts start with the transfer hook slices, followed by the bin arrays
+	instruction.AccountMetaSlice = append(instruction.AccountMetaSlice, hookX...)
+	instruction.AccountMetaSlice = append(instruction.AccountMetaSlice, hookY...)
 	for binArrayKey := range pool.BinArrays {
-		instruction.AccountMetaSlice[index] = solana.NewAccountMeta(solana.MustPublicKeyFromBase58(binArrayKey), true, false)
-		index++
+		instruction.AccountMetaSlice = append(instruction.AccountMetaSlice, solana.NewAccountMeta(solana.MustPublicKeyFromBase58(binArrayKey), true, false))
 	}
 
 	instructions = append(instructions, &instruction)
@@ -92,6 +129,55 @@ func (pool *MeteoraDlmmPool) BuildSwapInstructions(
 	return instructions, nil
 }
 
+// transferHookAccounts resolves the remaining accounts the transfer hook of mint needs for its
+// transfer in the swap: from the user into the reserve when isInput, out of the reserve otherwise
+func (pool *MeteoraDlmmPool) transferHookAccounts(
+	ctx context.Context,
+	solClient *sol.Client,
+	mint solana.PublicKey,
+	programFlag uint8,
+	reserve solana.PublicKey,
+	isInput bool,
+	user, userInTokenAccount, userOutTokenAccount solana.PublicKey,
+	inputAmount, outputAmount math.Int,
+) (solana.AccountMetaSlice, error) {
+	// Only Token-2022 mints can have a transfer hook
+	if programFlag != 1 {
+		return nil, nil
+	}
+	mints, err := solClient.GetMints(ctx, mint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mint %s: %w", mint, err)
+	}
+
+	// The pool account owns the reserves
+	source, destination, owner, amount := reserve, userOutTokenAccount, pool.PoolId, outputAmount
+	if isInput {
+		source, destination, owner, amount = userInTokenAccount, reserve, user, inputAmount
+	}
+	accounts, err := solClient.ResolveTransferHookAccounts(ctx, mints[mint], source, destination, owner, amount.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	// A remaining accounts slice holds at most 255 accounts
+	if len(accounts) > 255 {
+		return nil, fmt.Errorf("transfer hook of mint %s needs %d accounts", mint, len(accounts))
+	}
+	return accounts, nil
+}
+
+// tokenProgramFromFlag maps the token program flag of a pool's mint to the program
+func tokenProgramFromFlag(flag uint8) (solana.PublicKey, error) {
+	switch flag {
+	case 0:
+		return solana.TokenProgramID, nil
+	case 1:
+		return solana.Token2022ProgramID, nil
+	default:
+		return solana.PublicKey{}, fmt.Errorf("token program flag %d: %w", flag, sol.ErrUnsupportedTokenProgram)
+	}
+}
+
 // AccountsType represents the type of accounts in the remaining accounts slice
 type AccountsType uint8
 
@@ -111,11 +197,14 @@ type RemainingAccountsInfo struct {
 	Slices []RemainingAccountsSlice // Define based on actual SliceInfo structure if needed
 }
 
-// SwapInstruction represents a Meteora swap instruction
+// SwapInstruction represents a Meteora swap instruction.
+// In SwapModeExactIn it encodes swap2 with AmountIn as amount_in and AmountOut as min_amount_out;
+// in SwapModeExactOut it encodes swap_exact_out2 with AmountIn as max_in_amount and AmountOut as out_amount.
 type SwapInstruction struct {
 	bin.BaseVariant
+	Mode                    pkg.SwapMode          `bin:"-"`
 	AmountIn                uint64                `bin:"amount_in"`
-	MinAmountOut            uint64                `bin:"min_amount_out"`
+	AmountOut               uint64                `bin:"amount_out"`
 	RemainingAccountsInfo   RemainingAccountsInfo `bin:"remaining_accounts_info"`
 	solana.AccountMetaSlice `bin:"-" borsh_skip:"true"`
 }
@@ -137,18 +226,29 @@ func (instruction *SwapInstruction) Data() ([]byte, error) {
 	// name := "swap2"
 	// discriminator := sol.GetDiscriminator(namespace, name)
 
+	var discriminator [8]byte
+	switch instruction.Mode {
+	case pkg.SwapModeExactIn:
+		discriminator = Swap2IxDiscm
+	case pkg.SwapModeExactOut:
+		discriminator = SwapExactOut2IxDiscm
+	default:
+		return nil, fmt.Errorf("unsupported swap mode: %v", instruction.Mode)
+	}
+
 	buffer := new(bytes.Buffer)
-	if _, err := buffer.Write(Swap2IxDiscm[:]); err != nil {
+	if _, err := buffer.Write(discriminator[:]); err != nil {
 		return nil, fmt.Errorf("failed to write discriminator: %w", err)
 	}
 
+	// Write amount in (max amount in for exact out)
 	if err := bin.NewBorshEncoder(buffer).WriteUint64(instruction.AmountIn, binary.LittleEndian); err != nil {
-		return nil, fmt.Errorf("failed to encode amount: %w", err)
+		return nil, fmt.Errorf("failed to encode amount in: %w", err)
 	}
 
-	// Write minimum amount out threshold
-	if err := bin.NewBorshEncoder(buffer).WriteUint64(instruction.MinAmountOut, binary.LittleEndian); err != nil {
-		return nil, fmt.Errorf("failed to encode minimum amount out threshold: %w", err)
+	// Write amount out (min amount out for exact in)
+	if err := bin.NewBorshEncoder(buffer).WriteUint64(instruction.AmountOut, binary.LittleEndian); err != nil {
+		return nil, fmt.Errorf("failed to encode amount out: %w", err)
 	}
 
 	if err := bin.NewBorshEncoder(buffer).Encode(instruction.RemainingAccountsInfo); err != nil {
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"sync/atomic"
 
 	"cosmossdk.io/math"
 	bin "github.com/gagliardetto/binary"
@@ -48,6 +49,13 @@ type PumpAMMPool struct {
 	PoolId      solana.PublicKey
 	BaseAmount  math.Int
 	QuoteAmount math.Int
+
+	// transferFees holds the Token-2022 transfer fees of the pool's mints
+	transferFees pkg.TransferFees `bin:"skip"`
+	// slot is the highest slot of the account data applied with ApplyUpdate
+	slot uint64 `bin:"skip"`
+	// snapshot holds the *PumpAMMPool published by the latest ApplyUpdate
+	snapshot atomic.Value `bin:"skip"`
 }
 
 func (pool *PumpAMMPool) ProtocolName() pkg.ProtocolName {
@@ -117,6 +125,7 @@ func ParsePoolData(data []byte) (*PumpAMMPool, error) {
 	} else {
 		layout.CoinCreator = solana.MustPublicKeyFromBase58("11111111111111111111111111111111")
 	}
+	layout.transferFees.Track(layout.BaseMint, layout.QuoteMint)
 
 	return layout, nil
 }
@@ -135,15 +144,46 @@ func (s *PumpAMMPool) BuildSwapInstructions(
 	user solana.PublicKey,
 	inputMint string,
 	inputAmount math.Int,
-	minOut math.Int,
+	outputAmount math.Int,
+	mode pkg.SwapMode,
 	userBaseAccount solana.PublicKey,
 	userQuoteAccount solana.PublicKey,
 ) ([]solana.Instruction, error) {
-	if inputMint == s.BaseMint.String() {
-		return s.buyInAMMPool(user, s, inputAmount, minOut, userBaseAccount, userQuoteAccount)
-	} else {
-		return s.sellInAMMPool(user, s, inputAmount, minOut, userBaseAccount, userQuoteAccount)
+	if mode != pkg.SwapModeExactIn && mode != pkg.SwapModeExactOut {
+		return nil, fmt.Errorf("unsupported swap mode: %v", mode)
+	}
+	if mode == pkg.SwapModeExactOut && inputMint == s.BaseMint.String() {
+		return nil, fmt.Errorf("exact-out swap to the quote mint is not supported by pump amm")
+	}
+	// Either mint may be a Token-2022 mint; the pool account does not record which
+	programs, err := solClient.GetMintTokenPrograms(ctx, s.BaseMint, s.QuoteMint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mint token programs: %w", err)
+	}
+	baseTokenProgram, quoteTokenProgram := programs[s.BaseMint], programs[s.QuoteMint]
+
+	// sell spends an exact base amount; buy receives an exact base amount for at most
+	// the given quote amount
+	if mode == pkg.SwapModeExactIn && inputMint == s.BaseMint.String() {
+		return s.sellInAMMPool(user, s, inputAmount, outputAmount, userBaseAccount, userQuoteAccount, baseTokenProgram, quoteTokenProgram)
 	}
+	baseAmountOut := outputAmount
+	if mode == pkg.SwapModeExactIn {
+		// There is no exact quote-in instruction: buy the base amount the input is quoted at,
+		// before the transfer fee the pool's transfer withholds, for at most the input amount
+		quote, err := s.Quote(ctx, inputMint, inputAmount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to quote exact-in buy: %w", err)
+		}
+		if quote.AmountOut.LT(outputAmount) {
+			return nil, fmt.Errorf("quoted output %v is below the minimum output %v", quote.AmountOut, outputAmount)
+		}
+		baseAmountOut = quote.AmountOut
+		if !quote.TransferFeeOut.IsNil() {
+			baseAmountOut = baseAmountOut.Add(quote.TransferFeeOut)
+		}
+	}
+	return s.buyInAMMPool(user, s, inputAmount, baseAmountOut, userBaseAccount, userQuoteAccount, baseTokenProgram, quoteTokenProgram)
 }
 
 func (s *PumpAMMPool) buyInAMMPool(
@@ -153,6 +193,8 @@ func (s *PumpAMMPool) buyInAMMPool(
 	outAmountWithDecimals math.Int,
 	userBaseAccount solana.PublicKey,
 	userQuoteAccount solana.PublicKey,
+	baseTokenProgram solana.PublicKey,
+	quoteTokenProgram solana.PublicKey,
 ) ([]solana.Instruction, error) {
 	// Initialize instruction array
 	instrs := []solana.Instruction{}
@@ -170,7 +212,6 @@ func (s *PumpAMMPool) buyInAMMPool(
 	inst.BaseVariant = bin.BaseVariant{
 		Impl: inst,
 	}
-	// Ensure correct Token Program address
 	inst.AccountMetaSlice[0] = solana.NewAccountMeta(pool.PoolId, false, false)
 	inst.AccountMetaSlice[1] = solana.NewAccountMeta(userAddr, true, true)
 	inst.AccountMetaSlice[2] = solana.NewAccountMeta(PumpGlobalConfig, false, false)
@@ -182,9 +223,8 @@ func (s *PumpAMMPool) buyInAMMPool(
 	inst.AccountMetaSlice[8] = solana.NewAccountMeta(pool.PoolQuoteTokenAccount, true, false)
 	inst.AccountMetaSlice[9] = solana.NewAccountMeta(PumpProtocolFeeRecipient, false, false)
 	inst.AccountMetaSlice[10] = solana.NewAccountMeta(PumpProtocolFeeRecipientTokenAccount, true, false)
-	tokenProgramID := solana.MustPublicKeyFromBase58("TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA")
-	inst.AccountMetaSlice[11] = solana.NewAccountMeta(tokenProgramID, false, false)
-	inst.AccountMetaSlice[12] = solana.NewAccountMeta(tokenProgramID, false, false)
+	inst.AccountMetaSlice[11] = solana.NewAccountMeta(baseTokenProgram, false, false)
+	inst.AccountMetaSlice[12] = solana.NewAccountMeta(quoteTokenProgram, false, false)
 	inst.AccountMetaSlice[13] = solana.NewAccountMeta(solana.MustPublicKeyFromBase58("11111111111111111111111111111111"), false, false)
 	inst.AccountMetaSlice[14] = solana.NewAccountMeta(solana.MustPublicKeyFromBase58("ATokenGPvbdGVxr1b2hvZbsiqW5xWH25efTNsLJA8knL"), false, false)
 	inst.AccountMetaSlice[15] = solana.NewAccountMeta(solana.MustPublicKeyFromBase58("GS4CU59F31iL7aR2Q8zVS8DRrcRnXX1yjQ66TqNVQnaR"), false, false)
@@ -213,6 +253,8 @@ func (s *PumpAMMPool) sellInAMMPool(
 	minQuoteAmountOut math.Int,
 	userBaseAccount solana.PublicKey,
 	userQuoteAccount solana.PublicKey,
+	baseTokenProgram solana.PublicKey,
+	quoteTokenProgram solana.PublicKey,
 ) ([]solana.Instruction, error) {
 	instrs := []solana.Instruction{}
 
@@ -239,9 +281,8 @@ func (s *PumpAMMPool) sellInAMMPool(
 	inst.AccountMetaSlice[8] = solana.NewAccountMeta(pool.PoolQuoteTokenAccount, true, false)
 	inst.AccountMetaSlice[9] = solana.NewAccountMeta(PumpProtocolFeeRecipient, false, false)
 	inst.AccountMetaSlice[10] = solana.NewAccountMeta(PumpProtocolFeeRecipientTokenAccount, true, false)
-	tokenProgramID := solana.MustPublicKeyFromBase58("TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA")
-	inst.AccountMetaSlice[11] = solana.NewAccountMeta(tokenProgramID, false, false)
-	inst.AccountMetaSlice[12] = solana.NewAccountMeta(tokenProgramID, false, false)
+	inst.AccountMetaSlice[11] = solana.NewAccountMeta(baseTokenProgram, false, false)
+	inst.AccountMetaSlice[12] = solana.NewAccountMeta(quoteTokenProgram, false, false)
 	inst.AccountMetaSlice[13] = solana.NewAccountMeta(solana.MustPublicKeyFromBase58("11111111111111111111111111111111"), false, false)
 	inst.AccountMetaSlice[14] = solana.NewAccountMeta(solana.MustPublicKeyFromBase58("ATokenGPvbdGVxr1b2hvZbsiqW5xWH25efTNsLJA8knL"), false, false)
 	inst.AccountMetaSlice[15] = solana.NewAccountMeta(solana.MustPublicKeyFromBase58("GS4CU59F31iL7aR2Q8zVS8DRrcRnXX1yjQ66TqNVQnaR"), false, false)
@@ -342,52 +383,175 @@ func (inst *SellSwapInstruction) Data() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (pool *PumpAMMPool) Quote(ctx context.Context, solClient *sol.Client, inputMint string, inputAmount math.Int) (math.Int, error) {
-	// update pool data first
-	accounts := make([]solana.PublicKey, 0)
-	accounts = append(accounts, pool.PoolBaseTokenAccount)
-	accounts = append(accounts, pool.PoolQuoteTokenAccount)
-	results, err := solClient.GetMultipleAccountsWithOpts(ctx, accounts)
-	if err != nil {
-		return math.NewInt(0), fmt.Errorf("batch request failed: %v", err)
-	}
-	for i, result := range results.Value {
-		if result == nil {
-			return math.NewInt(0), fmt.Errorf("result is nil, account: %v", accounts[i].String())
+// DependentAccounts returns the pool account, its token accounts and the accounts of the mints' transfer fees
+func (pool *PumpAMMPool) DependentAccounts() []solana.PublicKey {
+	accounts := []solana.PublicKey{pool.PoolId, pool.PoolBaseTokenAccount, pool.PoolQuoteTokenAccount}
+	return append(accounts, pool.transferFees.DependentAccounts()...)
+}
+
+// ApplyUpdate applies the data of the pool account, one of its token accounts, one of its mints or the clock sysvar
+func (pool *PumpAMMPool) ApplyUpdate(account solana.PublicKey, data []byte, slot uint64) error {
+	switch account {
+	case pool.PoolId:
+		layout, err := ParsePoolData(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse pool: %w", err)
 		}
-		accountKey := accounts[i].String()
-		if pool.PoolBaseTokenAccount.String() == accountKey {
-			amountBytes := result.Data.GetBinary()[64:72]
-			amountUint := binary.LittleEndian.Uint64(amountBytes)
-			amount := math.NewIntFromUint64(amountUint)
-			pool.BaseAmount = amount
+		// Copy the on-chain fields only; the snapshot must not be overwritten while it is read
+		pool.PoolBump = layout.PoolBump
+		pool.Index = layout.Index
+		pool.Creator = layout.Creator
+		pool.BaseMint = layout.BaseMint
+		pool.QuoteMint = layout.QuoteMint
+		pool.LpMint = layout.LpMint
+		pool.PoolBaseTokenAccount = layout.PoolBaseTokenAccount
+		pool.PoolQuoteTokenAccount = layout.PoolQuoteTokenAccount
+		pool.LpSupply = layout.LpSupply
+		pool.CoinCreator = layout.CoinCreator
+		pool.transferFees.Track(pool.BaseMint, pool.QuoteMint)
+	case pool.PoolBaseTokenAccount, pool.PoolQuoteTokenAccount:
+		amount, err := sol.ParseTokenAccountAmount(data)
+		if err != nil {
+			return err
+		}
+		if account == pool.PoolBaseTokenAccount {
+			pool.BaseAmount = math.NewIntFromUint64(amount)
 		} else {
-			amountBytes := result.Data.GetBinary()[64:72]
-			amountUint := binary.LittleEndian.Uint64(amountBytes)
-			amount := math.NewIntFromUint64(amountUint)
-			pool.QuoteAmount = amount
+			pool.QuoteAmount = math.NewIntFromUint64(amount)
+		}
+	case pool.BaseMint, pool.QuoteMint:
+		if err := pool.transferFees.ApplyMint(account, data); err != nil {
+			return err
 		}
+	case solana.SysVarClockPubkey:
+		clock, err := sol.ParseClock(data)
+		if err != nil {
+			return err
+		}
+		pool.transferFees.SetEpoch(clock.Epoch)
+	default:
+		return fmt.Errorf("account %s is not a dependent account of pool %s", account, pool.PoolId)
+	}
+	pool.slot = max(pool.slot, slot)
+	pool.publish()
+	return nil
+}
+
+// publish stores a copy of the current state as the pool's snapshot
+func (pool *PumpAMMPool) publish() {
+	s := *pool
+	s.snapshot = atomic.Value{}
+	s.snapshot.Store(&s)
+	pool.snapshot.Store(&s)
+}
+
+// latest returns the published snapshot, or nil before the first ApplyUpdate
+func (pool *PumpAMMPool) latest() *PumpAMMPool {
+	s, _ := pool.snapshot.Load().(*PumpAMMPool)
+	return s
+}
+
+// Snapshot returns the state published by the latest ApplyUpdate
+func (pool *PumpAMMPool) Snapshot() pkg.PoolSnapshot {
+	if s := pool.latest(); s != nil {
+		return s
 	}
+	return nil
+}
 
+// Slot returns the highest slot of the account data applied to the pool
+func (pool *PumpAMMPool) Slot() uint64 {
+	return pool.slot
+}
+
+// feeMultiplier returns (1 - fee rate) scaled by BaseDecimal
+func feeMultiplier() math.Int {
 	feeRate := 1 - DefaultFeeRate
-	feeMultiplier := math.NewInt(int64(feeRate * float64(BaseDecimalInt)))
+	return math.NewInt(int64(feeRate * float64(BaseDecimalInt)))
+}
+
+func (pool *PumpAMMPool) Quote(ctx context.Context, inputMint string, inputAmount math.Int) (*pkg.QuoteResult, error) {
+	s := pool.latest()
+	if s == nil || s.BaseAmount.IsNil() || s.QuoteAmount.IsNil() || !s.transferFees.Loaded() {
+		return nil, pkg.ErrPoolStateNotLoaded
+	}
+	return s.transferFees.QuoteExactIn(inputMint, inputAmount, func(inputMint string, amountIn math.Int) (*pkg.QuoteResult, error) {
+		return s.quote(inputMint, amountIn), nil
+	})
+}
+
+func (pool *PumpAMMPool) quote(inputMint string, inputAmount math.Int) *pkg.QuoteResult {
+	outputMint, reserveIn, reserveOut := pool.reservesFor(inputMint)
 
 	// Calculate k = baseAmount * quoteAmount
 	k := pool.BaseAmount.Mul(pool.QuoteAmount)
 
+	// Calculate newReserveIn = reserveIn + amountWithFee
+	amountWithFee := inputAmount.Mul(feeMultiplier()).Quo(BaseDecimal)
+	newReserveIn := reserveIn.Add(amountWithFee)
+	// Calculate newReserveOut = k / newReserveIn
+	newReserveOut := k.Quo(newReserveIn)
+	amountOut := reserveOut.Sub(newReserveOut)
+
+	return pool.quoteResult(inputMint, outputMint, inputAmount, amountOut, inputAmount.Sub(amountWithFee), reserveIn, reserveOut)
+}
+
+// QuoteExactOut calculates the input amount needed to receive exactly outputAmount
+func (pool *PumpAMMPool) QuoteExactOut(ctx context.Context, inputMint string, outputAmount math.Int) (*pkg.QuoteResult, error) {
+	s := pool.latest()
+	if s == nil || s.BaseAmount.IsNil() || s.QuoteAmount.IsNil() || !s.transferFees.Loaded() {
+		return nil, pkg.ErrPoolStateNotLoaded
+	}
+	return s.transferFees.QuoteExactOut(inputMint, outputAmount, s.quoteExactOut)
+}
+
+func (pool *PumpAMMPool) quoteExactOut(inputMint string, outputAmount math.Int) (*pkg.QuoteResult, error) {
+	outputMint, reserveIn, reserveOut := pool.reservesFor(inputMint)
+	if outputAmount.GTE(reserveOut) {
+		return nil, fmt.Errorf("insufficient liquidity: output %v exceeds reserve %v", outputAmount, reserveOut)
+	}
+
+	amountIn := math.ZeroInt()
+	fee := math.ZeroInt()
+	if !outputAmount.IsZero() {
+		// Net input: reserveIn * amountOut / (reserveOut - amountOut), rounded up
+		denominator := reserveOut.Sub(outputAmount)
+		amountInAfterFee := reserveIn.Mul(outputAmount).Add(denominator).Sub(math.OneInt()).Quo(denominator)
+
+		// Gross up for the fee, rounded up
+		feeMultiplier := feeMultiplier()
+		amountIn = amountInAfterFee.Mul(BaseDecimal).Add(feeMultiplier).Sub(math.OneInt()).Quo(feeMultiplier)
+		fee = amountIn.Sub(amountInAfterFee)
+	}
+
+	return pool.quoteResult(inputMint, outputMint, amountIn, outputAmount, fee, reserveIn, reserveOut), nil
+}
+
+// reservesFor returns the output mint and the input and output reserves for a swap from inputMint
+func (pool *PumpAMMPool) reservesFor(inputMint string) (outputMint string, reserveIn, reserveOut math.Int) {
 	if inputMint == pool.BaseMint.String() {
-		// Calculate newBase = baseAmount + amountWithFee
-		newBase := pool.BaseAmount.Add(inputAmount.Mul(feeMultiplier).Quo(BaseDecimal))
-		// Calculate newQuote = k / newBase
-		newQuote := k.Quo(newBase)
-		priceBaseToQuote := pool.QuoteAmount.Sub(newQuote)
-		return priceBaseToQuote, nil
-	} else {
-		// Calculate newQuote = quoteAmount + amountWithFee
-		newQuote := pool.QuoteAmount.Add(inputAmount.Mul(feeMultiplier).Quo(BaseDecimal))
-		// Calculate newBase = k / newQuote
-		newBase := k.Quo(newQuote)
-		priceQuoteToBase := pool.BaseAmount.Sub(newBase)
-		return priceQuoteToBase, nil
+		return pool.QuoteMint.String(), pool.BaseAmount, pool.QuoteAmount
+	}
+	return pool.BaseMint.String(), pool.QuoteAmount, pool.BaseAmount
+}
+
+// quoteResult builds the quote result of a swap whose fee is charged on the input
+func (pool *PumpAMMPool) quoteResult(
+	inputMint, outputMint string,
+	amountIn, amountOut, fee math.Int,
+	reserveIn, reserveOut math.Int,
+) *pkg.QuoteResult {
+	result := &pkg.QuoteResult{
+		InputMint:       inputMint,
+		OutputMint:      outputMint,
+		AmountIn:        amountIn,
+		AmountOut:       amountOut,
+		FeeAmount:       fee,
+		FeeMint:         inputMint,
+		SpotPriceBefore: pkg.SpotPrice(reserveIn, reserveOut),
+		SpotPriceAfter:  pkg.SpotPrice(reserveIn.Add(amountIn.Sub(fee)), reserveOut.Sub(amountOut)),
+		Accounts:        []solana.PublicKey{pool.PoolId, pool.PoolBaseTokenAccount, pool.PoolQuoteTokenAccount},
 	}
+	result.SetPriceImpact()
+	return result
 }
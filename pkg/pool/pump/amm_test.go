@@ -0,0 +1,47 @@
+package pump
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/internal/pooltest"
+)
+
+// poolData returns the account data of a PumpSwap pool of pool's mints and token accounts with lpSupply
+func poolData(pool *PumpAMMPool, lpSupply uint64) []byte {
+	data := make([]byte, PoolDataSize)
+	copy(data[43:], pool.BaseMint[:])
+	copy(data[75:], pool.QuoteMint[:])
+	copy(data[139:], pool.PoolBaseTokenAccount[:])
+	copy(data[171:], pool.PoolQuoteTokenAccount[:])
+	binary.LittleEndian.PutUint64(data[203:], lpSupply)
+	return data
+}
+
+func TestPumpAMMPoolQuoteDuringUpdates(t *testing.T) {
+	pool := &PumpAMMPool{
+		PoolId:                solana.NewWallet().PublicKey(),
+		BaseMint:              solana.NewWallet().PublicKey(),
+		QuoteMint:             solana.NewWallet().PublicKey(),
+		PoolBaseTokenAccount:  solana.NewWallet().PublicKey(),
+		PoolQuoteTokenAccount: solana.NewWallet().PublicKey(),
+	}
+	pooltest.QuoteDuringUpdates(t, pooltest.Fixture{
+		Name:   "pump amm",
+		Pool:   pool,
+		Amount: math.NewInt(1_000_000),
+		Rounds: 500,
+		State: func(i int) []pooltest.Update {
+			return []pooltest.Update{
+				{Account: pool.PoolId, Data: poolData(pool, uint64(1000+i))},
+				{Account: pool.PoolBaseTokenAccount, Data: pooltest.TokenAccountData(uint64(1_000_000_000 + i))},
+				{Account: pool.PoolQuoteTokenAccount, Data: pooltest.TokenAccountData(uint64(2_000_000_000 - i))},
+				{Account: pool.BaseMint, Data: pooltest.MintData(6)},
+				{Account: pool.QuoteMint, Data: pooltest.MintData(9)},
+				{Account: solana.SysVarClockPubkey, Data: pooltest.ClockData(uint64(100+i), 500)},
+			}
+		},
+	})
+}
@@ -10,9 +10,9 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"sync/atomic"
 	"unsafe"
 
-	"cosmossdk.io/math"
 	cosmath "cosmossdk.io/math"
 	bin "github.com/gagliardetto/binary"
 	"github.com/gagliardetto/solana-go"
@@ -98,6 +98,18 @@ type AMMPool struct {
 	QuoteAmount  cosmath.Int
 	BaseReserve  cosmath.Int
 	QuoteReserve cosmath.Int
+
+	// OrderBook counts the funds the pool holds in its OpenBook orders as reserves, like the program
+	// did while pools traded on the order book. The open orders and market accounts become dependent accounts.
+	OrderBook bool
+	// Base and quote totals of the pool's open orders account, loaded when OrderBook is set
+	OpenOrdersBaseTotal  cosmath.Int
+	OpenOrdersQuoteTotal cosmath.Int
+
+	// slot is the highest slot of the account data applied with ApplyUpdate
+	slot uint64
+	// snapshot holds the *AMMPool published by the latest ApplyUpdate
+	snapshot atomic.Value
 }
 
 func (pool *AMMPool) ProtocolName() pkg.ProtocolName {
@@ -278,6 +290,24 @@ type MarketStateLayoutV3 struct {
 	PaddingEnd             [7]byte
 }
 
+// Offsets of the base and quote token totals in an OpenBook open orders account:
+// "serum" padding, account flags, market and owner, then the free and total amounts
+const (
+	openOrdersBaseTotalOffset  = 5 + 8 + 32 + 32 + 8
+	openOrdersQuoteTotalOffset = openOrdersBaseTotalOffset + 16
+)
+
+// ParseOpenOrdersTotals reads the base and quote totals, free and locked in orders, of an
+// OpenBook open orders account
+func ParseOpenOrdersTotals(data []byte) (baseTotal, quoteTotal uint64, err error) {
+	if len(data) < openOrdersQuoteTotalOffset+8 {
+		return 0, 0, fmt.Errorf("open orders data too short: %d bytes", len(data))
+	}
+	baseTotal = binary.LittleEndian.Uint64(data[openOrdersBaseTotalOffset:])
+	quoteTotal = binary.LittleEndian.Uint64(data[openOrdersQuoteTotalOffset:])
+	return baseTotal, quoteTotal, nil
+}
+
 func (l MarketStateLayoutV3) Span() uint64 {
 	return uint64(unsafe.Sizeof(l)) - 4 // Blame golang
 }
@@ -323,56 +353,152 @@ func (p *AMMPool) GetTokens() (baseMint, quoteMint string) {
 	return p.BaseMint.String(), p.QuoteMint.String()
 }
 
-// Quote calculates the expected output amount for a given input amount
-// It takes into account the current pool reserves and fees
-func (p *AMMPool) Quote(
-	ctx context.Context,
-	solClient *sol.Client,
-	inputMint string,
-	inputAmount cosmath.Int,
-) (cosmath.Int, error) {
-	// update pool data first
-	accounts := make([]solana.PublicKey, 0)
-	accounts = append(accounts, p.BaseVault)
-	accounts = append(accounts, p.QuoteVault)
-	results, err := solClient.GetMultipleAccountsWithOpts(ctx, accounts)
-	if err != nil {
-		return math.NewInt(0), fmt.Errorf("batch request failed: %v", err)
+// DependentAccounts returns the pool account and its vaults, its market account until the
+// market's order book accounts are known, and with OrderBook set its open orders and market accounts
+func (p *AMMPool) DependentAccounts() []solana.PublicKey {
+	accounts := []solana.PublicKey{p.PoolId, p.BaseVault, p.QuoteVault}
+	if p.OrderBook {
+		accounts = append(accounts, p.OpenOrders, p.MarketId)
+	} else if p.MarketBids.IsZero() {
+		accounts = append(accounts, p.MarketId)
 	}
-	for i, result := range results.Value {
-		if result == nil {
-			return math.NewInt(0), fmt.Errorf("result is nil, account: %v", accounts[i].String())
+	return accounts
+}
+
+// ApplyUpdate applies the data of the pool account, one of its vaults, its market account, or
+// with OrderBook set its open orders account
+func (p *AMMPool) ApplyUpdate(account solana.PublicKey, data []byte, slot uint64) error {
+	if !p.OrderBook && account == p.OpenOrders {
+		return fmt.Errorf("account %s is not a dependent account of pool %s", account, p.PoolId)
+	}
+	switch account {
+	case p.PoolId:
+		if err := p.Decode(data); err != nil {
+			return fmt.Errorf("failed to decode pool: %w", err)
 		}
-		accountKey := accounts[i].String()
-		if p.BaseVault.String() == accountKey {
-			amountBytes := result.Data.GetBinary()[64:72]
-			amountUint := binary.LittleEndian.Uint64(amountBytes)
-			amount := math.NewIntFromUint64(amountUint)
-			p.BaseAmount = amount
+	case p.BaseVault, p.QuoteVault:
+		amount, err := sol.ParseTokenAccountAmount(data)
+		if err != nil {
+			return err
+		}
+		if account == p.BaseVault {
+			p.BaseAmount = cosmath.NewIntFromUint64(amount)
 		} else {
-			amountBytes := result.Data.GetBinary()[64:72]
-			amountUint := binary.LittleEndian.Uint64(amountBytes)
-			amount := math.NewIntFromUint64(amountUint)
-			p.QuoteAmount = amount
+			p.QuoteAmount = cosmath.NewIntFromUint64(amount)
+		}
+	case p.OpenOrders:
+		baseTotal, quoteTotal, err := ParseOpenOrdersTotals(data)
+		if err != nil {
+			return err
 		}
+		p.OpenOrdersBaseTotal = cosmath.NewIntFromUint64(baseTotal)
+		p.OpenOrdersQuoteTotal = cosmath.NewIntFromUint64(quoteTotal)
+	case p.MarketId:
+		var market MarketStateLayoutV3
+		if err := market.Decode(data); err != nil {
+			return fmt.Errorf("failed to decode market: %w", err)
+		}
+		p.SetMarket(&market)
+	default:
+		return fmt.Errorf("account %s is not a dependent account of pool %s", account, p.PoolId)
 	}
+	p.slot = max(p.slot, slot)
+	p.updateReserves()
+	p.publish()
+	return nil
+}
 
+// publish stores a copy of the current state as the pool's snapshot
+func (p *AMMPool) publish() {
+	s := *p
+	s.snapshot = atomic.Value{}
+	s.snapshot.Store(&s)
+	p.snapshot.Store(&s)
+}
+
+// latest returns the published snapshot, or nil before the first ApplyUpdate
+func (p *AMMPool) latest() *AMMPool {
+	s, _ := p.snapshot.Load().(*AMMPool)
+	return s
+}
+
+// Snapshot returns the state published by the latest ApplyUpdate
+func (p *AMMPool) Snapshot() pkg.PoolSnapshot {
+	if s := p.latest(); s != nil {
+		return s
+	}
+	return nil
+}
+
+// Slot returns the highest slot of the account data applied to the pool
+func (p *AMMPool) Slot() uint64 {
+	return p.slot
+}
+
+// updateReserves recomputes the effective reserves once both vault balances are known
+func (p *AMMPool) updateReserves() {
+	if p.BaseAmount.IsNil() || p.QuoteAmount.IsNil() {
+		return
+	}
+	baseTotal, quoteTotal := p.BaseAmount, p.QuoteAmount
+	if p.OrderBook {
+		if p.OpenOrdersBaseTotal.IsNil() || p.OpenOrdersQuoteTotal.IsNil() {
+			return
+		}
+		baseTotal = baseTotal.Add(p.OpenOrdersBaseTotal)
+		quoteTotal = quoteTotal.Add(p.OpenOrdersQuoteTotal)
+	}
 	// Calculate effective reserves by subtracting pending PnL
-	p.BaseReserve = p.BaseAmount.Sub(cosmath.NewInt(int64(p.BaseNeedTakePnl)))
-	p.QuoteReserve = p.QuoteAmount.Sub(cosmath.NewInt(int64(p.QuoteNeedTakePnl)))
+	p.BaseReserve = baseTotal.Sub(cosmath.NewIntFromUint64(p.BaseNeedTakePnl))
+	p.QuoteReserve = quoteTotal.Sub(cosmath.NewIntFromUint64(p.QuoteNeedTakePnl))
+}
 
-	// Set reserves and decimals based on swap direction
-	reserves := []cosmath.Int{p.BaseReserve, p.QuoteReserve}
-	mintDecimals := []int{int(p.BaseDecimal), int(p.QuoteDecimal)}
+// SetMarket copies the OpenBook accounts the swap instruction needs from the pool's market
+func (p *AMMPool) SetMarket(market *MarketStateLayoutV3) {
+	p.MarketBaseVault = market.BaseVault
+	p.MarketQuoteVault = market.QuoteVault
+	p.MarketBids = market.Bids
+	p.MarketAsks = market.Asks
+	p.MarketEventQueue = market.EventQueue
+}
 
-	// Swap reserves if input is quote token
+// swapFee returns the pool's swap fee rate, which the program charges on the input amount
+func (p *AMMPool) swapFee() (numerator, denominator cosmath.Int, err error) {
+	if p.SwapFeeDenominator == 0 || p.SwapFeeNumerator >= p.SwapFeeDenominator {
+		return cosmath.Int{}, cosmath.Int{}, fmt.Errorf("invalid swap fee %d/%d of pool %s",
+			p.SwapFeeNumerator, p.SwapFeeDenominator, p.PoolId)
+	}
+	return cosmath.NewIntFromUint64(p.SwapFeeNumerator), cosmath.NewIntFromUint64(p.SwapFeeDenominator), nil
+}
+
+// reservesFor returns the output mint and the input and output reserves for a swap from inputMint
+func (p *AMMPool) reservesFor(inputMint string) (outputMint string, reserveIn, reserveOut cosmath.Int) {
 	if inputMint == p.QuoteMint.String() {
-		reserves[0], reserves[1] = reserves[1], reserves[0]
-		mintDecimals[0], mintDecimals[1] = mintDecimals[1], mintDecimals[0]
+		return p.BaseMint.String(), p.QuoteReserve, p.BaseReserve
+	}
+	return p.QuoteMint.String(), p.BaseReserve, p.QuoteReserve
+}
+
+// Quote calculates the expected output amount for a given input amount
+// It takes into account the current pool reserves and fees
+func (p *AMMPool) Quote(
+	ctx context.Context,
+	inputMint string,
+	inputAmount cosmath.Int,
+) (*pkg.QuoteResult, error) {
+	s := p.latest()
+	if s == nil || s.BaseReserve.IsNil() || s.QuoteReserve.IsNil() {
+		return nil, pkg.ErrPoolStateNotLoaded
 	}
+	return s.quote(inputMint, inputAmount)
+}
 
-	reserveIn := reserves[0]
-	reserveOut := reserves[1]
+func (p *AMMPool) quote(inputMint string, inputAmount cosmath.Int) (*pkg.QuoteResult, error) {
+	feeNumerator, feeDenominator, err := p.swapFee()
+	if err != nil {
+		return nil, err
+	}
+	outputMint, reserveIn, reserveOut := p.reservesFor(inputMint)
 
 	// Initialize output values
 	amountOutRaw := cosmath.ZeroInt()
@@ -380,8 +506,8 @@ func (p *AMMPool) Quote(
 
 	// Calculate output amount if input is non-zero
 	if !inputAmount.IsZero() {
-		// Calculate fee based on input amount
-		feeRaw = inputAmount.Mul(LIQUIDITY_FEES_NUMERATOR).Quo(LIQUIDITY_FEES_DENOMINATOR)
+		// Calculate fee based on input amount, rounded up like swap_base_in
+		feeRaw = ceilDiv(inputAmount.Mul(feeNumerator), feeDenominator)
 
 		// Calculate amount after fee
 		amountInWithFee := inputAmount.Sub(feeRaw)
@@ -390,7 +516,74 @@ func (p *AMMPool) Quote(
 		denominator := reserveIn.Add(amountInWithFee)
 		amountOutRaw = reserveOut.Mul(amountInWithFee).Quo(denominator)
 	}
-	return amountOutRaw, nil
+	return constantProductQuote(inputMint, outputMint, inputAmount, amountOutRaw, feeRaw, reserveIn, reserveOut,
+		p.PoolId, p.BaseVault, p.QuoteVault), nil
+}
+
+// QuoteExactOut calculates the input amount needed to receive exactly outputAmount,
+// following the program's swap_base_out rounding
+func (p *AMMPool) QuoteExactOut(
+	ctx context.Context,
+	inputMint string,
+	outputAmount cosmath.Int,
+) (*pkg.QuoteResult, error) {
+	s := p.latest()
+	if s == nil || s.BaseReserve.IsNil() || s.QuoteReserve.IsNil() {
+		return nil, pkg.ErrPoolStateNotLoaded
+	}
+	return s.quoteExactOut(inputMint, outputAmount)
+}
+
+func (p *AMMPool) quoteExactOut(inputMint string, outputAmount cosmath.Int) (*pkg.QuoteResult, error) {
+	feeNumerator, feeDenominator, err := p.swapFee()
+	if err != nil {
+		return nil, err
+	}
+	outputMint, reserveIn, reserveOut := p.reservesFor(inputMint)
+	if outputAmount.GTE(reserveOut) {
+		return nil, fmt.Errorf("insufficient liquidity: output %v exceeds reserve %v", outputAmount, reserveOut)
+	}
+
+	amountIn := cosmath.ZeroInt()
+	feeRaw := cosmath.ZeroInt()
+	if !outputAmount.IsZero() {
+		// Input before fee: reserveIn * amountOut / (reserveOut - amountOut), rounded up
+		amountInWithoutFee := ceilDiv(reserveIn.Mul(outputAmount), reserveOut.Sub(outputAmount))
+
+		// Gross up for the fee, rounded up
+		amountIn = ceilDiv(amountInWithoutFee.Mul(feeDenominator), feeDenominator.Sub(feeNumerator))
+		feeRaw = amountIn.Sub(amountInWithoutFee)
+	}
+	return constantProductQuote(inputMint, outputMint, amountIn, outputAmount, feeRaw, reserveIn, reserveOut,
+		p.PoolId, p.BaseVault, p.QuoteVault), nil
+}
+
+// ceilDiv divides a by b rounding up
+func ceilDiv(a, b cosmath.Int) cosmath.Int {
+	return a.Add(b).Sub(cosmath.OneInt()).Quo(b)
+}
+
+// constantProductQuote builds the quote result of a constant-product swap whose fee is
+// charged on the input and stays in the pool
+func constantProductQuote(
+	inputMint, outputMint string,
+	amountIn, amountOut, fee cosmath.Int,
+	reserveIn, reserveOut cosmath.Int,
+	accounts ...solana.PublicKey,
+) *pkg.QuoteResult {
+	result := &pkg.QuoteResult{
+		InputMint:       inputMint,
+		OutputMint:      outputMint,
+		AmountIn:        amountIn,
+		AmountOut:       amountOut,
+		FeeAmount:       fee,
+		FeeMint:         inputMint,
+		SpotPriceBefore: pkg.SpotPrice(reserveIn, reserveOut),
+		SpotPriceAfter:  pkg.SpotPrice(reserveIn.Add(amountIn), reserveOut.Sub(amountOut)),
+		Accounts:        accounts,
+	}
+	result.SetPriceImpact()
+	return result
 }
 
 // BuildSwapInstructions constructs the necessary instructions for executing a swap
@@ -401,12 +594,28 @@ func (pool *AMMPool) BuildSwapInstructions(
 	user solana.PublicKey,
 	inputMint string,
 	inputAmount cosmath.Int,
-	minOut cosmath.Int,
+	outputAmount cosmath.Int,
+	mode pkg.SwapMode,
 	userBaseAccount solana.PublicKey,
 	userQuoteAccount solana.PublicKey,
 ) ([]solana.Instruction, error) {
+	if pool.MarketBids.IsZero() {
+		return nil, fmt.Errorf("market accounts of amm pool %s are not loaded", pool.PoolId)
+	}
 	instrs := []solana.Instruction{}
 
+	// The AMM V4 program only moves SPL Token mints
+	programs, err := solClient.GetMintTokenPrograms(ctx, pool.BaseMint, pool.QuoteMint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mint token programs: %w", err)
+	}
+	for mint, program := range programs {
+		if program != solana.TokenProgramID {
+			return nil, fmt.Errorf("mint %s of amm pool %s is owned by %s: %w",
+				mint, pool.PoolId, program, sol.ErrUnsupportedTokenProgram)
+		}
+	}
+
 	// Determine input token mint
 	var inputValueMint solana.PublicKey
 	if inputMint == pool.BaseMint.String() {
@@ -425,38 +634,52 @@ func (pool *AMMPool) BuildSwapInstructions(
 		toAccount = userBaseAccount
 	}
 
+	// Set up account metas for the swap instruction, shared by swapBaseIn and swapBaseOut
+	accounts := make(solana.AccountMetaSlice, 18)
+	accounts[0] = solana.NewAccountMeta(solana.TokenProgramID, false, false)
+	accounts[1] = solana.NewAccountMeta(pool.PoolId, true, false)
+	accounts[2] = solana.NewAccountMeta(pool.Authority, false, false)
+	accounts[3] = solana.NewAccountMeta(pool.OpenOrders, true, false)
+	accounts[4] = solana.NewAccountMeta(pool.TargetOrders, true, false)
+	accounts[5] = solana.NewAccountMeta(pool.BaseVault, true, false)
+	accounts[6] = solana.NewAccountMeta(pool.QuoteVault, true, false)
+	accounts[7] = solana.NewAccountMeta(pool.MarketProgramId, false, false)
+	accounts[8] = solana.NewAccountMeta(pool.MarketId, true, false)
+	accounts[9] = solana.NewAccountMeta(pool.MarketBids, true, false)
+	accounts[10] = solana.NewAccountMeta(pool.MarketAsks, true, false)
+	accounts[11] = solana.NewAccountMeta(pool.MarketEventQueue, true, false)
+	accounts[12] = solana.NewAccountMeta(pool.MarketBaseVault, true, false)
+	accounts[13] = solana.NewAccountMeta(pool.MarketQuoteVault, true, false)
+	accounts[14] = solana.NewAccountMeta(pool.MarketAuthority, false, false)
+	accounts[15] = solana.NewAccountMeta(fromAccount, true, false)
+	accounts[16] = solana.NewAccountMeta(toAccount, true, false)
+	accounts[17] = solana.NewAccountMeta(user, true, true)
+
 	// Create swap instruction
-	inst := InSwapInstruction{
-		InAmount:         inputAmount.Uint64(),
-		MinimumOutAmount: minOut.Uint64(),
-		AccountMetaSlice: make(solana.AccountMetaSlice, 18),
-	}
-	inst.BaseVariant = bin.BaseVariant{
-		Impl: inst,
-	}
-
-	// Set up account metas for the swap instruction
-	tokenProgramID := solana.MustPublicKeyFromBase58("TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA")
-	inst.AccountMetaSlice[0] = solana.NewAccountMeta(tokenProgramID, false, false)
-	inst.AccountMetaSlice[1] = solana.NewAccountMeta(pool.PoolId, true, false)
-	inst.AccountMetaSlice[2] = solana.NewAccountMeta(pool.Authority, false, false)
-	inst.AccountMetaSlice[3] = solana.NewAccountMeta(pool.OpenOrders, true, false)
-	inst.AccountMetaSlice[4] = solana.NewAccountMeta(pool.TargetOrders, true, false)
-	inst.AccountMetaSlice[5] = solana.NewAccountMeta(pool.BaseVault, true, false)
-	inst.AccountMetaSlice[6] = solana.NewAccountMeta(pool.QuoteVault, true, false)
-	inst.AccountMetaSlice[7] = solana.NewAccountMeta(pool.MarketProgramId, false, false)
-	inst.AccountMetaSlice[8] = solana.NewAccountMeta(pool.MarketId, true, false)
-	inst.AccountMetaSlice[9] = solana.NewAccountMeta(pool.MarketBids, true, false)
-	inst.AccountMetaSlice[10] = solana.NewAccountMeta(pool.MarketAsks, true, false)
-	inst.AccountMetaSlice[11] = solana.NewAccountMeta(pool.MarketEventQueue, true, false)
-	inst.AccountMetaSlice[12] = solana.NewAccountMeta(pool.MarketBaseVault, true, false)
-	inst.AccountMetaSlice[13] = solana.NewAccountMeta(pool.MarketQuoteVault, true, false)
-	inst.AccountMetaSlice[14] = solana.NewAccountMeta(pool.MarketAuthority, false, false)
-	inst.AccountMetaSlice[15] = solana.NewAccountMeta(fromAccount, true, false)
-	inst.AccountMetaSlice[16] = solana.NewAccountMeta(toAccount, true, false)
-	inst.AccountMetaSlice[17] = solana.NewAccountMeta(user, true, true)
-
-	instrs = append(instrs, &inst)
+	switch mode {
+	case pkg.SwapModeExactIn:
+		inst := InSwapInstruction{
+			InAmount:         inputAmount.Uint64(),
+			MinimumOutAmount: outputAmount.Uint64(),
+			AccountMetaSlice: accounts,
+		}
+		inst.BaseVariant = bin.BaseVariant{
+			Impl: inst,
+		}
+		instrs = append(instrs, &inst)
+	case pkg.SwapModeExactOut:
+		inst := OutSwapInstruction{
+			MaxInAmount:      inputAmount.Uint64(),
+			OutAmount:        outputAmount.Uint64(),
+			AccountMetaSlice: accounts,
+		}
+		inst.BaseVariant = bin.BaseVariant{
+			Impl: inst,
+		}
+		instrs = append(instrs, &inst)
+	default:
+		return nil, fmt.Errorf("unsupported swap mode: %v", mode)
+	}
 	return instrs, nil
 }
 
@@ -499,3 +722,43 @@ func (inst *InSwapInstruction) MarshalWithEncoder(encoder *bin.Encoder) (err err
 	}
 	return nil
 }
+
+type OutSwapInstruction struct {
+	bin.BaseVariant
+	MaxInAmount             uint64
+	OutAmount               uint64
+	solana.AccountMetaSlice `bin:"-" borsh_skip:"true"`
+}
+
+func (inst *OutSwapInstruction) ProgramID() solana.PublicKey {
+	return RAYDIUM_AMM_PROGRAM_ID
+}
+
+func (inst *OutSwapInstruction) Accounts() (out []*solana.AccountMeta) {
+	return inst.Impl.(solana.AccountsGettable).GetAccounts()
+}
+
+func (inst *OutSwapInstruction) Data() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := bin.NewBorshEncoder(buf).Encode(inst); err != nil {
+		return nil, fmt.Errorf("unable to encode instruction: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (inst *OutSwapInstruction) MarshalWithEncoder(encoder *bin.Encoder) (err error) {
+	// SwapBaseOut instruction is number 11
+	err = encoder.WriteUint8(11)
+	if err != nil {
+		return err
+	}
+	err = encoder.WriteUint64(inst.MaxInAmount, binary.LittleEndian)
+	if err != nil {
+		return err
+	}
+	err = encoder.WriteUint64(inst.OutAmount, binary.LittleEndian)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package raydium
+
+import (
+	"encoding/binary"
+
+	cosmath "cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/internal/pooltest"
+)
+
+// ammPoolData returns the account data of an AMM pool of pool's mints and vaults charging swapFeeNumerator/10000
+func ammPoolData(pool *AMMPool, swapFeeNumerator uint64) []byte {
+	data := make([]byte, pool.Span())
+	binary.LittleEndian.PutUint64(data[pool.Offset("SwapFeeNumerator"):], swapFeeNumerator)
+	binary.LittleEndian.PutUint64(data[pool.Offset("SwapFeeDenominator"):], 10000)
+	copy(data[pool.Offset("BaseVault"):], pool.BaseVault[:])
+	copy(data[pool.Offset("QuoteVault"):], pool.QuoteVault[:])
+	copy(data[pool.Offset("BaseMint"):], pool.BaseMint[:])
+	copy(data[pool.Offset("QuoteMint"):], pool.QuoteMint[:])
+	copy(data[pool.Offset("MarketId"):], pool.MarketId[:])
+	return data
+}
+
+// ammFixture returns an AMM pool whose vault balances and fee move every round
+func ammFixture() pooltest.Fixture {
+	pool := &AMMPool{
+		PoolId:     solana.NewWallet().PublicKey(),
+		BaseMint:   solana.NewWallet().PublicKey(),
+		QuoteMint:  solana.NewWallet().PublicKey(),
+		BaseVault:  solana.NewWallet().PublicKey(),
+		QuoteVault: solana.NewWallet().PublicKey(),
+		MarketId:   solana.NewWallet().PublicKey(),
+	}
+	return pooltest.Fixture{
+		Name:   "amm",
+		Pool:   pool,
+		Amount: cosmath.NewInt(1_000_000),
+		Rounds: 500,
+		State: func(i int) []pooltest.Update {
+			return []pooltest.Update{
+				{Account: pool.PoolId, Data: ammPoolData(pool, uint64(25+i%5))},
+				{Account: pool.BaseVault, Data: pooltest.TokenAccountData(uint64(1_000_000_000 + i))},
+				{Account: pool.QuoteVault, Data: pooltest.TokenAccountData(uint64(2_000_000_000 - i))},
+			}
+		},
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"math"
 	"math/big"
 	"strconv"
+	"sync/atomic"
 
 	cosmath "cosmossdk.io/math"
 	bin "github.com/gagliardetto/binary"
@@ -72,6 +73,13 @@ type CLMMPool struct {
 	ExBitmapAddress   solana.PublicKey
 	exTickArrayBitmap *TickArrayBitmapExtensionType
 	TickArrayCache    map[string]TickArray
+	// transferFees holds the Token-2022 transfer fees of the pool's mints
+	transferFees pkg.TransferFees
+
+	// slot is the highest slot of the account data applied with ApplyUpdate
+	slot uint64
+	// snapshot holds the *CLMMPool published by the latest ApplyUpdate
+	snapshot atomic.Value
 }
 
 type RewardInfo struct {
@@ -257,6 +265,8 @@ func (l *CLMMPool) Decode(data []byte) error {
 
 	// Skip padding2
 	offset += 32 * 8
+
+	l.transferFees.Track(l.TokenMint0, l.TokenMint1)
 	return nil
 }
 
@@ -290,11 +300,15 @@ func (p *CLMMPool) BuildSwapInstructions(
 	userAddr solana.PublicKey,
 	inputMint string,
 	amountIn cosmath.Int,
-	minOutAmountWithDecimals cosmath.Int,
+	amountOut cosmath.Int,
+	mode pkg.SwapMode,
 	userBaseAccount solana.PublicKey,
 	userQuoteAccount solana.PublicKey,
 ) ([]solana.Instruction, error) {
-
+	// Build from the published state, which is not modified by concurrent updates
+	if s := p.latest(); s != nil {
+		p = s
+	}
 	instrs := []solana.Instruction{}
 
 	var inputValueMint solana.PublicKey
@@ -309,13 +323,28 @@ func (p *CLMMPool) BuildSwapInstructions(
 		outputValueMint = p.TokenMint0
 	}
 
-	inst := RayCLMMSwapInstruction{
-		Amount:               amountIn.Uint64(),
-		OtherAmountThreshold: minOutAmountWithDecimals.Uint64(),
-		SqrtPriceLimitX64:    uint128.Zero,
-		IsBaseInput:          inputValueMint == p.TokenMint0,
-		AccountMetaSlice:     make(solana.AccountMetaSlice, 16),
+	// IsBaseInput selects which side is fixed: the specified amount is the input
+	// in exact-in mode and the output in exact-out mode
+	var inst RayCLMMSwapInstruction
+	switch mode {
+	case pkg.SwapModeExactIn:
+		inst = RayCLMMSwapInstruction{
+			Amount:               amountIn.Uint64(),
+			OtherAmountThreshold: amountOut.Uint64(),
+			SqrtPriceLimitX64:    uint128.Zero,
+			IsBaseInput:          true,
+		}
+	case pkg.SwapModeExactOut:
+		inst = RayCLMMSwapInstruction{
+			Amount:               amountOut.Uint64(),
+			OtherAmountThreshold: amountIn.Uint64(),
+			SqrtPriceLimitX64:    uint128.Zero,
+			IsBaseInput:          false,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported swap mode: %v", mode)
 	}
+	inst.AccountMetaSlice = make(solana.AccountMetaSlice, 16)
 	inst.BaseVariant = bin.BaseVariant{
 		Impl: inst,
 	}
@@ -431,74 +460,234 @@ func (pool *CLMMPool) GetTokens() (baseMint, quoteMint string) {
 	return pool.TokenMint0.String(), pool.TokenMint1.String()
 }
 
-func (pool *CLMMPool) Quote(ctx context.Context, solClient *sol.Client, inputMint string, inputAmount cosmath.Int) (cosmath.Int, error) {
-	// update pool state first
-	results, err := solClient.GetMultipleAccountsWithOpts(ctx, []solana.PublicKey{pool.ExBitmapAddress})
+// DependentAccounts returns the pool account, its bitmap extension, the accounts of the mints'
+// transfer fees and, once the extension is known, the initialized tick arrays around the current tick
+func (pool *CLMMPool) DependentAccounts() []solana.PublicKey {
+	accounts := []solana.PublicKey{pool.PoolId, pool.ExBitmapAddress}
+	accounts = append(accounts, pool.transferFees.DependentAccounts()...)
+	if pool.exTickArrayBitmap == nil {
+		return accounts
+	}
+	tickArrayAddresses, _ := pool.GetTickArrayAddresses()
+	return append(accounts, tickArrayAddresses...)
+}
+
+// ApplyUpdate applies the data of the pool account, its bitmap extension, one of its mints,
+// the clock sysvar or one of its tick arrays
+func (pool *CLMMPool) ApplyUpdate(account solana.PublicKey, data []byte, slot uint64) error {
+	switch account {
+	case pool.PoolId:
+		if err := pool.Decode(data); err != nil {
+			return fmt.Errorf("failed to decode pool: %w", err)
+		}
+		pool.pruneTickArrays()
+	case pool.ExBitmapAddress:
+		if len(data) < exBitmapAccountSize {
+			return fmt.Errorf("bitmap extension data too short: %d bytes", len(data))
+		}
+		pool.ParseExBitmapInfo(data)
+		pool.pruneTickArrays()
+	case pool.TokenMint0, pool.TokenMint1:
+		if err := pool.transferFees.ApplyMint(account, data); err != nil {
+			return err
+		}
+	case solana.SysVarClockPubkey:
+		clock, err := sol.ParseClock(data)
+		if err != nil {
+			return err
+		}
+		pool.transferFees.SetEpoch(clock.Epoch)
+	default:
+		tickArray := &TickArray{}
+		if err := tickArray.Decode(data); err != nil {
+			return fmt.Errorf("failed to decode tick array: %w", err)
+		}
+		if tickArray.PoolId != pool.PoolId {
+			return fmt.Errorf("account %s is not a dependent account of pool %s", account, pool.PoolId)
+		}
+		// Copy the cache so published snapshots keep their own
+		cache := make(map[string]TickArray, len(pool.TickArrayCache)+1)
+		for key, cached := range pool.TickArrayCache {
+			cache[key] = cached
+		}
+		cache[strconv.FormatInt(int64(tickArray.StartTickIndex), 10)] = *tickArray
+		pool.TickArrayCache = cache
+	}
+	pool.slot = max(pool.slot, slot)
+	pool.publish()
+	return nil
+}
+
+// publish stores a copy of the current state as the pool's snapshot
+func (pool *CLMMPool) publish() {
+	s := *pool
+	s.snapshot = atomic.Value{}
+	s.snapshot.Store(&s)
+	pool.snapshot.Store(&s)
+}
+
+// latest returns the published snapshot, or nil before the first ApplyUpdate
+func (pool *CLMMPool) latest() *CLMMPool {
+	s, _ := pool.snapshot.Load().(*CLMMPool)
+	return s
+}
+
+// Snapshot returns the state published by the latest ApplyUpdate
+func (pool *CLMMPool) Snapshot() pkg.PoolSnapshot {
+	if s := pool.latest(); s != nil {
+		return s
+	}
+	return nil
+}
+
+// Slot returns the highest slot of the account data applied to the pool
+func (pool *CLMMPool) Slot() uint64 {
+	return pool.slot
+}
+
+// pruneTickArrays drops cached tick arrays that are no longer around the current tick,
+// since they stop receiving updates
+func (pool *CLMMPool) pruneTickArrays() {
+	if pool.exTickArrayBitmap == nil || len(pool.TickArrayCache) == 0 {
+		return
+	}
+	current := make(map[string]struct{})
+	for _, startIndex := range pool.getInitializedTickArrayInRange(10) {
+		current[strconv.FormatInt(startIndex, 10)] = struct{}{}
+	}
+	// Build a new cache so published snapshots keep their own
+	cache := make(map[string]TickArray, len(current))
+	for key, cached := range pool.TickArrayCache {
+		if _, ok := current[key]; ok {
+			cache[key] = cached
+		}
+	}
+	pool.TickArrayCache = cache
+}
+
+func (pool *CLMMPool) Quote(ctx context.Context, inputMint string, inputAmount cosmath.Int) (*pkg.QuoteResult, error) {
+	s := pool.latest()
+	if s == nil || s.exTickArrayBitmap == nil || len(s.TickArrayCache) == 0 || !s.transferFees.Loaded() {
+		return nil, pkg.ErrPoolStateNotLoaded
+	}
+	return s.transferFees.QuoteExactIn(inputMint, inputAmount, s.quote)
+}
+
+func (pool *CLMMPool) quote(inputMint string, inputAmount cosmath.Int) (*pkg.QuoteResult, error) {
+	swap, err := pool.computeSwap(inputMint, inputAmount)
 	if err != nil {
-		return cosmath.Int{}, fmt.Errorf("batch request failed: %v", err)
+		return nil, err
 	}
-	for _, result := range results.Value {
-		pool.ParseExBitmapInfo(result.Data.GetBinary())
+	return pool.quoteResult(inputMint, inputAmount, swap.amountCalculated.Neg(), swap), nil
+}
+
+// QuoteExactOut calculates the input amount, including fees, needed to receive exactly outputAmount
+func (pool *CLMMPool) QuoteExactOut(ctx context.Context, inputMint string, outputAmount cosmath.Int) (*pkg.QuoteResult, error) {
+	s := pool.latest()
+	if s == nil || s.exTickArrayBitmap == nil || len(s.TickArrayCache) == 0 || !s.transferFees.Loaded() {
+		return nil, pkg.ErrPoolStateNotLoaded
 	}
+	return s.transferFees.QuoteExactOut(inputMint, outputAmount, s.quoteExactOut)
+}
 
-	tickArrayAddresses, err := pool.GetTickArrayAddresses()
+func (pool *CLMMPool) quoteExactOut(inputMint string, outputAmount cosmath.Int) (*pkg.QuoteResult, error) {
+	// A negative amount tells swapCompute the output side is fixed
+	swap, err := pool.computeSwap(inputMint, outputAmount.Neg())
 	if err != nil {
-		return cosmath.Int{}, fmt.Errorf("get tick array address error: %v", err)
+		return nil, err
 	}
-	results, err = solClient.GetMultipleAccountsWithOpts(ctx, tickArrayAddresses)
+	return pool.quoteResult(inputMint, swap.amountCalculated, outputAmount, swap), nil
+}
+
+// ComputeAmountOutFormat calculates the expected output amount for a given input amount.
+// The output is returned as a negative amount.
+func (pool *CLMMPool) ComputeAmountOutFormat(inputTokenMint string, inputAmount cosmath.Int) (cosmath.Int, error) {
+	swap, err := pool.computeSwap(inputTokenMint, inputAmount)
 	if err != nil {
-		log.Printf("batch request failed: %v", err)
-		return cosmath.Int{}, fmt.Errorf("batch request failed: %v", err)
+		return cosmath.Int{}, err
 	}
-	for _, result := range results.Value {
-		tickArray := &TickArray{}
-		err := tickArray.Decode(result.Data.GetBinary())
-		if err != nil {
-			return cosmath.Int{}, fmt.Errorf("failed to decode tick array: %w", err)
-		}
-		if pool.TickArrayCache == nil {
-			pool.TickArrayCache = make(map[string]TickArray)
-		}
-		pool.TickArrayCache[strconv.FormatInt(int64(tickArray.StartTickIndex), 10)] = *tickArray
+	return swap.amountCalculated, nil
+}
+
+// ComputeAmountInFormat calculates the input amount needed for a given output amount
+func (pool *CLMMPool) ComputeAmountInFormat(inputTokenMint string, outputAmount cosmath.Int) (cosmath.Int, error) {
+	swap, err := pool.computeSwap(inputTokenMint, outputAmount.Neg())
+	if err != nil {
+		return cosmath.Int{}, err
 	}
+	return swap.amountCalculated, nil
+}
 
-	if inputMint == pool.TokenMint0.String() {
-		priceBaseToQuote, err := pool.ComputeAmountOutFormat(pool.TokenMint0.String(), inputAmount)
-		if err != nil {
-			return cosmath.Int{}, err
-		}
-		return priceBaseToQuote.Neg(), nil
-	} else {
-		priceQuoteToBase, err := pool.ComputeAmountOutFormat(pool.TokenMint1.String(), inputAmount)
-		if err != nil {
-			return cosmath.Int{}, err
-		}
-		return priceQuoteToBase.Neg(), nil
+// quoteResult converts a computed swap into a QuoteResult
+func (pool *CLMMPool) quoteResult(inputMint string, amountIn, amountOut cosmath.Int, swap *clmmSwapResult) *pkg.QuoteResult {
+	zeroForOne := inputMint == pool.TokenMint0.String()
+	outputMint := pool.TokenMint0.String()
+	if zeroForOne {
+		outputMint = pool.TokenMint1.String()
+	}
+
+	accounts := []solana.PublicKey{pool.PoolId, pool.TokenVault0, pool.TokenVault1, pool.ObservationKey, pool.ExBitmapAddress}
+	accounts = append(accounts, swap.tickArrays...)
+
+	result := &pkg.QuoteResult{
+		InputMint:       inputMint,
+		OutputMint:      outputMint,
+		AmountIn:        amountIn,
+		AmountOut:       amountOut,
+		FeeAmount:       swap.feeAmount,
+		FeeMint:         inputMint,
+		SpotPriceBefore: sqrtPriceX64ToPrice(pool.SqrtPriceX64.Big(), zeroForOne),
+		SpotPriceAfter:  sqrtPriceX64ToPrice(swap.sqrtPriceX64.BigInt(), zeroForOne),
+		TicksCrossed:    swap.ticksCrossed,
+		Accounts:        accounts,
 	}
+	result.SetPriceImpact()
+	return result
 }
 
-// ComputeAmountOutFormat calculates the expected output amount for a given input amount
-func (pool *CLMMPool) ComputeAmountOutFormat(inputTokenMint string, inputAmount cosmath.Int) (cosmath.Int, error) {
+// sqrtPriceX64ToPrice converts a Q64.64 square root price to the price of the input token
+// in output token units
+func sqrtPriceX64ToPrice(sqrtPriceX64 *big.Int, zeroForOne bool) cosmath.LegacyDec {
+	price := pkg.DecFromFixedPoint(new(big.Int).Mul(sqrtPriceX64, sqrtPriceX64), 128)
+	if zeroForOne {
+		return price
+	}
+	return pkg.InvertPrice(price)
+}
+
+// clmmSwapResult is the outcome of swapCompute
+type clmmSwapResult struct {
+	// amountCalculated is the negated output for exact input, or the input including fees for exact output
+	amountCalculated cosmath.Int
+	feeAmount        cosmath.Int
+	sqrtPriceX64     cosmath.Int
+	ticksCrossed     int
+	tickArrays       []solana.PublicKey
+}
+
+// computeSwap runs swapCompute from the first initialized tick array in the swap direction
+func (pool *CLMMPool) computeSwap(inputTokenMint string, amountSpecified cosmath.Int) (*clmmSwapResult, error) {
 	zeroForOne := inputTokenMint == pool.TokenMint0.String()
 
-	firstTickArrayStartIndex, _, err := pool.getFirstInitializedTickArray(zeroForOne, pool.exTickArrayBitmap)
+	firstTickArrayStartIndex, firstTickArray, err := pool.getFirstInitializedTickArray(zeroForOne, pool.exTickArrayBitmap)
 	if err != nil {
-		return cosmath.Int{}, fmt.Errorf("failed to get first initialized tick array: %w", err)
+		return nil, fmt.Errorf("failed to get first initialized tick array: %w", err)
 	}
 
-	expectedAmountOut, err := pool.swapCompute(
+	swap, err := pool.swapCompute(
 		int64(pool.TickCurrent),
 		zeroForOne,
-		inputAmount,
+		amountSpecified,
 		cosmath.NewIntFromUint64(uint64(pool.FeeRate)),
 		firstTickArrayStartIndex,
 		pool.exTickArrayBitmap,
 	)
 	if err != nil {
-		return cosmath.Int{}, fmt.Errorf("failed to compute swap amount: %w", err)
+		return nil, fmt.Errorf("failed to compute swap amount: %w", err)
 	}
+	swap.tickArrays = append([]solana.PublicKey{firstTickArray}, swap.tickArrays...)
 
-	return expectedAmountOut, nil
+	return swap, nil
 }
 
 // swapCompute performs the core swap calculation logic
@@ -509,9 +698,9 @@ func (pool *CLMMPool) swapCompute(
 	fee cosmath.Int,
 	lastSavedTickArrayStartIndex int64,
 	exTickArrayBitmap *TickArrayBitmapExtensionType,
-) (cosmath.Int, error) {
+) (*clmmSwapResult, error) {
 	if amountSpecified.IsZero() {
-		return cosmath.Int{}, errors.New("input amount cannot be zero")
+		return nil, errors.New("input amount cannot be zero")
 	}
 
 	baseInput := amountSpecified.IsPositive()
@@ -523,6 +712,8 @@ func (pool *CLMMPool) swapCompute(
 	amountIn := cosmath.NewInt(0)
 	amountOut := cosmath.NewInt(0)
 	feeAmount := cosmath.NewInt(0)
+	totalFeeAmount := cosmath.NewInt(0)
+	ticksCrossed := 0
 	sqrtPriceX64 := cosmath.NewIntFromBigInt(pool.SqrtPriceX64.Big())
 	tick := int64(0)
 
@@ -537,14 +728,13 @@ func (pool *CLMMPool) swapCompute(
 		tick = lastSavedTickArrayStartIndex
 	}
 
-	// Initialize accounts and liquidity
-	accounts := make([]*solana.PublicKey, 0)
+	// Initialize accounts and liquidity; accounts collects the tick arrays entered after the first one
+	accounts := make([]solana.PublicKey, 0)
 	liquidity := cosmath.NewIntFromBigInt(pool.Liquidity.Big())
-	tickAarrayStartIndex := lastSavedTickArrayStartIndex
 	tickArrayCurrent := pool.TickArrayCache[strconv.FormatInt(lastSavedTickArrayStartIndex, 10)]
 
 	// Set price limits based on direction
-	if baseInput {
+	if zeroForOne {
 		sqrtPriceLimitX64 = MIN_SQRT_PRICE_X64.Add(cosmath.NewInt(1))
 	} else {
 		sqrtPriceLimitX64 = MAX_SQRT_PRICE_X64.Sub(cosmath.NewInt(1))
@@ -562,7 +752,6 @@ func (pool *CLMMPool) swapCompute(
 		tickState := getNextInitTick(&tickArrayCurrent, tick, int64(pool.TickSpacing), zeroForOne, t)
 
 		nextInitTick := tickState
-		tickArrayAddress := &solana.PublicKey{}
 
 		// Handle liquidity crossing
 		if nextInitTick == nil || nextInitTick.LiquidityGross.Big().Cmp(big.NewInt(0)) <= 0 {
@@ -574,30 +763,26 @@ func (pool *CLMMPool) swapCompute(
 				zeroForOne,
 			)
 			if err != nil {
-				return cosmath.Int{}, fmt.Errorf("failed to get next initialized tick array: %w", err)
+				return nil, fmt.Errorf("failed to get next initialized tick array: %w", err)
 			}
 			if !isExist {
-				return cosmath.Int{}, errors.New("insufficient liquidity")
+				return nil, errors.New("insufficient liquidity")
 			}
 
 			tickAarrayStartIndex := nextInitTickArrayIndex
 			expectedNextTickArrayAddress := getPdaTickArrayAddress(RAYDIUM_CLMM_PROGRAM_ID, pool.PoolId, tickAarrayStartIndex)
 
-			tickArrayAddress = &expectedNextTickArrayAddress
+			accounts = append(accounts, expectedNextTickArrayAddress)
 			tickArrayCurrent = pool.TickArrayCache[strconv.FormatInt(tickAarrayStartIndex, 10)]
 			nextInitTick, err = firstInitializedTick(&tickArrayCurrent, zeroForOne)
 			if err != nil {
-				return cosmath.Int{}, fmt.Errorf("failed to get first initialized tick: %w", err)
+				return nil, fmt.Errorf("failed to get first initialized tick: %w", err)
 			}
 		}
 
 		// Calculate next tick and price
 		tickNext := int64(nextInitTick.Tick)
 		initialized := nextInitTick.LiquidityGross.Big().Cmp(big.NewInt(0)) > 0
-		if lastSavedTickArrayStartIndex != tickAarrayStartIndex && tickArrayAddress != nil {
-			accounts = append(accounts, tickArrayAddress)
-			lastSavedTickArrayStartIndex = tickAarrayStartIndex
-		}
 
 		// Clamp tick to valid range
 		if tickNext < MIN_TICK {
@@ -608,7 +793,7 @@ func (pool *CLMMPool) swapCompute(
 
 		sqrtPriceNextX64, err := getSqrtPriceX64FromTick(int64(tickNext))
 		if err != nil {
-			return cosmath.Int{}, fmt.Errorf("failed to get sqrt price from tick: %w", err)
+			return nil, fmt.Errorf("failed to get sqrt price from tick: %w", err)
 		}
 
 		// Calculate target price
@@ -631,6 +816,7 @@ func (pool *CLMMPool) swapCompute(
 		)
 
 		// Update amounts
+		totalFeeAmount = totalFeeAmount.Add(feeAmount)
 		if baseInput {
 			amountSpecifiedRemaining = amountSpecifiedRemaining.Sub(amountIn.Add(feeAmount))
 			amountCalculated = amountCalculated.Sub(amountOut)
@@ -642,6 +828,7 @@ func (pool *CLMMPool) swapCompute(
 		// Update liquidity and tick
 		if sqrtPriceX64.Equal(sqrtPriceNextX64) {
 			if initialized {
+				ticksCrossed++
 				liquidityNet := nextInitTick.LiquidityNet
 				if zeroForOne {
 					liquidityNet = -liquidityNet
@@ -657,7 +844,7 @@ func (pool *CLMMPool) swapCompute(
 		} else if sqrtPriceX64 != sqrtPriceStartX64 {
 			_T, err := getTickFromSqrtPriceX64(sqrtPriceX64)
 			if err != nil {
-				return cosmath.Int{}, fmt.Errorf("failed to get tick from sqrt price: %w", err)
+				return nil, fmt.Errorf("failed to get tick from sqrt price: %w", err)
 			}
 			t = _T != tick && !zeroForOne && int64(tickArrayCurrent.StartTickIndex) == _T
 			tick = _T
@@ -666,11 +853,17 @@ func (pool *CLMMPool) swapCompute(
 		// Safety check for infinite loops
 		loop++
 		if loop > 100 {
-			return cosmath.Int{}, errors.New("swap computation exceeded maximum iterations")
+			return nil, errors.New("swap computation exceeded maximum iterations")
 		}
 	}
 
-	return amountCalculated, nil
+	return &clmmSwapResult{
+		amountCalculated: amountCalculated,
+		feeAmount:        totalFeeAmount,
+		sqrtPriceX64:     sqrtPriceX64,
+		ticksCrossed:     ticksCrossed,
+		tickArrays:       accounts,
+	}, nil
 }
 
 // GetRemainAccounts returns the remaining accounts needed for the swap
@@ -679,6 +872,9 @@ func (pool *CLMMPool) GetRemainAccounts(
 	client *sol.Client,
 	inputTokenMint string,
 ) ([]solana.PublicKey, error) {
+	if s := pool.latest(); s != nil {
+		pool = s
+	}
 	// Determine swap direction
 	zeroForOne := inputTokenMint == pool.TokenMint0.String()
 
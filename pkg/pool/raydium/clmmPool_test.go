@@ -0,0 +1,80 @@
+package raydium
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	cosmath "cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/internal/pooltest"
+)
+
+// clmmPoolData returns the account data of a CLMM pool of pool's mints with tick spacing 10 at tick 5,
+// whose price is sqrtPrice, with liquidity and the tick arrays starting at -600 and 0 initialized
+func clmmPoolData(pool *CLMMPool, sqrtPrice cosmath.Int, liquidity uint64) []byte {
+	data := make([]byte, 1544)
+	copy(data[73:], pool.TokenMint0[:])
+	copy(data[105:], pool.TokenMint1[:])
+	copy(data[137:], pool.TokenVault0[:])
+	copy(data[169:], pool.TokenVault1[:])
+	binary.LittleEndian.PutUint16(data[235:], 10)
+	binary.LittleEndian.PutUint64(data[237:], liquidity)
+	sqrtPrice.BigInt().FillBytes(data[253:269])
+	slices.Reverse(data[253:269])
+	binary.LittleEndian.PutUint32(data[269:], 5)
+	// The bit of a tick array is its start index over the ticks of an array, offset by 512
+	binary.LittleEndian.PutUint64(data[904+7*8:], 1<<63)
+	binary.LittleEndian.PutUint64(data[904+8*8:], 1)
+	return data
+}
+
+// clmmTickArrayData returns the account data of pool's tick array at startIndex with every tick
+// initialized without net liquidity
+func clmmTickArrayData(pool *CLMMPool, startIndex int32, liquidityGross uint64) []byte {
+	data := make([]byte, 10240)
+	copy(data[8:], pool.PoolId[:])
+	binary.LittleEndian.PutUint32(data[40:], uint32(startIndex))
+	for i := 0; i < TICK_ARRAY_SIZE; i++ {
+		tick := data[44+i*168:]
+		binary.LittleEndian.PutUint32(tick, uint32(startIndex+int32(i)*10))
+		binary.LittleEndian.PutUint64(tick[20:], liquidityGross)
+	}
+	data[44+TICK_ARRAY_SIZE*168] = TICK_ARRAY_SIZE
+	return data
+}
+
+// clmmFixture returns a CLMM pool whose liquidity moves every round
+func clmmFixture(t *testing.T) pooltest.Fixture {
+	pool := &CLMMPool{
+		PoolId:          solana.NewWallet().PublicKey(),
+		ExBitmapAddress: solana.NewWallet().PublicKey(),
+		TokenMint0:      solana.NewWallet().PublicKey(),
+		TokenMint1:      solana.NewWallet().PublicKey(),
+		TokenVault0:     solana.NewWallet().PublicKey(),
+		TokenVault1:     solana.NewWallet().PublicKey(),
+		FeeRate:         2500,
+	}
+	sqrtPrice, err := getSqrtPriceX64FromTick(5)
+	if err != nil {
+		t.Fatalf("getSqrtPriceX64FromTick: %v", err)
+	}
+	lowerTickArray := getPdaTickArrayAddress(RAYDIUM_CLMM_PROGRAM_ID, pool.PoolId, -600)
+	upperTickArray := getPdaTickArrayAddress(RAYDIUM_CLMM_PROGRAM_ID, pool.PoolId, 0)
+	return pooltest.Fixture{
+		Name:   "clmm",
+		Pool:   pool,
+		Amount: cosmath.NewInt(1_000_000),
+		Rounds: 200,
+		State: func(i int) []pooltest.Update {
+			return []pooltest.Update{
+				{Account: pool.PoolId, Data: clmmPoolData(pool, sqrtPrice, uint64(1_000_000_000_000+i))},
+				{Account: pool.ExBitmapAddress, Data: make([]byte, exBitmapAccountSize)},
+				{Account: pool.TokenMint0, Data: pooltest.MintData(6)},
+				{Account: pool.TokenMint1, Data: pooltest.MintData(9)},
+				{Account: lowerTickArray, Data: clmmTickArrayData(pool, -600, uint64(1+i))},
+				{Account: upperTickArray, Data: clmmTickArrayData(pool, 0, uint64(1+i))},
+			}
+		},
+	}
+}
@@ -144,6 +144,10 @@ func (p *CLMMPool) FetchPoolTickArrays(ctx context.Context, client *rpc.Client)
 	return nil
 }
 
+// exBitmapAccountSize is the size of the bitmap extension data read by ParseExBitmapInfo:
+// discriminator, pool ID and the positive and negative bitmaps
+const exBitmapAccountSize = 8 + 32 + 2*EXTENSION_TICKARRAY_BITMAP_SIZE*64
+
 // ParseExBitmapInfo parses the extended bitmap information
 func (p *CLMMPool) ParseExBitmapInfo(data []byte) {
 	var bitmap TickArrayBitmapExtensionType
@@ -1442,7 +1446,7 @@ func getNextSqrtPriceFromTokenAmountBRoundingDown(
 func mulDivRoundingUp(a, b, denominator *big.Int) *big.Int {
 	numerator := new(big.Int).Mul(a, b)
 	result := new(big.Int).Div(numerator, denominator)
-	if !new(big.Int).Mod(numerator, denominator).IsInt64() {
+	if new(big.Int).Mod(numerator, denominator).Sign() != 0 {
 		result.Add(result, big.NewInt(1))
 	}
 	return result
@@ -0,0 +1,23 @@
+package raydium
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestMulDivRoundingUp(t *testing.T) {
+	for _, tt := range []struct {
+		a, b, denominator, want int64
+	}{
+		{6, 1, 3, 2},
+		{7, 1, 3, 3},
+		{1, 1, 1000, 1},
+		{0, 5, 3, 0},
+		{10, 10, 7, 15},
+	} {
+		got := mulDivRoundingUp(big.NewInt(tt.a), big.NewInt(tt.b), big.NewInt(tt.denominator))
+		if got.Int64() != tt.want {
+			t.Errorf("mulDivRoundingUp(%d, %d, %d) = %v, want %d", tt.a, tt.b, tt.denominator, got, tt.want)
+		}
+	}
+}
@@ -45,6 +45,7 @@ var (
 
 // Seeds and Discriminators
 var (
-	AUTH_SEED                  = "vault_and_lp_mint_auth_seed"
-	SwapBaseInputDiscriminator = []byte{143, 190, 90, 218, 196, 30, 51, 222}
+	AUTH_SEED                   = "vault_and_lp_mint_auth_seed"
+	SwapBaseInputDiscriminator  = []byte{143, 190, 90, 218, 196, 30, 51, 222}
+	SwapBaseOutputDiscriminator = []byte{55, 217, 98, 86, 163, 74, 180, 173}
 )
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"sync/atomic"
 
 	"cosmossdk.io/math"
 	cosmath "cosmossdk.io/math"
@@ -39,15 +40,23 @@ type CPMMPool struct {
 	OpenTime           uint64           // 8 bytes
 	_padding2          [32]uint64       // 256 bytes padding
 
-	PoolId           solana.PublicKey
-	BaseAmount       cosmath.Int
-	QuoteAmount      cosmath.Int
-	BaseReserve      cosmath.Int
-	QuoteReserve     cosmath.Int
-	BaseDecimal      uint64
-	QuoteDecimal     uint64
-	BaseNeedTakePnl  uint64
-	QuoteNeedTakePnl uint64
+	// Runtime fields, not part of the on-chain layout
+	PoolId       solana.PublicKey `bin:"-"`
+	BaseAmount   cosmath.Int      `bin:"-"`
+	QuoteAmount  cosmath.Int      `bin:"-"`
+	BaseReserve  cosmath.Int      `bin:"-"`
+	QuoteReserve cosmath.Int      `bin:"-"`
+	BaseDecimal  uint64           `bin:"-"`
+	QuoteDecimal uint64           `bin:"-"`
+	// TradeFeeRate is the trade fee rate of the pool's amm config, over FEE_RATE_DENOMINATOR
+	TradeFeeRate uint64 `bin:"-"`
+	// transferFees holds the Token-2022 transfer fees of the pool's mints
+	transferFees pkg.TransferFees `bin:"-"`
+
+	// slot is the highest slot of the account data applied with ApplyUpdate
+	slot uint64 `bin:"-"`
+	// snapshot holds the *CPMMPool published by the latest ApplyUpdate
+	snapshot atomic.Value `bin:"-"`
 }
 
 func (pool *CPMMPool) ProtocolName() pkg.ProtocolName {
@@ -64,7 +73,13 @@ func (p *CPMMPool) Decode(data []byte) error {
 	}
 
 	dec := bin.NewBinDecoder(data)
-	return dec.Decode(p)
+	if err := dec.Decode(p); err != nil {
+		return err
+	}
+	p.BaseDecimal = uint64(p.Mint0Decimals)
+	p.QuoteDecimal = uint64(p.Mint1Decimals)
+	p.transferFees.Track(p.Token0Mint, p.Token1Mint)
+	return nil
 }
 
 func (p *CPMMPool) Span() uint64 {
@@ -96,7 +111,8 @@ func (pool *CPMMPool) BuildSwapInstructions(
 	userAddr solana.PublicKey,
 	inputMint string,
 	amountIn math.Int,
-	minOutAmountWithDecimals math.Int,
+	amountOut math.Int,
+	mode pkg.SwapMode,
 	userBaseAccount solana.PublicKey,
 	userQuoteAccount solana.PublicKey,
 ) ([]solana.Instruction, error) {
@@ -110,9 +126,17 @@ func (pool *CPMMPool) BuildSwapInstructions(
 		inputValueMint = pool.Token1Mint
 	}
 
+	if mode != pkg.SwapModeExactIn && mode != pkg.SwapModeExactOut {
+		return nil, fmt.Errorf("unsupported swap mode: %v", mode)
+	}
+	if !sol.IsTokenProgram(pool.Token0Program) || !sol.IsTokenProgram(pool.Token1Program) {
+		return nil, fmt.Errorf("pool %s token programs %s and %s: %w",
+			pool.PoolId, pool.Token0Program, pool.Token1Program, sol.ErrUnsupportedTokenProgram)
+	}
 	swapInst := CPMMSwapInstruction{
+		Mode:             mode,
 		InAmount:         amountIn.Uint64(),
-		MinimumOutAmount: minOutAmountWithDecimals.Uint64(),
+		OutAmount:        amountOut.Uint64(),
 		AccountMetaSlice: make(solana.AccountMetaSlice, 13),
 	}
 	swapInst.BaseVariant = bin.BaseVariant{
@@ -129,35 +153,40 @@ func (pool *CPMMPool) BuildSwapInstructions(
 	swapInst.AccountMetaSlice[2] = solana.NewAccountMeta(pool.AmmConfig, false, false) // amm_config
 	swapInst.AccountMetaSlice[3] = solana.NewAccountMeta(pool.PoolId, true, false)     // pool_state
 	if inputValueMint.String() == pool.Token0Mint.String() {
-		swapInst.AccountMetaSlice[4] = solana.NewAccountMeta(userBaseAccount, true, false)   // input_token_account
-		swapInst.AccountMetaSlice[5] = solana.NewAccountMeta(userQuoteAccount, true, false)  // output_token_account
-		swapInst.AccountMetaSlice[6] = solana.NewAccountMeta(pool.Token0Vault, true, false)  // input_vault
-		swapInst.AccountMetaSlice[7] = solana.NewAccountMeta(pool.Token1Vault, true, false)  // output_vault
-		swapInst.AccountMetaSlice[10] = solana.NewAccountMeta(pool.Token0Mint, false, false) // input_token_mint
-		swapInst.AccountMetaSlice[11] = solana.NewAccountMeta(pool.Token1Mint, false, false) // output_token_mint
+		swapInst.AccountMetaSlice[4] = solana.NewAccountMeta(userBaseAccount, true, false)     // input_token_account
+		swapInst.AccountMetaSlice[5] = solana.NewAccountMeta(userQuoteAccount, true, false)    // output_token_account
+		swapInst.AccountMetaSlice[6] = solana.NewAccountMeta(pool.Token0Vault, true, false)    // input_vault
+		swapInst.AccountMetaSlice[7] = solana.NewAccountMeta(pool.Token1Vault, true, false)    // output_vault
+		swapInst.AccountMetaSlice[8] = solana.NewAccountMeta(pool.Token0Program, false, false) // input_token_program
+		swapInst.AccountMetaSlice[9] = solana.NewAccountMeta(pool.Token1Program, false, false) // output_token_program
+		swapInst.AccountMetaSlice[10] = solana.NewAccountMeta(pool.Token0Mint, false, false)   // input_token_mint
+		swapInst.AccountMetaSlice[11] = solana.NewAccountMeta(pool.Token1Mint, false, false)   // output_token_mint
 
 	} else {
-		swapInst.AccountMetaSlice[4] = solana.NewAccountMeta(userQuoteAccount, true, false)  // input_token_account
-		swapInst.AccountMetaSlice[5] = solana.NewAccountMeta(userBaseAccount, true, false)   // output_token_account
-		swapInst.AccountMetaSlice[6] = solana.NewAccountMeta(pool.Token1Vault, true, false)  // input_vault
-		swapInst.AccountMetaSlice[7] = solana.NewAccountMeta(pool.Token0Vault, true, false)  // output_vault
-		swapInst.AccountMetaSlice[10] = solana.NewAccountMeta(pool.Token1Mint, false, false) // input_token_mint
-		swapInst.AccountMetaSlice[11] = solana.NewAccountMeta(pool.Token0Mint, false, false) // output_token_mint
+		swapInst.AccountMetaSlice[4] = solana.NewAccountMeta(userQuoteAccount, true, false)    // input_token_account
+		swapInst.AccountMetaSlice[5] = solana.NewAccountMeta(userBaseAccount, true, false)     // output_token_account
+		swapInst.AccountMetaSlice[6] = solana.NewAccountMeta(pool.Token1Vault, true, false)    // input_vault
+		swapInst.AccountMetaSlice[7] = solana.NewAccountMeta(pool.Token0Vault, true, false)    // output_vault
+		swapInst.AccountMetaSlice[8] = solana.NewAccountMeta(pool.Token1Program, false, false) // input_token_program
+		swapInst.AccountMetaSlice[9] = solana.NewAccountMeta(pool.Token0Program, false, false) // output_token_program
+		swapInst.AccountMetaSlice[10] = solana.NewAccountMeta(pool.Token1Mint, false, false)   // input_token_mint
+		swapInst.AccountMetaSlice[11] = solana.NewAccountMeta(pool.Token0Mint, false, false)   // output_token_mint
 
 	}
-	swapInst.AccountMetaSlice[8] = solana.NewAccountMeta(solana.TokenProgramID, false, false) // input_token_program
-	swapInst.AccountMetaSlice[9] = solana.NewAccountMeta(solana.TokenProgramID, false, false) // output_token_program
-	swapInst.AccountMetaSlice[12] = solana.NewAccountMeta(pool.ObservationKey, true, false)   // observation_state
+	swapInst.AccountMetaSlice[12] = solana.NewAccountMeta(pool.ObservationKey, true, false) // observation_state
 	instrs = append(instrs, &swapInst)
 
 	return instrs, nil
 }
 
-// CPMMSwapInstruction represents the data for a CPMM swap instruction
+// CPMMSwapInstruction represents the data for a CPMM swap instruction.
+// In SwapModeExactIn it encodes swapBaseInput with InAmount as amount_in and OutAmount as minimum_amount_out;
+// in SwapModeExactOut it encodes swapBaseOutput with InAmount as max_amount_in and OutAmount as amount_out.
 type CPMMSwapInstruction struct {
 	bin.BaseVariant
+	Mode                    pkg.SwapMode
 	InAmount                uint64
-	MinimumOutAmount        uint64
+	OutAmount               uint64
 	solana.AccountMetaSlice `bin:"-" borsh_skip:"true"`
 }
 
@@ -171,16 +200,22 @@ func (inst *CPMMSwapInstruction) Accounts() (out []*solana.AccountMeta) {
 
 func (inst *CPMMSwapInstruction) Data() ([]byte, error) {
 	// Use a single method to encode data to avoid conflicts
-	data := make([]byte, 8+8+8) // discriminator(8) + amount_in(8) + minimum_amount_out(8)
-
-	// Write the correct discriminator for swapBaseInput
-	copy(data[0:8], SwapBaseInputDiscriminator)
-
-	// Write amount_in
-	binary.LittleEndian.PutUint64(data[8:16], inst.InAmount)
-
-	// Write minimum_amount_out
-	binary.LittleEndian.PutUint64(data[16:24], inst.MinimumOutAmount)
+	data := make([]byte, 8+8+8) // discriminator(8) + amount(8) + other_amount(8)
+
+	switch inst.Mode {
+	case pkg.SwapModeExactIn:
+		// swapBaseInput: amount_in, minimum_amount_out
+		copy(data[0:8], SwapBaseInputDiscriminator)
+		binary.LittleEndian.PutUint64(data[8:16], inst.InAmount)
+		binary.LittleEndian.PutUint64(data[16:24], inst.OutAmount)
+	case pkg.SwapModeExactOut:
+		// swapBaseOutput: max_amount_in, amount_out
+		copy(data[0:8], SwapBaseOutputDiscriminator)
+		binary.LittleEndian.PutUint64(data[8:16], inst.InAmount)
+		binary.LittleEndian.PutUint64(data[16:24], inst.OutAmount)
+	default:
+		return nil, fmt.Errorf("unsupported swap mode: %v", inst.Mode)
+	}
 
 	return data, nil
 }
@@ -197,70 +232,159 @@ func getAuthorityPDA() (solana.PublicKey, uint8, error) {
 	return authority, bump, nil
 }
 
-func (pool *CPMMPool) Quote(ctx context.Context, solClient *sol.Client, inputMint string, inputAmount math.Int) (math.Int, error) {
-	// update pool data first
-	accounts := make([]solana.PublicKey, 0)
-	accounts = append(accounts, pool.Token0Vault)
-	accounts = append(accounts, pool.Token1Vault)
-	results, err := solClient.GetMultipleAccountsWithOpts(ctx, accounts)
-	if err != nil {
-		return math.NewInt(0), fmt.Errorf("batch request failed: %v", err)
-	}
-	for i, result := range results.Value {
-		if result == nil {
-			return math.NewInt(0), fmt.Errorf("result is nil, account: %v", accounts[i].String())
+// DependentAccounts returns the pool account, its vaults and the accounts of the mints' transfer fees
+func (pool *CPMMPool) DependentAccounts() []solana.PublicKey {
+	accounts := []solana.PublicKey{pool.PoolId, pool.Token0Vault, pool.Token1Vault}
+	return append(accounts, pool.transferFees.DependentAccounts()...)
+}
+
+// ApplyUpdate applies the data of the pool account, one of its vaults, one of its mints or the clock sysvar
+func (pool *CPMMPool) ApplyUpdate(account solana.PublicKey, data []byte, slot uint64) error {
+	switch account {
+	case pool.PoolId:
+		if err := pool.Decode(data); err != nil {
+			return fmt.Errorf("failed to decode pool: %w", err)
+		}
+	case pool.Token0Vault, pool.Token1Vault:
+		amount, err := sol.ParseTokenAccountAmount(data)
+		if err != nil {
+			return err
 		}
-		accountKey := accounts[i].String()
-		if pool.Token0Vault.String() == accountKey {
-			amountBytes := result.Data.GetBinary()[64:72]
-			amountUint := binary.LittleEndian.Uint64(amountBytes)
-			amount := math.NewIntFromUint64(amountUint)
-			pool.BaseAmount = amount
+		if account == pool.Token0Vault {
+			pool.BaseAmount = math.NewIntFromUint64(amount)
 		} else {
-			amountBytes := result.Data.GetBinary()[64:72]
-			amountUint := binary.LittleEndian.Uint64(amountBytes)
-			amount := math.NewIntFromUint64(amountUint)
-			pool.QuoteAmount = amount
+			pool.QuoteAmount = math.NewIntFromUint64(amount)
+		}
+	case pool.Token0Mint, pool.Token1Mint:
+		if err := pool.transferFees.ApplyMint(account, data); err != nil {
+			return err
 		}
+	case solana.SysVarClockPubkey:
+		clock, err := sol.ParseClock(data)
+		if err != nil {
+			return err
+		}
+		pool.transferFees.SetEpoch(clock.Epoch)
+	default:
+		return fmt.Errorf("account %s is not a dependent account of pool %s", account, pool.PoolId)
 	}
+	pool.slot = max(pool.slot, slot)
+	pool.updateReserves()
+	pool.publish()
+	return nil
+}
 
-	pool.BaseReserve = pool.BaseAmount.Sub(math.NewInt(int64(pool.BaseNeedTakePnl)))
-	pool.QuoteReserve = pool.QuoteAmount.Sub(math.NewInt(int64(pool.QuoteNeedTakePnl)))
+// publish stores a copy of the current state as the pool's snapshot
+func (pool *CPMMPool) publish() {
+	s := *pool
+	s.snapshot = atomic.Value{}
+	s.snapshot.Store(&s)
+	pool.snapshot.Store(&s)
+}
+
+// latest returns the published snapshot, or nil before the first ApplyUpdate
+func (pool *CPMMPool) latest() *CPMMPool {
+	s, _ := pool.snapshot.Load().(*CPMMPool)
+	return s
+}
 
-	// Set reserves based on direction
-	reserves := []math.Int{pool.BaseReserve, pool.QuoteReserve}
-	mintDecimals := []int{int(pool.BaseDecimal), int(pool.QuoteDecimal)}
+// Snapshot returns the state published by the latest ApplyUpdate
+func (pool *CPMMPool) Snapshot() pkg.PoolSnapshot {
+	if s := pool.latest(); s != nil {
+		return s
+	}
+	return nil
+}
 
-	// Determine input side
-	input := "base"
+// Slot returns the highest slot of the account data applied to the pool
+func (pool *CPMMPool) Slot() uint64 {
+	return pool.slot
+}
+
+// updateReserves recomputes the effective reserves once both vault balances are known.
+// Like the program, it leaves out the protocol and fund fees that are kept in the vaults until claimed.
+func (pool *CPMMPool) updateReserves() {
+	if pool.BaseAmount.IsNil() || pool.QuoteAmount.IsNil() {
+		return
+	}
+	pool.BaseReserve = pool.BaseAmount.Sub(math.NewIntFromUint64(pool.ProtocolFeesToken0)).Sub(math.NewIntFromUint64(pool.FundFeesToken0))
+	pool.QuoteReserve = pool.QuoteAmount.Sub(math.NewIntFromUint64(pool.ProtocolFeesToken1)).Sub(math.NewIntFromUint64(pool.FundFeesToken1))
+}
+
+// checkSwappable verifies the reserves and the trade fee rate allow a swap
+func (pool *CPMMPool) checkSwappable(reserveIn, reserveOut math.Int) error {
+	if !reserveIn.IsPositive() || !reserveOut.IsPositive() {
+		return fmt.Errorf("pool %s has no liquidity: reserves %v and %v", pool.PoolId, reserveIn, reserveOut)
+	}
+	if math.NewIntFromUint64(pool.TradeFeeRate).GTE(FEE_RATE_DENOMINATOR) {
+		return fmt.Errorf("invalid trade fee rate %d of pool %s", pool.TradeFeeRate, pool.PoolId)
+	}
+	return nil
+}
+
+// reservesFor returns the output mint and the input and output reserves for a swap from inputMint
+func (pool *CPMMPool) reservesFor(inputMint string) (outputMint string, reserveIn, reserveOut math.Int) {
 	if inputMint == pool.Token1Mint.String() {
-		input = "quote"
+		return pool.Token0Mint.String(), pool.QuoteReserve, pool.BaseReserve
 	}
+	return pool.Token1Mint.String(), pool.BaseReserve, pool.QuoteReserve
+}
 
-	// If input is quote, reverse reserves and decimals
-	if input == "quote" {
-		reserves[0], reserves[1] = reserves[1], reserves[0]
-		mintDecimals[0], mintDecimals[1] = mintDecimals[1], mintDecimals[0]
+func (pool *CPMMPool) Quote(ctx context.Context, inputMint string, inputAmount math.Int) (*pkg.QuoteResult, error) {
+	s := pool.latest()
+	if s == nil || s.BaseReserve.IsNil() || s.QuoteReserve.IsNil() || !s.transferFees.Loaded() {
+		return nil, pkg.ErrPoolStateNotLoaded
 	}
+	return s.transferFees.QuoteExactIn(inputMint, inputAmount, s.quote)
+}
 
-	reserveIn := reserves[0]
-	reserveOut := reserves[1]
+// quote follows the program's swap_base_input: the trade fee is taken from the input,
+// rounded up, and the rest is swapped on the constant product curve, rounded down
+func (pool *CPMMPool) quote(inputMint string, inputAmount math.Int) (*pkg.QuoteResult, error) {
+	outputMint, reserveIn, reserveOut := pool.reservesFor(inputMint)
+	if err := pool.checkSwappable(reserveIn, reserveOut); err != nil {
+		return nil, err
+	}
 
-	// Initialize output values
 	amountOutRaw := math.ZeroInt()
 	feeRaw := math.ZeroInt()
-
-	// If amountIn is not zero, calculate amountOut
 	if !inputAmount.IsZero() {
-		// Calculate fee
-		feeRaw = inputAmount.Mul(LIQUIDITY_FEES_NUMERATOR).Quo(LIQUIDITY_FEES_DENOMINATOR)
+		feeRaw = ceilDiv(inputAmount.Mul(math.NewIntFromUint64(pool.TradeFeeRate)), FEE_RATE_DENOMINATOR)
+		amountInLessFee := inputAmount.Sub(feeRaw)
+		amountOutRaw = reserveOut.Mul(amountInLessFee).Quo(reserveIn.Add(amountInLessFee))
+	}
+	return constantProductQuote(inputMint, outputMint, inputAmount, amountOutRaw, feeRaw, reserveIn, reserveOut,
+		pool.PoolId, pool.Token0Vault, pool.Token1Vault, pool.ObservationKey), nil
+}
+
+// QuoteExactOut calculates the input amount needed to receive exactly outputAmount
+func (pool *CPMMPool) QuoteExactOut(ctx context.Context, inputMint string, outputAmount math.Int) (*pkg.QuoteResult, error) {
+	s := pool.latest()
+	if s == nil || s.BaseReserve.IsNil() || s.QuoteReserve.IsNil() || !s.transferFees.Loaded() {
+		return nil, pkg.ErrPoolStateNotLoaded
+	}
+	return s.transferFees.QuoteExactOut(inputMint, outputAmount, s.quoteExactOut)
+}
 
-		// Calculate amountInWithFee
-		amountInWithFee := inputAmount.Sub(feeRaw)
+// quoteExactOut follows the program's swap_base_output: the input before fee is rounded up
+// on the constant product curve, then grossed up for the trade fee, rounded up again
+func (pool *CPMMPool) quoteExactOut(inputMint string, outputAmount math.Int) (*pkg.QuoteResult, error) {
+	outputMint, reserveIn, reserveOut := pool.reservesFor(inputMint)
+	if err := pool.checkSwappable(reserveIn, reserveOut); err != nil {
+		return nil, err
+	}
+	if outputAmount.GTE(reserveOut) {
+		return nil, fmt.Errorf("insufficient liquidity: output %v exceeds reserve %v", outputAmount, reserveOut)
+	}
 
-		// Calculate output amount using constant product formula
-		denominator := reserveIn.Add(amountInWithFee)
-		amountOutRaw = reserveOut.Mul(amountInWithFee).Quo(denominator)
+	amountIn := math.ZeroInt()
+	feeRaw := math.ZeroInt()
+	if !outputAmount.IsZero() {
+		amountInLessFee := ceilDiv(reserveIn.Mul(outputAmount), reserveOut.Sub(outputAmount))
+		tradeFeeRate := math.NewIntFromUint64(pool.TradeFeeRate)
+		amountIn = ceilDiv(amountInLessFee.Mul(FEE_RATE_DENOMINATOR), FEE_RATE_DENOMINATOR.Sub(tradeFeeRate))
+		feeRaw = ceilDiv(amountIn.Mul(tradeFeeRate), FEE_RATE_DENOMINATOR)
 	}
-	return amountOutRaw, nil
+	return constantProductQuote(inputMint, outputMint, amountIn, outputAmount, feeRaw, reserveIn, reserveOut,
+		pool.PoolId, pool.Token0Vault, pool.Token1Vault, pool.ObservationKey), nil
 }
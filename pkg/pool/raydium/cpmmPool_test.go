@@ -0,0 +1,45 @@
+package raydium
+
+import (
+	"cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/internal/pooltest"
+)
+
+// cpmmPoolData returns the account data of a CPMM pool of pool's mints and vaults
+func cpmmPoolData(pool *CPMMPool) []byte {
+	data := make([]byte, 637)
+	copy(data[8+32*2:], pool.Token0Vault[:])
+	copy(data[8+32*3:], pool.Token1Vault[:])
+	copy(data[pool.Offset("Token0Mint"):], pool.Token0Mint[:])
+	copy(data[pool.Offset("Token1Mint"):], pool.Token1Mint[:])
+	return data
+}
+
+// cpmmFixture returns a CPMM pool whose vault balances and clock move every round
+func cpmmFixture() pooltest.Fixture {
+	pool := &CPMMPool{
+		PoolId:       solana.NewWallet().PublicKey(),
+		Token0Mint:   solana.NewWallet().PublicKey(),
+		Token1Mint:   solana.NewWallet().PublicKey(),
+		Token0Vault:  solana.NewWallet().PublicKey(),
+		Token1Vault:  solana.NewWallet().PublicKey(),
+		TradeFeeRate: 2500,
+	}
+	return pooltest.Fixture{
+		Name:   "cpmm",
+		Pool:   pool,
+		Amount: math.NewInt(1_000_000),
+		Rounds: 500,
+		State: func(i int) []pooltest.Update {
+			return []pooltest.Update{
+				{Account: pool.PoolId, Data: cpmmPoolData(pool)},
+				{Account: pool.Token0Vault, Data: pooltest.TokenAccountData(uint64(1_000_000_000 + i))},
+				{Account: pool.Token1Vault, Data: pooltest.TokenAccountData(uint64(2_000_000_000 - i))},
+				{Account: pool.Token0Mint, Data: pooltest.MintData(6)},
+				{Account: pool.Token1Mint, Data: pooltest.MintData(9)},
+				{Account: solana.SysVarClockPubkey, Data: pooltest.ClockData(uint64(100+i), 500)},
+			}
+		},
+	}
+}
@@ -0,0 +1,11 @@
+package raydium
+
+import (
+	"testing"
+
+	"github.com/solana-zh/solroute/internal/pooltest"
+)
+
+func TestPoolsQuoteDuringUpdates(t *testing.T) {
+	pooltest.QuoteDuringUpdates(t, ammFixture(), clmmFixture(t), cpmmFixture())
+}
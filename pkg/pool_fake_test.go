@@ -0,0 +1,89 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+
+	"cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// fakePool depends on a fixed set of accounts, plus the accounts of next once its first account
+// was applied, the way a CLMM pool learns its tick arrays from the pool state. It records the
+// account data applied to it and cannot be quoted.
+type fakePool struct {
+	id       string
+	protocol ProtocolName
+	data     []byte
+	accounts []solana.PublicKey
+	next     []solana.PublicKey
+	applied  map[solana.PublicKey][]byte
+}
+
+func newFakePool(id string, accounts ...solana.PublicKey) *fakePool {
+	return &fakePool{id: id, accounts: accounts, applied: make(map[solana.PublicKey][]byte)}
+}
+
+func (p *fakePool) ProtocolName() ProtocolName     { return p.protocol }
+func (p *fakePool) GetProgramID() solana.PublicKey { return solana.PublicKey{} }
+func (p *fakePool) GetID() string                  { return p.id }
+func (p *fakePool) GetTokens() (string, string)    { return "", "" }
+func (p *fakePool) Snapshot() PoolSnapshot         { return nil }
+
+func (p *fakePool) DependentAccounts() []solana.PublicKey {
+	if len(p.accounts) > 0 && p.applied[p.accounts[0]] != nil {
+		return append(append([]solana.PublicKey(nil), p.accounts...), p.next...)
+	}
+	return p.accounts
+}
+
+func (p *fakePool) ApplyUpdate(account solana.PublicKey, data []byte, _ uint64) error {
+	p.applied[account] = data
+	return nil
+}
+
+func (p *fakePool) Quote(context.Context, string, math.Int) (*QuoteResult, error) {
+	return nil, ErrPoolStateNotLoaded
+}
+
+func (p *fakePool) QuoteExactOut(context.Context, string, math.Int) (*QuoteResult, error) {
+	return nil, ErrPoolStateNotLoaded
+}
+
+func (p *fakePool) BuildSwapInstructions(context.Context, *sol.Client, solana.PublicKey, string, math.Int, math.Int, SwapMode, solana.PublicKey, solana.PublicKey) ([]solana.Instruction, error) {
+	return nil, fmt.Errorf("fake pool %s cannot swap", p.id)
+}
+
+// fakeProtocol decodes any account into a fakePool holding its data
+type fakeProtocol struct {
+	name ProtocolName
+}
+
+func (p *fakeProtocol) ProtocolName() ProtocolName { return p.name }
+
+func (p *fakeProtocol) FetchPoolsByPair(context.Context, string, string) ([]Pool, error) {
+	return nil, nil
+}
+
+func (p *fakeProtocol) FetchPoolByID(context.Context, string) (Pool, error) {
+	return nil, fmt.Errorf("protocol %s fetches no pools by ID", p.name)
+}
+
+func (p *fakeProtocol) FetchAllPools(context.Context) ([]Pool, error) {
+	return nil, nil
+}
+
+func (p *fakeProtocol) DecodePool(_ context.Context, poolID solana.PublicKey, data []byte) (Pool, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty pool account")
+	}
+	pool := newFakePool(poolID.String())
+	pool.protocol, pool.data = p.name, data
+	return pool, nil
+}
+
+// fakeFactory returns a factory of fakeProtocols named name
+func fakeFactory(name string) ProtocolFactory {
+	return func(*sol.Client) Protocol { return &fakeProtocol{name: ProtocolName(name)} }
+}
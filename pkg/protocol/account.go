@@ -0,0 +1,26 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// fetchPoolAccount fetches the data of the pool account poolID
+func fetchPoolAccount(ctx context.Context, solClient *sol.Client, poolID string) (solana.PublicKey, []byte, error) {
+	poolKey, err := solana.PublicKeyFromBase58(poolID)
+	if err != nil {
+		return solana.PublicKey{}, nil, fmt.Errorf("invalid pool ID: %w", err)
+	}
+
+	account, err := solClient.GetAccountInfoWithOpts(ctx, poolKey)
+	if err != nil {
+		return solana.PublicKey{}, nil, fmt.Errorf("failed to get pool account %s: %w", poolID, err)
+	}
+	if account == nil || account.Value == nil {
+		return solana.PublicKey{}, nil, fmt.Errorf("pool account %s not found", poolID)
+	}
+	return poolKey, account.Value.Data.GetBinary(), nil
+}
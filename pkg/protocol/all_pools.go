@@ -0,0 +1,201 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/solana-zh/solroute/pkg"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+const (
+	// allPoolsShards splits a full program scan by the first byte of a pool mint so
+	// that no single getProgramAccounts response has to carry every pool of a protocol
+	allPoolsShards = 256
+	// allPoolsConcurrency bounds the shard requests in flight for one protocol
+	allPoolsConcurrency = 8
+)
+
+// poolScan describes how to enumerate the pool accounts of a program
+type poolScan struct {
+	protocol  pkg.ProtocolName
+	programID solana.PublicKey
+	// dataSize is the size of a pool account
+	dataSize uint64
+	// mintOffset is the offset of a mint in the pool account, used to shard the scan
+	mintOffset uint64
+}
+
+// accounts returns every pool account of the program
+func (s poolScan) accounts(ctx context.Context, solClient *sol.Client) (rpc.GetProgramAccountsResult, error) {
+	return s.fetch(ctx, solClient, nil)
+}
+
+// ids returns the address of every pool account of the program without its data
+func (s poolScan) ids(ctx context.Context, solClient *sol.Client) ([]solana.PublicKey, error) {
+	var zero uint64
+	accounts, err := s.fetch(ctx, solClient, &rpc.DataSlice{Offset: &zero, Length: &zero})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]solana.PublicKey, 0, len(accounts))
+	for _, account := range accounts {
+		ids = append(ids, account.Pubkey)
+	}
+	return ids, nil
+}
+
+// records returns a record, without static metadata, for every pool account of the program
+func (s poolScan) records(ctx context.Context, solClient *sol.Client) ([]*pkg.PoolRecord, error) {
+	accounts, err := s.accounts(ctx, solClient)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*pkg.PoolRecord, 0, len(accounts))
+	for _, account := range accounts {
+		records = append(records, &pkg.PoolRecord{
+			ID:       account.Pubkey,
+			Protocol: s.protocol,
+			Data:     account.Account.Data.GetBinary(),
+		})
+	}
+	return records, nil
+}
+
+// recordsByID fetches the records of the given pools in batches, skipping accounts that do not
+// exist or are not pools of the program
+func (s poolScan) recordsByID(ctx context.Context, solClient *sol.Client, poolIDs []solana.PublicKey) ([]*pkg.PoolRecord, error) {
+	records := make([]*pkg.PoolRecord, 0, len(poolIDs))
+	for start := 0; start < len(poolIDs); start += sol.MaxMultipleAccounts {
+		end := min(start+sol.MaxMultipleAccounts, len(poolIDs))
+		result, err := solClient.GetMultipleAccountsWithOpts(ctx, poolIDs[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to get multiple accounts: %w", err)
+		}
+		for i, account := range result.Value {
+			if account == nil || !account.Owner.Equals(s.programID) {
+				continue
+			}
+			data := account.Data.GetBinary()
+			if uint64(len(data)) != s.dataSize {
+				continue
+			}
+			records = append(records, &pkg.PoolRecord{
+				ID:       poolIDs[start+i],
+				Protocol: s.protocol,
+				Data:     data,
+			})
+		}
+	}
+	return records, nil
+}
+
+// fetch runs the scan as allPoolsShards getProgramAccounts requests filtered on the first
+// byte of the mint at mintOffset. An account returned by several shards is kept once.
+func (s poolScan) fetch(ctx context.Context, solClient *sol.Client, dataSlice *rpc.DataSlice) (rpc.GetProgramAccountsResult, error) {
+	results := make([]rpc.GetProgramAccountsResult, allPoolsShards)
+	errs := make([]error, allPoolsShards)
+
+	sem := make(chan struct{}, allPoolsConcurrency)
+	var wg sync.WaitGroup
+	for shard := 0; shard < allPoolsShards; shard++ {
+		wg.Add(1)
+		go func(shard int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[shard] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			results[shard], errs[shard] = solClient.GetProgramAccountsWithOpts(ctx, s.programID, &rpc.GetProgramAccountsOpts{
+				DataSlice: dataSlice,
+				Filters: []rpc.RPCFilter{
+					{
+						DataSize: s.dataSize,
+					},
+					{
+						Memcmp: &rpc.RPCFilterMemcmp{
+							Offset: s.mintOffset,
+							Bytes:  solana.Base58{byte(shard)},
+						},
+					},
+				},
+			})
+		}(shard)
+	}
+	wg.Wait()
+
+	accounts := make(rpc.GetProgramAccountsResult, 0)
+	seen := make(map[solana.PublicKey]struct{})
+	for shard, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to get program accounts for shard %d: %w", shard, err)
+		}
+		for _, account := range results[shard] {
+			if account == nil {
+				continue
+			}
+			if _, ok := seen[account.Pubkey]; ok {
+				continue
+			}
+			seen[account.Pubkey] = struct{}{}
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+// fetchMultipleAccounts fetches accounts in batches of sol.MaxMultipleAccounts keys.
+// Accounts that do not exist are left out of the result.
+func fetchMultipleAccounts(ctx context.Context, solClient *sol.Client, keys []solana.PublicKey) (map[solana.PublicKey][]byte, error) {
+	result, err := solClient.GetMultipleAccountsChunked(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make(map[solana.PublicKey][]byte, len(result))
+	for key, account := range result {
+		accounts[key] = account.Data
+	}
+	return accounts, nil
+}
+
+// dropUnresolved leaves out and reports the records whose static metadata could not be
+// resolved, such as AMM pools whose market failed to load. Left out of the store, they are
+// fetched again as new pools by the next reconcile.
+func dropUnresolved(protocol pkg.ProtocolName, records []*pkg.PoolRecord) []*pkg.PoolRecord {
+	resolved := make([]*pkg.PoolRecord, 0, len(records))
+	var dropped []string
+	for _, record := range records {
+		if record.Static == nil {
+			dropped = append(dropped, record.ID.String())
+			continue
+		}
+		resolved = append(resolved, record)
+	}
+	if len(dropped) > 0 {
+		log.Printf("skipping %d %v pools without static metadata: %s", len(dropped), protocol, strings.Join(dropped, ", "))
+	}
+	return resolved
+}
+
+// restorePools restores every record with restore, skipping the ones that fail like
+// FetchPoolsByPair skips pools it cannot decode
+func restorePools(records []*pkg.PoolRecord, restore func(record *pkg.PoolRecord) (pkg.Pool, error)) []pkg.Pool {
+	pools := make([]pkg.Pool, 0, len(records))
+	for _, record := range records {
+		pool, err := restore(record)
+		if err != nil {
+			continue
+		}
+		pools = append(pools, pool)
+	}
+	return pools
+}
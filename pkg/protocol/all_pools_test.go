@@ -0,0 +1,81 @@
+package protocol
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg/pool/raydium"
+)
+
+func TestFetchAllPoolsShardsByMintByte(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeRPC(t)
+
+	ammConfig := solana.NewWallet().PublicKey()
+	fake.setAccount(ammConfig, raydium.RAYDIUM_CPMM_PROGRAM_ID, cpmmAmmConfigData(2500))
+	quoteMint := solana.NewWallet().PublicKey()
+	// One pool per shard: the first byte of its token 0 mint is the shard
+	pools := make(map[string]struct{}, allPoolsShards)
+	var duplicated solana.PublicKey
+	for shard := 0; shard < allPoolsShards; shard++ {
+		mint := solana.NewWallet().PublicKey()
+		mint[0] = byte(shard)
+		pool := solana.NewWallet().PublicKey()
+		fake.setAccount(pool, raydium.RAYDIUM_CPMM_PROGRAM_ID, cpmmPoolData(ammConfig, mint, quoteMint))
+		pools[pool.String()] = struct{}{}
+		duplicated = pool
+	}
+	// Every shard also returns one of the pools
+	fake.everyScan = []solana.PublicKey{duplicated}
+
+	got, err := NewRaydiumCpmm(client).FetchAllPools(ctx)
+	if err != nil {
+		t.Fatalf("FetchAllPools: %v", err)
+	}
+
+	var layout raydium.CPMMPool
+	mintOffset := layout.Offset("Token0Mint")
+	scans := fake.scanFilters()
+	if len(scans) != allPoolsShards {
+		t.Fatalf("getProgramAccounts called %d times, want %d", len(scans), allPoolsShards)
+	}
+	shards := make(map[byte]int)
+	for _, filters := range scans {
+		var dataSize uint64
+		for _, filter := range filters {
+			if filter.DataSize != 0 {
+				dataSize = filter.DataSize
+			}
+			if memcmp := filter.Memcmp; memcmp != nil {
+				if memcmp.Offset != mintOffset || len(memcmp.Bytes) != 1 {
+					t.Errorf("shard filter compares %d bytes at %d, want 1 byte at %d", len(memcmp.Bytes), memcmp.Offset, mintOffset)
+					continue
+				}
+				shards[memcmp.Bytes[0]]++
+			}
+		}
+		if dataSize != 637 {
+			t.Errorf("scan filters on data size %d, want 637", dataSize)
+		}
+	}
+	for shard := 0; shard < allPoolsShards; shard++ {
+		if shards[byte(shard)] != 1 {
+			t.Errorf("shard %d scanned %d times, want once", shard, shards[byte(shard)])
+		}
+	}
+
+	if len(got) != len(pools) {
+		t.Errorf("FetchAllPools returned %d pools, want %d", len(got), len(pools))
+	}
+	seen := make(map[string]bool, len(got))
+	for _, pool := range got {
+		if seen[pool.GetID()] {
+			t.Errorf("pool %s returned twice", pool.GetID())
+		}
+		seen[pool.GetID()] = true
+		if _, ok := pools[pool.GetID()]; !ok {
+			t.Errorf("FetchAllPools returned unknown pool %s", pool.GetID())
+		}
+	}
+}
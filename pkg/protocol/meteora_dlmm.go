@@ -12,6 +12,15 @@ import (
 	"github.com/solana-zh/solroute/pkg/sol"
 )
 
+func init() {
+	pkg.RegisterProtocol(meteora.MeteoraProgramID, func(solClient *sol.Client) pkg.Protocol {
+		return NewMeteoraDlmm(solClient)
+	})
+}
+
+// staticBitmapExtension is the PoolRecord static key of the bin array bitmap extension
+const staticBitmapExtension = "bitmap_extension"
+
 // MeteoraDlmmProtocol handles interactions with Meteora DLMM (Dynamic Liquidity Market Maker) pools
 type MeteoraDlmmProtocol struct {
 	SolClient *sol.Client
@@ -30,30 +39,19 @@ func (protocol *MeteoraDlmmProtocol) ProtocolName() pkg.ProtocolName {
 
 // FetchPoolsByPair retrieves all Meteora DLMM pools for a given token pair
 func (protocol *MeteoraDlmmProtocol) FetchPoolsByPair(ctx context.Context, baseMint string, quoteMint string) ([]pkg.Pool, error) {
-	programAccounts := rpc.GetProgramAccountsResult{}
-
-	// Fetch pools with baseMint as TokenX and quoteMint as TokenY
-	baseQuotePools, err := protocol.getMeteoraDlmmPoolAccountsByTokenPair(ctx, baseMint, quoteMint)
+	// Fetch pools with either mint as TokenX
+	programAccounts, err := fetchPairAccounts(ctx, baseMint, quoteMint, protocol.getMeteoraDlmmPoolAccountsByTokenPair)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch pools with baseMint as TokenX: %w", err)
+		return nil, fmt.Errorf("failed to fetch pools for pair %s/%s: %w", baseMint, quoteMint, err)
 	}
-	programAccounts = append(programAccounts, baseQuotePools...)
 
 	pools := make([]pkg.Pool, 0, len(programAccounts))
 	for _, account := range programAccounts {
-		poolData := &meteora.MeteoraDlmmPool{}
-		if err := poolData.Decode(account.Account.Data.GetBinary()); err != nil {
-			// Skip pools that can't be decoded
+		poolData, err := protocol.DecodePool(ctx, account.Pubkey, account.Account.Data.GetBinary())
+		if err != nil {
+			// Skip pools that can't be decoded or can't get bin arrays
 			continue
 		}
-
-		poolData.PoolId = account.Pubkey
-		if err := poolData.GetBinArrayForSwap(ctx, protocol.SolClient); err != nil {
-			// Skip pools that can't get bin array
-			continue
-		}
-
-		poolData.BitmapExtensionKey, _ = meteora.DeriveBinArrayBitmapExtension(poolData.PoolId)
 		pools = append(pools, poolData)
 	}
 	return pools, nil
@@ -65,7 +63,7 @@ func (protocol *MeteoraDlmmProtocol) getMeteoraDlmmPoolAccountsByTokenPair(ctx c
 	result, err := protocol.SolClient.GetProgramAccountsWithOpts(ctx, meteora.MeteoraProgramID, &rpc.GetProgramAccountsOpts{
 		Filters: []rpc.RPCFilter{
 			{
-				DataSize: 904, // Meteora DLMM pool account size
+				DataSize: meteora.PoolDataSize,
 			},
 			{
 				Memcmp: &rpc.RPCFilterMemcmp{
@@ -87,23 +85,115 @@ func (protocol *MeteoraDlmmProtocol) getMeteoraDlmmPoolAccountsByTokenPair(ctx c
 	return result, nil
 }
 
+// poolScan enumerates the Meteora DLMM pool accounts
+func (protocol *MeteoraDlmmProtocol) poolScan() poolScan {
+	var poolLayout meteora.MeteoraDlmmPool
+	return poolScan{
+		protocol:   pkg.ProtocolNameMeteoraDlmm,
+		programID:  meteora.MeteoraProgramID,
+		dataSize:   meteora.PoolDataSize,
+		mintOffset: poolLayout.Offset("TokenXMint"),
+	}
+}
+
+// FetchAllPools retrieves every Meteora DLMM pool of the program.
+// Bin arrays are not fetched here; the pools list them in DependentAccounts and take them
+// through ApplyUpdate when a refresh or a PoolFeed loads their state.
+func (protocol *MeteoraDlmmProtocol) FetchAllPools(ctx context.Context) ([]pkg.Pool, error) {
+	records, err := protocol.FetchAllPoolRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return restorePools(records, protocol.RestorePool), nil
+}
+
+// FetchAllPoolIDs lists the address of every Meteora DLMM pool
+func (protocol *MeteoraDlmmProtocol) FetchAllPoolIDs(ctx context.Context) ([]solana.PublicKey, error) {
+	ids, err := protocol.poolScan().ids(ctx, protocol.SolClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch all pool IDs: %w", err)
+	}
+	return ids, nil
+}
+
+// FetchAllPoolRecords retrieves the record of every Meteora DLMM pool of the program
+func (protocol *MeteoraDlmmProtocol) FetchAllPoolRecords(ctx context.Context) ([]*pkg.PoolRecord, error) {
+	records, err := protocol.poolScan().records(ctx, protocol.SolClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch all pools: %w", err)
+	}
+	if err := protocol.resolveStatic(ctx, records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// FetchPoolRecords retrieves the records of the given Meteora DLMM pools
+func (protocol *MeteoraDlmmProtocol) FetchPoolRecords(ctx context.Context, poolIDs []solana.PublicKey) ([]*pkg.PoolRecord, error) {
+	records, err := protocol.poolScan().recordsByID(ctx, protocol.SolClient, poolIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pool records: %w", err)
+	}
+	if err := protocol.resolveStatic(ctx, records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// resolveStatic records the bitmap extension key of each pool
+func (protocol *MeteoraDlmmProtocol) resolveStatic(ctx context.Context, records []*pkg.PoolRecord) error {
+	for _, record := range records {
+		bitmapExtensionKey, _ := meteora.DeriveBinArrayBitmapExtension(record.ID)
+		record.Static = map[string]string{staticBitmapExtension: bitmapExtensionKey.String()}
+	}
+	return nil
+}
+
+// RestorePool decodes a Meteora DLMM pool from its record without loading bin arrays
+func (protocol *MeteoraDlmmProtocol) RestorePool(record *pkg.PoolRecord) (pkg.Pool, error) {
+	poolData, err := decodeDlmmPool(record.ID, record.Data)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := record.Static[staticBitmapExtension]; ok {
+		bitmapExtensionKey, err := solana.PublicKeyFromBase58(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bitmap extension key for %s: %w", record.ID, err)
+		}
+		poolData.BitmapExtensionKey = bitmapExtensionKey
+	}
+	return poolData, nil
+}
+
 // FetchPoolByID retrieves a specific Meteora DLMM pool by its ID
 func (protocol *MeteoraDlmmProtocol) FetchPoolByID(ctx context.Context, poolID string) (pkg.Pool, error) {
-	poolData := &meteora.MeteoraDlmmPool{}
-	account, err := protocol.SolClient.GetAccountInfoWithOpts(ctx, solana.MustPublicKeyFromBase58(poolID))
+	poolKey, data, err := fetchPoolAccount(ctx, protocol.SolClient, poolID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pool account: %w", err)
+		return nil, err
 	}
+	return protocol.DecodePool(ctx, poolKey, data)
+}
 
-	if err := poolData.Decode(account.Value.Data.GetBinary()); err != nil {
-		return nil, fmt.Errorf("failed to decode pool data: %w", err)
+// DecodePool decodes Meteora DLMM pool account data and loads the bin arrays around the active bin
+func (protocol *MeteoraDlmmProtocol) DecodePool(ctx context.Context, poolID solana.PublicKey, data []byte) (pkg.Pool, error) {
+	poolData, err := decodeDlmmPool(poolID, data)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := poolData.GetBinArrayForSwap(ctx, protocol.SolClient); err != nil {
 		return nil, fmt.Errorf("failed to get bin array for swap: %w", err)
 	}
+	return poolData, nil
+}
 
-	bitmapExtensionKey, _ := meteora.DeriveBinArrayBitmapExtension(poolData.PoolId)
-	poolData.BitmapExtensionKey = bitmapExtensionKey
+// decodeDlmmPool decodes pool account data without fetching any other account
+func decodeDlmmPool(poolID solana.PublicKey, data []byte) (*meteora.MeteoraDlmmPool, error) {
+	poolData := &meteora.MeteoraDlmmPool{}
+	if err := poolData.Decode(data); err != nil {
+		return nil, fmt.Errorf("failed to decode pool data: %w", err)
+	}
+	poolData.PoolId = poolID
+	poolData.BitmapExtensionKey, _ = meteora.DeriveBinArrayBitmapExtension(poolData.PoolId)
 	return poolData, nil
 }
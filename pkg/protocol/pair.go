@@ -0,0 +1,57 @@
+package protocol
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// pairAccountsFetcher returns the program accounts of pools whose first mint is mint0
+// and second mint is mint1
+type pairAccountsFetcher func(ctx context.Context, mint0, mint1 string) (rpc.GetProgramAccountsResult, error)
+
+// fetchPairAccounts runs fetch for both mint orientations of a pair concurrently and
+// merges the results, dropping accounts returned by both queries.
+// Pools store their mints in on-chain order, so a single orientation misses the pools
+// created with the mints the other way round.
+func fetchPairAccounts(ctx context.Context, mintA, mintB string, fetch pairAccountsFetcher) (rpc.GetProgramAccountsResult, error) {
+	orientations := [][2]string{{mintA, mintB}}
+	if mintA != mintB {
+		orientations = append(orientations, [2]string{mintB, mintA})
+	}
+
+	results := make([]rpc.GetProgramAccountsResult, len(orientations))
+	errs := make([]error, len(orientations))
+	var wg sync.WaitGroup
+	for i, orientation := range orientations {
+		wg.Add(1)
+		go func(i int, mint0, mint1 string) {
+			defer wg.Done()
+			results[i], errs[i] = fetch(ctx, mint0, mint1)
+		}(i, orientation[0], orientation[1])
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]struct{})
+	accounts := make(rpc.GetProgramAccountsResult, 0)
+	for _, result := range results {
+		for _, account := range result {
+			if account == nil {
+				continue
+			}
+			if _, ok := seen[account.Pubkey.String()]; ok {
+				continue
+			}
+			seen[account.Pubkey.String()] = struct{}{}
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
@@ -0,0 +1,98 @@
+package protocol
+
+import (
+	"context"
+	"encoding/binary"
+	"sort"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg/pool/raydium"
+)
+
+// cpmmPoolData returns the account data of a CPMM pool of mint0 and mint1 using ammConfig
+func cpmmPoolData(ammConfig, mint0, mint1 solana.PublicKey) []byte {
+	var layout raydium.CPMMPool
+	data := make([]byte, 637)
+	copy(data[8:], ammConfig[:])
+	copy(data[layout.Offset("Token0Mint"):], mint0[:])
+	copy(data[layout.Offset("Token1Mint"):], mint1[:])
+	return data
+}
+
+// cpmmAmmConfigData returns the account data of a CPMM amm config charging tradeFeeRate
+func cpmmAmmConfigData(tradeFeeRate uint64) []byte {
+	// discriminator, bump, disable create pool and index precede the trade fee rate
+	data := make([]byte, 8+4+8*4+32*2+8*16)
+	binary.LittleEndian.PutUint64(data[8+4:], tradeFeeRate)
+	return data
+}
+
+func TestFetchPoolsByPairFindsBothOrientations(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeRPC(t)
+
+	mintA := solana.NewWallet().PublicKey()
+	mintB := solana.NewWallet().PublicKey()
+	ammConfig := solana.NewWallet().PublicKey()
+	fake.setAccount(ammConfig, raydium.RAYDIUM_CPMM_PROGRAM_ID, cpmmAmmConfigData(2500))
+
+	poolAB := solana.NewWallet().PublicKey()
+	poolBA := solana.NewWallet().PublicKey()
+	fake.setAccount(poolAB, raydium.RAYDIUM_CPMM_PROGRAM_ID, cpmmPoolData(ammConfig, mintA, mintB))
+	fake.setAccount(poolBA, raydium.RAYDIUM_CPMM_PROGRAM_ID, cpmmPoolData(ammConfig, mintB, mintA))
+	// A pool of another pair is not returned
+	other := solana.NewWallet().PublicKey()
+	fake.setAccount(solana.NewWallet().PublicKey(), raydium.RAYDIUM_CPMM_PROGRAM_ID, cpmmPoolData(ammConfig, mintA, other))
+
+	want := []string{poolAB.String(), poolBA.String()}
+	sort.Strings(want)
+	protocol := NewRaydiumCpmm(client)
+	for _, pair := range [][2]solana.PublicKey{{mintA, mintB}, {mintB, mintA}} {
+		pools, err := protocol.FetchPoolsByPair(ctx, pair[0].String(), pair[1].String())
+		if err != nil {
+			t.Fatalf("FetchPoolsByPair(%s, %s): %v", pair[0], pair[1], err)
+		}
+		got := make([]string, len(pools))
+		for i, pool := range pools {
+			got[i] = pool.GetID()
+			if fee := pool.(*raydium.CPMMPool).TradeFeeRate; fee != 2500 {
+				t.Errorf("pool %s has trade fee rate %d, want 2500", pool.GetID(), fee)
+			}
+		}
+		sort.Strings(got)
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("FetchPoolsByPair(%s, %s) = %v, want %v", pair[0], pair[1], got, want)
+		}
+	}
+}
+
+func TestFetchPoolsByPairDropsDuplicates(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeRPC(t)
+
+	mintA := solana.NewWallet().PublicKey()
+	mintB := solana.NewWallet().PublicKey()
+	ammConfig := solana.NewWallet().PublicKey()
+	fake.setAccount(ammConfig, raydium.RAYDIUM_CPMM_PROGRAM_ID, cpmmAmmConfigData(2500))
+
+	pool := solana.NewWallet().PublicKey()
+	fake.setAccount(pool, raydium.RAYDIUM_CPMM_PROGRAM_ID, cpmmPoolData(ammConfig, mintA, mintB))
+	// Both orientations return the pool
+	fake.everyScan = []solana.PublicKey{pool}
+
+	pools, err := NewRaydiumCpmm(client).FetchPoolsByPair(ctx, mintA.String(), mintB.String())
+	if err != nil {
+		t.Fatalf("FetchPoolsByPair: %v", err)
+	}
+	if got := fake.callCount("getProgramAccounts"); got != 2 {
+		t.Errorf("getProgramAccounts called %d times, want once per orientation", got)
+	}
+	if len(pools) != 1 || pools[0].GetID() != pool.String() {
+		ids := make([]string, len(pools))
+		for i, p := range pools {
+			ids[i] = p.GetID()
+		}
+		t.Errorf("FetchPoolsByPair = %v, want [%s] once", ids, pool)
+	}
+}
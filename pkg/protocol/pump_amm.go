@@ -11,6 +11,12 @@ import (
 	"github.com/solana-zh/solroute/pkg/sol"
 )
 
+func init() {
+	pkg.RegisterProtocol(pump.PumpSwapProgramID, func(solClient *sol.Client) pkg.Protocol {
+		return NewPumpAmm(solClient)
+	})
+}
+
 type PumpAmmProtocol struct {
 	SolClient *sol.Client
 }
@@ -26,20 +32,17 @@ func (p *PumpAmmProtocol) ProtocolName() pkg.ProtocolName {
 }
 
 func (p *PumpAmmProtocol) FetchPoolsByPair(ctx context.Context, baseMint string, quoteMint string) ([]pkg.Pool, error) {
-	programAccounts := rpc.GetProgramAccountsResult{}
-	data, err := p.getPumpAMMPoolAccountsByTokenPair(ctx, baseMint, quoteMint)
+	programAccounts, err := fetchPairAccounts(ctx, baseMint, quoteMint, p.getPumpAMMPoolAccountsByTokenPair)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch pools with base token %s: %w", baseMint, err)
+		return nil, fmt.Errorf("failed to fetch pools for pair %s/%s: %w", baseMint, quoteMint, err)
 	}
-	programAccounts = append(programAccounts, data...)
 
 	res := make([]pkg.Pool, 0)
 	for _, v := range programAccounts {
-		layout, err := pump.ParsePoolData(v.Account.Data.GetBinary())
+		layout, err := p.DecodePool(ctx, v.Pubkey, v.Account.Data.GetBinary())
 		if err != nil {
 			continue
 		}
-		layout.PoolId = v.Pubkey
 		res = append(res, layout)
 	}
 	return res, nil
@@ -77,21 +80,72 @@ func (p *PumpAmmProtocol) getPumpAMMPoolAccountsByTokenPair(ctx context.Context,
 	})
 }
 
-func (p *PumpAmmProtocol) FetchPoolByID(ctx context.Context, poolId string) (pkg.Pool, error) {
-	poolPubkey, err := solana.PublicKeyFromBase58(poolId)
+// poolScan enumerates the PumpSwap pool accounts
+func (p *PumpAmmProtocol) poolScan() poolScan {
+	var layout pump.PumpAMMPool
+	return poolScan{
+		protocol:   pkg.ProtocolNamePumpAmm,
+		programID:  pump.PumpSwapProgramID,
+		dataSize:   layout.Span(),
+		mintOffset: layout.Offset("BaseMint"),
+	}
+}
+
+// FetchAllPools retrieves every PumpSwap pool of the program
+func (p *PumpAmmProtocol) FetchAllPools(ctx context.Context) ([]pkg.Pool, error) {
+	records, err := p.FetchAllPoolRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return restorePools(records, p.RestorePool), nil
+}
+
+// FetchAllPoolIDs lists the address of every PumpSwap pool
+func (p *PumpAmmProtocol) FetchAllPoolIDs(ctx context.Context) ([]solana.PublicKey, error) {
+	ids, err := p.poolScan().ids(ctx, p.SolClient)
 	if err != nil {
-		return nil, fmt.Errorf("invalid pool ID: %w", err)
+		return nil, fmt.Errorf("failed to fetch all pool IDs: %w", err)
 	}
+	return ids, nil
+}
 
-	account, err := p.SolClient.GetAccountInfoWithOpts(ctx, poolPubkey)
+// FetchAllPoolRecords retrieves the record of every PumpSwap pool of the program
+func (p *PumpAmmProtocol) FetchAllPoolRecords(ctx context.Context) ([]*pkg.PoolRecord, error) {
+	records, err := p.poolScan().records(ctx, p.SolClient)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pool account %s: %w", poolId, err)
+		return nil, fmt.Errorf("failed to fetch all pools: %w", err)
 	}
+	return records, nil
+}
+
+// FetchPoolRecords retrieves the records of the given PumpSwap pools
+func (p *PumpAmmProtocol) FetchPoolRecords(ctx context.Context, poolIDs []solana.PublicKey) ([]*pkg.PoolRecord, error) {
+	records, err := p.poolScan().recordsByID(ctx, p.SolClient, poolIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pool records: %w", err)
+	}
+	return records, nil
+}
+
+// RestorePool decodes a PumpSwap pool from its record
+func (p *PumpAmmProtocol) RestorePool(record *pkg.PoolRecord) (pkg.Pool, error) {
+	return p.DecodePool(context.Background(), record.ID, record.Data)
+}
+
+func (p *PumpAmmProtocol) FetchPoolByID(ctx context.Context, poolId string) (pkg.Pool, error) {
+	poolPubkey, data, err := fetchPoolAccount(ctx, p.SolClient, poolId)
+	if err != nil {
+		return nil, err
+	}
+	return p.DecodePool(ctx, poolPubkey, data)
+}
 
-	layout, err := pump.ParsePoolData(account.Value.Data.GetBinary())
+// DecodePool parses PumpSwap pool account data
+func (p *PumpAmmProtocol) DecodePool(ctx context.Context, poolID solana.PublicKey, data []byte) (pkg.Pool, error) {
+	layout, err := pump.ParsePoolData(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse pool data for pool %s: %w", poolId, err)
+		return nil, fmt.Errorf("failed to parse pool data for pool %s: %w", poolID, err)
 	}
-	layout.PoolId = poolPubkey
+	layout.PoolId = poolID
 	return layout, nil
 }
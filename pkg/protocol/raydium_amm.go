@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
@@ -14,8 +15,30 @@ import (
 	"github.com/solana-zh/solroute/pkg/sol"
 )
 
+func init() {
+	pkg.RegisterProtocol(raydium.RAYDIUM_AMM_PROGRAM_ID, func(solClient *sol.Client) pkg.Protocol {
+		return NewRaydiumAmm(solClient)
+	})
+}
+
+// PoolRecord static keys of the authorities and market accounts resolved for an AMM pool
+const (
+	staticAuthority        = "authority"
+	staticMarketAuthority  = "market_authority"
+	staticMarketBids       = "market_bids"
+	staticMarketAsks       = "market_asks"
+	staticMarketEventQueue = "market_event_queue"
+	staticMarketBaseVault  = "market_base_vault"
+	staticMarketQuoteVault = "market_quote_vault"
+)
+
+// ammAuthoritySeed derives the authority that owns the vaults of every AMM pool
+var ammAuthoritySeed = []byte("amm authority")
+
 type RaydiumAMMProtocol struct {
 	SolClient *sol.Client
+	// OrderBook sets AMMPool.OrderBook on the pools the protocol returns
+	OrderBook bool
 }
 
 func NewRaydiumAmm(solClient *sol.Client) *RaydiumAMMProtocol {
@@ -29,12 +52,10 @@ func (p *RaydiumAMMProtocol) ProtocolName() pkg.ProtocolName {
 }
 
 func (p *RaydiumAMMProtocol) FetchPoolsByPair(ctx context.Context, baseMint, quoteMint string) ([]pkg.Pool, error) {
-	accounts := make([]*rpc.KeyedAccount, 0)
-	programAccounts, err := p.getAMMPoolAccountsByTokenPair(ctx, baseMint, quoteMint)
+	accounts, err := fetchPairAccounts(ctx, baseMint, quoteMint, p.getAMMPoolAccountsByTokenPair)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch pools with base token %s: %w", baseMint, err)
+		return nil, fmt.Errorf("failed to fetch pools for pair %s/%s: %w", baseMint, quoteMint, err)
 	}
-	accounts = append(accounts, programAccounts...)
 
 	res := make([]pkg.Pool, 0)
 	for _, v := range accounts {
@@ -43,6 +64,7 @@ func (p *RaydiumAMMProtocol) FetchPoolsByPair(ctx context.Context, baseMint, quo
 			continue
 		}
 		layout.PoolId = v.Pubkey
+		layout.OrderBook = p.OrderBook
 		if err := p.processAMMPool(ctx, layout); err != nil {
 			return nil, fmt.Errorf("failed to process AMM pool %s: %w", v.Pubkey.String(), err)
 		}
@@ -85,27 +107,199 @@ func (p *RaydiumAMMProtocol) getAMMPoolAccountsByTokenPair(ctx context.Context,
 
 // FetchPoolByID fetches a specific pool by its ID
 func (r *RaydiumAMMProtocol) FetchPoolByID(ctx context.Context, poolID string) (pkg.Pool, error) {
-	poolPubkey, err := solana.PublicKeyFromBase58(poolID)
+	poolPubkey, data, err := fetchPoolAccount(ctx, r.SolClient, poolID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid pool ID: %w", err)
-	}
-
-	account, err := r.SolClient.GetAccountInfoWithOpts(ctx, poolPubkey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get pool account %s: %w", poolID, err)
+		return nil, err
 	}
+	return r.DecodePool(ctx, poolPubkey, data)
+}
 
+// DecodePool decodes AMM pool account data and resolves its authorities from the market
+func (r *RaydiumAMMProtocol) DecodePool(ctx context.Context, poolID solana.PublicKey, data []byte) (pkg.Pool, error) {
 	layout := &raydium.AMMPool{}
-	if err := layout.Decode(account.Value.Data.GetBinary()); err != nil {
+	if err := layout.Decode(data); err != nil {
 		return nil, fmt.Errorf("failed to decode pool data for %s: %w", poolID, err)
 	}
-	layout.PoolId = poolPubkey
+	layout.PoolId = poolID
+	layout.OrderBook = r.OrderBook
 	if err := r.processAMMPool(ctx, layout); err != nil {
 		return nil, fmt.Errorf("failed to process AMM pool %s: %w", poolID, err)
 	}
 	return layout, nil
 }
 
+// poolScan enumerates the AMM pool accounts
+func (r *RaydiumAMMProtocol) poolScan() poolScan {
+	var layout raydium.AMMPool
+	return poolScan{
+		protocol:   pkg.ProtocolNameRaydiumAmm,
+		programID:  raydium.RAYDIUM_AMM_PROGRAM_ID,
+		dataSize:   layout.Span(),
+		mintOffset: layout.Offset("BaseMint"),
+	}
+}
+
+// FetchAllPools retrieves every AMM pool of the program.
+// The market accounts of all pools are fetched in batches with getMultipleAccounts for the
+// order book accounts the swap instruction needs; pools whose market cannot be loaded are skipped.
+func (r *RaydiumAMMProtocol) FetchAllPools(ctx context.Context) ([]pkg.Pool, error) {
+	records, err := r.FetchAllPoolRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return restorePools(records, r.RestorePool), nil
+}
+
+// FetchAllPoolIDs lists the address of every AMM pool
+func (r *RaydiumAMMProtocol) FetchAllPoolIDs(ctx context.Context) ([]solana.PublicKey, error) {
+	ids, err := r.poolScan().ids(ctx, r.SolClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch all pool IDs: %w", err)
+	}
+	return ids, nil
+}
+
+// FetchAllPoolRecords retrieves the record of every AMM pool of the program
+func (r *RaydiumAMMProtocol) FetchAllPoolRecords(ctx context.Context) ([]*pkg.PoolRecord, error) {
+	records, err := r.poolScan().records(ctx, r.SolClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch all pools: %w", err)
+	}
+	if err := r.resolveStatic(ctx, records); err != nil {
+		return nil, err
+	}
+	return dropUnresolved(pkg.ProtocolNameRaydiumAmm, records), nil
+}
+
+// FetchPoolRecords retrieves the records of the given AMM pools
+func (r *RaydiumAMMProtocol) FetchPoolRecords(ctx context.Context, poolIDs []solana.PublicKey) ([]*pkg.PoolRecord, error) {
+	records, err := r.poolScan().recordsByID(ctx, r.SolClient, poolIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pool records: %w", err)
+	}
+	if err := r.resolveStatic(ctx, records); err != nil {
+		return nil, err
+	}
+	return dropUnresolved(pkg.ProtocolNameRaydiumAmm, records), nil
+}
+
+// resolveStatic records the pool and market authorities and the market accounts of each pool,
+// fetching every market once. Pools whose market cannot be loaded get no static metadata.
+func (r *RaydiumAMMProtocol) resolveStatic(ctx context.Context, records []*pkg.PoolRecord) error {
+	authority, _, err := solana.FindProgramAddress([][]byte{ammAuthoritySeed}, raydium.RAYDIUM_AMM_PROGRAM_ID)
+	if err != nil {
+		return fmt.Errorf("failed to find program address: %w", err)
+	}
+
+	layouts := make([]*raydium.AMMPool, len(records))
+	marketKeys := make([]solana.PublicKey, 0)
+	seenMarkets := make(map[solana.PublicKey]struct{})
+	for i, record := range records {
+		layout := &raydium.AMMPool{}
+		if err := layout.Decode(record.Data); err != nil {
+			continue
+		}
+		layouts[i] = layout
+		if _, ok := seenMarkets[layout.MarketId]; !ok {
+			seenMarkets[layout.MarketId] = struct{}{}
+			marketKeys = append(marketKeys, layout.MarketId)
+		}
+	}
+
+	marketData, err := fetchMultipleAccounts(ctx, r.SolClient, marketKeys)
+	if err != nil {
+		return fmt.Errorf("failed to get markets: %w", err)
+	}
+	markets := make(map[solana.PublicKey]*raydium.MarketStateLayoutV3, len(marketData))
+	for key, data := range marketData {
+		market := &raydium.MarketStateLayoutV3{}
+		if err := market.Decode(data); err != nil {
+			continue
+		}
+		markets[key] = market
+	}
+
+	for i, record := range records {
+		layout := layouts[i]
+		if layout == nil {
+			continue
+		}
+		market, ok := markets[layout.MarketId]
+		if !ok {
+			continue
+		}
+		marketAuthority, _, err := getAssociatedAuthority(layout.MarketProgramId, layout.MarketId)
+		if err != nil {
+			continue
+		}
+		record.Static = map[string]string{
+			staticAuthority:        authority.String(),
+			staticMarketAuthority:  marketAuthority.String(),
+			staticMarketBids:       market.Bids.String(),
+			staticMarketAsks:       market.Asks.String(),
+			staticMarketEventQueue: market.EventQueue.String(),
+			staticMarketBaseVault:  market.BaseVault.String(),
+			staticMarketQuoteVault: market.QuoteVault.String(),
+		}
+	}
+	return nil
+}
+
+// RestorePool decodes an AMM pool from its record
+func (r *RaydiumAMMProtocol) RestorePool(record *pkg.PoolRecord) (pkg.Pool, error) {
+	layout := &raydium.AMMPool{}
+	if err := layout.Decode(record.Data); err != nil {
+		return nil, fmt.Errorf("failed to decode pool data for %s: %w", record.ID, err)
+	}
+	layout.PoolId = record.ID
+	layout.OrderBook = r.OrderBook
+
+	err := setStaticKeys(record, []staticKey{
+		{staticAuthority, &layout.Authority},
+		{staticMarketAuthority, &layout.MarketAuthority},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Records saved without the market accounts restore without them; the pool then depends
+	// on its market account until an update loads them
+	if _, ok := record.Static[staticMarketBids]; !ok {
+		return layout, nil
+	}
+	var market raydium.MarketStateLayoutV3
+	err = setStaticKeys(record, []staticKey{
+		{staticMarketBids, &market.Bids},
+		{staticMarketAsks, &market.Asks},
+		{staticMarketEventQueue, &market.EventQueue},
+		{staticMarketBaseVault, &market.BaseVault},
+		{staticMarketQuoteVault, &market.QuoteVault},
+	})
+	if err != nil {
+		return nil, err
+	}
+	layout.SetMarket(&market)
+	return layout, nil
+}
+
+// staticKey is a public key stored in PoolRecord.Static under name
+type staticKey struct {
+	name string
+	key  *solana.PublicKey
+}
+
+// setStaticKeys parses the public keys stored in the static metadata of record
+func setStaticKeys(record *pkg.PoolRecord, keys []staticKey) error {
+	for _, k := range keys {
+		key, err := solana.PublicKeyFromBase58(record.Static[k.name])
+		if err != nil {
+			return fmt.Errorf("invalid %s for %s: %w", strings.ReplaceAll(k.name, "_", " "), record.ID, err)
+		}
+		*k.key = key
+	}
+	return nil
+}
+
 func getAssociatedAuthority(programID solana.PublicKey, marketID solana.PublicKey) (solana.PublicKey, uint8, error) {
 	seeds := [][]byte{marketID.Bytes()}
 	var nonce uint8 = 0
@@ -143,7 +337,7 @@ func (p *RaydiumAMMProtocol) processAMMPool(ctx context.Context, layout *raydium
 		return fmt.Errorf("failed to decode market layout: %w", err)
 	}
 
-	authority, _, err := solana.FindProgramAddress([][]byte{{97, 109, 109, 32, 97, 117, 116, 104, 111, 114, 105, 116, 121}}, raydium.RAYDIUM_AMM_PROGRAM_ID)
+	authority, _, err := solana.FindProgramAddress([][]byte{ammAuthoritySeed}, raydium.RAYDIUM_AMM_PROGRAM_ID)
 	if err != nil {
 		return fmt.Errorf("failed to find program address: %w", err)
 	}
@@ -155,5 +349,6 @@ func (p *RaydiumAMMProtocol) processAMMPool(ctx context.Context, layout *raydium
 
 	layout.Authority = authority
 	layout.MarketAuthority = marketAuthority
+	layout.SetMarket(&marketLayout)
 	return nil
 }
@@ -0,0 +1,157 @@
+package protocol
+
+import (
+	"context"
+	"testing"
+
+	"cosmossdk.io/math"
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
+	"github.com/solana-zh/solroute/pkg/pool/raydium"
+)
+
+// openBookProgramID owns the markets of AMM pools
+var openBookProgramID = solana.MustPublicKeyFromBase58("srmqPvymJeFKQ4zGQed1GFppgkRHL9kaELCbyksJtPX")
+
+// ammPoolData returns the account data of an AMM pool of baseMint and quoteMint trading on market
+func ammPoolData(baseMint, quoteMint, market solana.PublicKey) []byte {
+	var layout raydium.AMMPool
+	data := make([]byte, layout.Span())
+	copy(data[layout.Offset("BaseMint"):], baseMint[:])
+	copy(data[layout.Offset("QuoteMint"):], quoteMint[:])
+	copy(data[layout.Offset("MarketId"):], market[:])
+	copy(data[layout.Offset("MarketProgramId"):], openBookProgramID[:])
+	return data
+}
+
+// testMarket returns an OpenBook market with fresh order book accounts, and its account data
+func testMarket(t *testing.T, market solana.PublicKey) (*raydium.MarketStateLayoutV3, []byte) {
+	t.Helper()
+	layout := &raydium.MarketStateLayoutV3{
+		OwnAddress: market,
+		BaseVault:  solana.NewWallet().PublicKey(),
+		QuoteVault: solana.NewWallet().PublicKey(),
+		EventQueue: solana.NewWallet().PublicKey(),
+		Bids:       solana.NewWallet().PublicKey(),
+		Asks:       solana.NewWallet().PublicKey(),
+	}
+	data, err := bin.MarshalBorsh(layout)
+	if err != nil {
+		t.Fatalf("failed to encode market: %v", err)
+	}
+	return layout, data
+}
+
+func TestFetchAllAMMPoolsLoadsMarketAccounts(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeRPC(t)
+
+	marketID := solana.NewWallet().PublicKey()
+	market, marketData := testMarket(t, marketID)
+	fake.setAccount(marketID, openBookProgramID, marketData)
+
+	poolID := solana.NewWallet().PublicKey()
+	fake.setAccount(poolID, raydium.RAYDIUM_AMM_PROGRAM_ID,
+		ammPoolData(solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), marketID))
+	// A pool whose market does not exist cannot be swapped through and is skipped
+	fake.setAccount(solana.NewWallet().PublicKey(), raydium.RAYDIUM_AMM_PROGRAM_ID,
+		ammPoolData(solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()))
+
+	pools, err := NewRaydiumAmm(client).FetchAllPools(ctx)
+	if err != nil {
+		t.Fatalf("FetchAllPools: %v", err)
+	}
+	if len(pools) != 1 || pools[0].GetID() != poolID.String() {
+		t.Fatalf("FetchAllPools returned %d pools, want only %s", len(pools), poolID)
+	}
+	pool := pools[0].(*raydium.AMMPool)
+	for _, tt := range []struct {
+		name      string
+		got, want solana.PublicKey
+	}{
+		{"bids", pool.MarketBids, market.Bids},
+		{"asks", pool.MarketAsks, market.Asks},
+		{"event queue", pool.MarketEventQueue, market.EventQueue},
+		{"base vault", pool.MarketBaseVault, market.BaseVault},
+		{"quote vault", pool.MarketQuoteVault, market.QuoteVault},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("market %s = %s, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+	if pool.Authority.IsZero() || pool.MarketAuthority.IsZero() {
+		t.Errorf("authorities not resolved: authority %s, market authority %s", pool.Authority, pool.MarketAuthority)
+	}
+}
+
+func TestFetchAMMPoolRecordsDropsPoolsWithoutMarket(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeRPC(t)
+
+	marketID := solana.NewWallet().PublicKey()
+	_, marketData := testMarket(t, marketID)
+	fake.setAccount(marketID, openBookProgramID, marketData)
+	poolID := solana.NewWallet().PublicKey()
+	fake.setAccount(poolID, raydium.RAYDIUM_AMM_PROGRAM_ID,
+		ammPoolData(solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), marketID))
+	orphan := solana.NewWallet().PublicKey()
+	fake.setAccount(orphan, raydium.RAYDIUM_AMM_PROGRAM_ID,
+		ammPoolData(solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()))
+
+	records, err := NewRaydiumAmm(client).FetchPoolRecords(ctx, []solana.PublicKey{poolID, orphan})
+	if err != nil {
+		t.Fatalf("FetchPoolRecords: %v", err)
+	}
+	// The pool whose market failed to load is left out rather than stored without metadata
+	if len(records) != 1 || records[0].ID != poolID || records[0].Static[staticMarketBids] == "" {
+		t.Errorf("FetchPoolRecords = %v, want only the record of %s with its market", records, poolID)
+	}
+}
+
+func TestRestoreAMMPoolWithoutMarketAccounts(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeRPC(t)
+
+	marketID := solana.NewWallet().PublicKey()
+	market, marketData := testMarket(t, marketID)
+	fake.setAccount(marketID, openBookProgramID, marketData)
+	poolID := solana.NewWallet().PublicKey()
+	fake.setAccount(poolID, raydium.RAYDIUM_AMM_PROGRAM_ID,
+		ammPoolData(solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), marketID))
+
+	protocol := NewRaydiumAmm(client)
+	records, err := protocol.FetchPoolRecords(ctx, []solana.PublicKey{poolID})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("FetchPoolRecords = %d records, %v", len(records), err)
+	}
+	// A record saved before the market accounts were part of the static metadata
+	record := records[0]
+	for _, key := range []string{staticMarketBids, staticMarketAsks, staticMarketEventQueue, staticMarketBaseVault, staticMarketQuoteVault} {
+		delete(record.Static, key)
+	}
+
+	restored, err := protocol.RestorePool(record)
+	if err != nil {
+		t.Fatalf("RestorePool: %v", err)
+	}
+	pool := restored.(*raydium.AMMPool)
+	if !containsKey(pool.DependentAccounts(), marketID) {
+		t.Errorf("pool without market accounts does not depend on market %s", marketID)
+	}
+	_, err = pool.BuildSwapInstructions(ctx, client, solana.NewWallet().PublicKey(), pool.BaseMint.String(),
+		math.NewInt(1000), math.NewInt(1), pkg.SwapModeExactIn, solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey())
+	if err == nil {
+		t.Errorf("BuildSwapInstructions succeeded without the market accounts")
+	}
+
+	if err := pool.ApplyUpdate(marketID, marketData, 1); err != nil {
+		t.Fatalf("ApplyUpdate(market): %v", err)
+	}
+	if pool.MarketBids != market.Bids || pool.MarketQuoteVault != market.QuoteVault {
+		t.Errorf("market accounts not loaded from the market update")
+	}
+	if containsKey(pool.DependentAccounts(), marketID) {
+		t.Errorf("pool still depends on market %s once its accounts are loaded", marketID)
+	}
+}
@@ -3,6 +3,7 @@ package protocol
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	bin "github.com/gagliardetto/binary"
 	"github.com/gagliardetto/solana-go"
@@ -12,6 +13,16 @@ import (
 	"github.com/solana-zh/solroute/pkg/sol"
 )
 
+func init() {
+	pkg.RegisterProtocol(raydium.RAYDIUM_CLMM_PROGRAM_ID, func(solClient *sol.Client) pkg.Protocol {
+		return NewRaydiumClmm(solClient)
+	})
+}
+
+// staticFeeRate is the PoolRecord static key of the trade fee rate from the pool's amm config,
+// used by the CLMM and CPMM protocols
+const staticFeeRate = "fee_rate"
+
 type RaydiumClmmProtocol struct {
 	SolClient *sol.Client
 }
@@ -27,38 +38,17 @@ func (p *RaydiumClmmProtocol) ProtocolName() pkg.ProtocolName {
 }
 
 func (p *RaydiumClmmProtocol) FetchPoolsByPair(ctx context.Context, baseMint string, quoteMint string) ([]pkg.Pool, error) {
-	accounts := make([]*rpc.KeyedAccount, 0)
-	programAccounts, err := p.getCLMMPoolAccountsByTokenPair(ctx, baseMint, quoteMint)
+	accounts, err := fetchPairAccounts(ctx, baseMint, quoteMint, p.getCLMMPoolAccountsByTokenPair)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch pools with base token %s: %w", baseMint, err)
+		return nil, fmt.Errorf("failed to fetch pools for pair %s/%s: %w", baseMint, quoteMint, err)
 	}
-	accounts = append(accounts, programAccounts...)
 
 	res := make([]pkg.Pool, 0)
 	for _, v := range accounts {
-		data := v.Account.Data.GetBinary()
-		layout := &raydium.CLMMPool{}
-		if err := layout.Decode(data); err != nil {
-			continue
-		}
-		layout.PoolId = v.Pubkey
-
-		ammConfigData, err := p.SolClient.GetAccountInfoWithOpts(ctx, layout.AmmConfig)
+		layout, err := p.DecodePool(ctx, v.Pubkey, v.Account.Data.GetBinary())
 		if err != nil {
 			continue
 		}
-		feeRate, err := parseAmmConfig(ammConfigData.Value.Data.GetBinary())
-		if err != nil {
-			continue
-		}
-		layout.FeeRate = feeRate
-
-		exBitmapAddress, _, err := raydium.GetPdaExBitmapAccount(raydium.RAYDIUM_CLMM_PROGRAM_ID, layout.PoolId)
-		if err != nil {
-			continue
-		}
-		layout.ExBitmapAddress = exBitmapAddress
-
 		res = append(res, layout)
 	}
 	return res, nil
@@ -102,20 +92,153 @@ func (p *RaydiumClmmProtocol) getCLMMPoolAccountsByTokenPair(ctx context.Context
 }
 
 func (r *RaydiumClmmProtocol) FetchPoolByID(ctx context.Context, poolId string) (pkg.Pool, error) {
-	poolIdKey, err := solana.PublicKeyFromBase58(poolId)
+	poolIdKey, data, err := fetchPoolAccount(ctx, r.SolClient, poolId)
+	if err != nil {
+		return nil, err
+	}
+	return r.DecodePool(ctx, poolIdKey, data)
+}
+
+// DecodePool decodes CLMM pool account data and loads its fee rate from the amm config
+func (r *RaydiumClmmProtocol) DecodePool(ctx context.Context, poolID solana.PublicKey, data []byte) (pkg.Pool, error) {
+	layout, err := decodeCLMMPool(poolID, data)
 	if err != nil {
-		return nil, fmt.Errorf("invalid pool id: %w", err)
+		return nil, err
 	}
-	account, err := r.SolClient.GetAccountInfoWithOpts(ctx, poolIdKey)
+
+	ammConfigData, err := r.SolClient.GetAccountInfoWithOpts(ctx, layout.AmmConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pool account %s: %w", poolId, err)
+		return nil, fmt.Errorf("failed to get amm config %s: %w", layout.AmmConfig, err)
+	}
+	feeRate, err := parseAmmConfig(ammConfigData.Value.Data.GetBinary())
+	if err != nil {
+		return nil, err
+	}
+	layout.FeeRate = feeRate
+	return layout, nil
+}
+
+// poolScan enumerates the CLMM pool accounts
+func (r *RaydiumClmmProtocol) poolScan() poolScan {
+	var knownPoolLayout raydium.CLMMPool
+	return poolScan{
+		protocol:   pkg.ProtocolNameRaydiumClmm,
+		programID:  raydium.RAYDIUM_CLMM_PROGRAM_ID,
+		dataSize:   uint64(knownPoolLayout.Span()),
+		mintOffset: knownPoolLayout.Offset("TokenMint0"),
 	}
+}
 
-	data := account.Value.Data.GetBinary()
+// FetchAllPools retrieves every CLMM pool of the program.
+// Each amm config is fetched once and shared by the pools that use it.
+func (r *RaydiumClmmProtocol) FetchAllPools(ctx context.Context) ([]pkg.Pool, error) {
+	records, err := r.FetchAllPoolRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return restorePools(records, r.RestorePool), nil
+}
+
+// FetchAllPoolIDs lists the address of every CLMM pool
+func (r *RaydiumClmmProtocol) FetchAllPoolIDs(ctx context.Context) ([]solana.PublicKey, error) {
+	ids, err := r.poolScan().ids(ctx, r.SolClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch all pool IDs: %w", err)
+	}
+	return ids, nil
+}
+
+// FetchAllPoolRecords retrieves the record of every CLMM pool of the program
+func (r *RaydiumClmmProtocol) FetchAllPoolRecords(ctx context.Context) ([]*pkg.PoolRecord, error) {
+	records, err := r.poolScan().records(ctx, r.SolClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch all pools: %w", err)
+	}
+	if err := r.resolveStatic(ctx, records); err != nil {
+		return nil, err
+	}
+	return dropUnresolved(pkg.ProtocolNameRaydiumClmm, records), nil
+}
+
+// FetchPoolRecords retrieves the records of the given CLMM pools
+func (r *RaydiumClmmProtocol) FetchPoolRecords(ctx context.Context, poolIDs []solana.PublicKey) ([]*pkg.PoolRecord, error) {
+	records, err := r.poolScan().recordsByID(ctx, r.SolClient, poolIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pool records: %w", err)
+	}
+	if err := r.resolveStatic(ctx, records); err != nil {
+		return nil, err
+	}
+	return dropUnresolved(pkg.ProtocolNameRaydiumClmm, records), nil
+}
+
+// resolveStatic records the trade fee rate of each pool, fetching every amm config once
+func (r *RaydiumClmmProtocol) resolveStatic(ctx context.Context, records []*pkg.PoolRecord) error {
+	ammConfigs := make([]solana.PublicKey, len(records))
+	configKeys := make([]solana.PublicKey, 0)
+	seenConfigs := make(map[solana.PublicKey]struct{})
+	for i, record := range records {
+		layout := &raydium.CLMMPool{}
+		if err := layout.Decode(record.Data); err != nil {
+			continue
+		}
+		ammConfigs[i] = layout.AmmConfig
+		if _, ok := seenConfigs[layout.AmmConfig]; !ok {
+			seenConfigs[layout.AmmConfig] = struct{}{}
+			configKeys = append(configKeys, layout.AmmConfig)
+		}
+	}
+
+	configs, err := fetchMultipleAccounts(ctx, r.SolClient, configKeys)
+	if err != nil {
+		return fmt.Errorf("failed to get amm configs: %w", err)
+	}
+	feeRates := make(map[solana.PublicKey]uint32, len(configs))
+	for key, data := range configs {
+		feeRate, err := parseAmmConfig(data)
+		if err != nil {
+			continue
+		}
+		feeRates[key] = feeRate
+	}
+
+	for i, record := range records {
+		feeRate, ok := feeRates[ammConfigs[i]]
+		if !ok {
+			continue
+		}
+		record.Static = map[string]string{staticFeeRate: strconv.FormatUint(uint64(feeRate), 10)}
+	}
+	return nil
+}
+
+// RestorePool decodes a CLMM pool from its record
+func (r *RaydiumClmmProtocol) RestorePool(record *pkg.PoolRecord) (pkg.Pool, error) {
+	layout, err := decodeCLMMPool(record.ID, record.Data)
+	if err != nil {
+		return nil, err
+	}
+	feeRate, err := strconv.ParseUint(record.Static[staticFeeRate], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fee rate for %s: %w", record.ID, err)
+	}
+	layout.FeeRate = uint32(feeRate)
+	return layout, nil
+}
+
+// decodeCLMMPool decodes pool account data and derives the accounts that depend only on the pool ID
+func decodeCLMMPool(poolID solana.PublicKey, data []byte) (*raydium.CLMMPool, error) {
 	layout := &raydium.CLMMPool{}
 	if err := layout.Decode(data); err != nil {
-		return nil, fmt.Errorf("failed to decode pool data for %s: %w", poolId, err)
+		return nil, fmt.Errorf("failed to decode pool data for %s: %w", poolID, err)
+	}
+	layout.PoolId = poolID
+
+	exBitmapAddress, _, err := raydium.GetPdaExBitmapAccount(raydium.RAYDIUM_CLMM_PROGRAM_ID, layout.PoolId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ex bitmap address: %w", err)
 	}
+	layout.ExBitmapAddress = exBitmapAddress
 	return layout, nil
 }
 
@@ -3,7 +3,9 @@ package protocol
 import (
 	"context"
 	"fmt"
+	"strconv"
 
+	bin "github.com/gagliardetto/binary"
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/solana-zh/solroute/pkg"
@@ -11,6 +13,12 @@ import (
 	"github.com/solana-zh/solroute/pkg/sol"
 )
 
+func init() {
+	pkg.RegisterProtocol(raydium.RAYDIUM_CPMM_PROGRAM_ID, func(solClient *sol.Client) pkg.Protocol {
+		return NewRaydiumCpmm(solClient)
+	})
+}
+
 // RaydiumCpmmProtocol represents the Raydium CPMM protocol implementation
 type RaydiumCpmmProtocol struct {
 	SolClient *sol.Client
@@ -29,20 +37,18 @@ func (p *RaydiumCpmmProtocol) ProtocolName() pkg.ProtocolName {
 
 // FetchPoolsByPair retrieves all pools for a given token pair
 func (p *RaydiumCpmmProtocol) FetchPoolsByPair(ctx context.Context, baseMint string, quoteMint string) ([]pkg.Pool, error) {
-	// Fetch pools with baseMint as token0
-	programAccounts, err := p.getCPMMPoolAccountsByTokenPair(ctx, baseMint, quoteMint)
+	// Fetch pools with either mint as token0
+	programAccounts, err := fetchPairAccounts(ctx, baseMint, quoteMint, p.getCPMMPoolAccountsByTokenPair)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch pools with base token %s: %w", baseMint, err)
+		return nil, fmt.Errorf("failed to fetch pools for pair %s/%s: %w", baseMint, quoteMint, err)
 	}
 
 	pools := make([]pkg.Pool, 0)
 	for _, account := range programAccounts {
-		data := account.Account.Data.GetBinary()
-		pool := &raydium.CPMMPool{}
-		if err := pool.Decode(data); err != nil {
+		pool, err := p.DecodePool(ctx, account.Pubkey, account.Account.Data.GetBinary())
+		if err != nil {
 			continue
 		}
-		pool.PoolId = account.Pubkey
 		pools = append(pools, pool)
 	}
 
@@ -90,18 +96,179 @@ func (p *RaydiumCpmmProtocol) getCPMMPoolAccountsByTokenPair(ctx context.Context
 	return result, nil
 }
 
+// poolScan enumerates the CPMM pool accounts
+func (p *RaydiumCpmmProtocol) poolScan() poolScan {
+	var layout raydium.CPMMPool
+	return poolScan{
+		protocol:   pkg.ProtocolNameRaydiumCpmm,
+		programID:  raydium.RAYDIUM_CPMM_PROGRAM_ID,
+		dataSize:   637,
+		mintOffset: layout.Offset("Token0Mint"),
+	}
+}
+
+// FetchAllPools retrieves every CPMM pool of the program
+func (p *RaydiumCpmmProtocol) FetchAllPools(ctx context.Context) ([]pkg.Pool, error) {
+	records, err := p.FetchAllPoolRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return restorePools(records, p.RestorePool), nil
+}
+
+// FetchAllPoolIDs lists the address of every CPMM pool
+func (p *RaydiumCpmmProtocol) FetchAllPoolIDs(ctx context.Context) ([]solana.PublicKey, error) {
+	ids, err := p.poolScan().ids(ctx, p.SolClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch all pool IDs: %w", err)
+	}
+	return ids, nil
+}
+
+// FetchAllPoolRecords retrieves the record of every CPMM pool of the program
+func (p *RaydiumCpmmProtocol) FetchAllPoolRecords(ctx context.Context) ([]*pkg.PoolRecord, error) {
+	records, err := p.poolScan().records(ctx, p.SolClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch all pools: %w", err)
+	}
+	if err := p.resolveStatic(ctx, records); err != nil {
+		return nil, err
+	}
+	return dropUnresolved(pkg.ProtocolNameRaydiumCpmm, records), nil
+}
+
+// FetchPoolRecords retrieves the records of the given CPMM pools
+func (p *RaydiumCpmmProtocol) FetchPoolRecords(ctx context.Context, poolIDs []solana.PublicKey) ([]*pkg.PoolRecord, error) {
+	records, err := p.poolScan().recordsByID(ctx, p.SolClient, poolIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pool records: %w", err)
+	}
+	if err := p.resolveStatic(ctx, records); err != nil {
+		return nil, err
+	}
+	return dropUnresolved(pkg.ProtocolNameRaydiumCpmm, records), nil
+}
+
+// resolveStatic records the trade fee rate of each pool, fetching every amm config once
+func (p *RaydiumCpmmProtocol) resolveStatic(ctx context.Context, records []*pkg.PoolRecord) error {
+	ammConfigs := make([]solana.PublicKey, len(records))
+	configKeys := make([]solana.PublicKey, 0)
+	seenConfigs := make(map[solana.PublicKey]struct{})
+	for i, record := range records {
+		pool := &raydium.CPMMPool{}
+		if err := pool.Decode(record.Data); err != nil {
+			continue
+		}
+		ammConfigs[i] = pool.AmmConfig
+		if _, ok := seenConfigs[pool.AmmConfig]; !ok {
+			seenConfigs[pool.AmmConfig] = struct{}{}
+			configKeys = append(configKeys, pool.AmmConfig)
+		}
+	}
+
+	configs, err := fetchMultipleAccounts(ctx, p.SolClient, configKeys)
+	if err != nil {
+		return fmt.Errorf("failed to get amm configs: %w", err)
+	}
+	feeRates := make(map[solana.PublicKey]uint64, len(configs))
+	for key, data := range configs {
+		feeRate, err := parseCpmmAmmConfig(data)
+		if err != nil {
+			continue
+		}
+		feeRates[key] = feeRate
+	}
+
+	for i, record := range records {
+		feeRate, ok := feeRates[ammConfigs[i]]
+		if !ok {
+			continue
+		}
+		record.Static = map[string]string{staticFeeRate: strconv.FormatUint(feeRate, 10)}
+	}
+	return nil
+}
+
+// RestorePool decodes a CPMM pool from its record
+func (p *RaydiumCpmmProtocol) RestorePool(record *pkg.PoolRecord) (pkg.Pool, error) {
+	pool, err := decodeCPMMPool(record.ID, record.Data)
+	if err != nil {
+		return nil, err
+	}
+	feeRate, err := strconv.ParseUint(record.Static[staticFeeRate], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fee rate for %s: %w", record.ID, err)
+	}
+	pool.TradeFeeRate = feeRate
+	return pool, nil
+}
+
 // FetchPoolByID retrieves a CPMM pool by its ID
 func (p *RaydiumCpmmProtocol) FetchPoolByID(ctx context.Context, poolID string) (pkg.Pool, error) {
-	account, err := p.SolClient.GetAccountInfoWithOpts(ctx, solana.MustPublicKeyFromBase58(poolID))
+	poolKey, data, err := fetchPoolAccount(ctx, p.SolClient, poolID)
+	if err != nil {
+		return nil, err
+	}
+	return p.DecodePool(ctx, poolKey, data)
+}
+
+// DecodePool decodes CPMM pool account data and loads its trade fee rate from the amm config
+func (p *RaydiumCpmmProtocol) DecodePool(ctx context.Context, poolID solana.PublicKey, data []byte) (pkg.Pool, error) {
+	pool, err := decodeCPMMPool(poolID, data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pool account %s: %w", poolID, err)
+		return nil, err
 	}
 
+	ammConfigData, err := p.SolClient.GetAccountInfoWithOpts(ctx, pool.AmmConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get amm config %s: %w", pool.AmmConfig, err)
+	}
+	feeRate, err := parseCpmmAmmConfig(ammConfigData.Value.Data.GetBinary())
+	if err != nil {
+		return nil, err
+	}
+	pool.TradeFeeRate = feeRate
+	return pool, nil
+}
+
+// decodeCPMMPool decodes pool account data
+func decodeCPMMPool(poolID solana.PublicKey, data []byte) (*raydium.CPMMPool, error) {
 	pool := &raydium.CPMMPool{}
-	if err := pool.Decode(account.Value.Data.GetBinary()); err != nil {
+	if err := pool.Decode(data); err != nil {
 		return nil, fmt.Errorf("failed to decode pool data for %s: %w", poolID, err)
 	}
-	pool.PoolId = solana.MustPublicKeyFromBase58(poolID)
-
+	pool.PoolId = poolID
 	return pool, nil
 }
+
+func parseCpmmAmmConfig(data []byte) (uint64, error) {
+	var ammConfig CpmmAmmConfig
+	if err := ammConfig.Decode(data); err != nil {
+		return 0, fmt.Errorf("failed to decode amm config: %w", err)
+	}
+	return ammConfig.TradeFeeRate, nil
+}
+
+// CpmmAmmConfig is the amm config account shared by CPMM pools; fee rates are over 1_000_000
+type CpmmAmmConfig struct {
+	Bump              uint8
+	DisableCreatePool bool
+	Index             uint16
+	TradeFeeRate      uint64
+	ProtocolFeeRate   uint64
+	FundFeeRate       uint64
+	CreatePoolFee     uint64
+	ProtocolOwner     solana.PublicKey
+	FundOwner         solana.PublicKey
+	Padding           [16]uint64
+}
+
+func (l *CpmmAmmConfig) Decode(data []byte) error {
+	// Skip 8 bytes discriminator if present
+	if len(data) > 8 {
+		data = data[8:]
+	}
+
+	dec := bin.NewBinDecoder(data)
+	return dec.Decode(l)
+}
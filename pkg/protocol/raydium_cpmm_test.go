@@ -0,0 +1,148 @@
+package protocol
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
+	"github.com/solana-zh/solroute/pkg/pool/raydium"
+)
+
+// loadAccountFixture serves the accounts of testdata/<name>.json, a pool account followed by the
+// accounts it depends on, and returns the pool's address and the data of every account
+func loadAccountFixture(t *testing.T, fake *fakeRPC, name string) (solana.PublicKey, map[solana.PublicKey][]byte) {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	var fixture struct {
+		Accounts []struct {
+			Address solana.PublicKey `json:"address"`
+			Owner   solana.PublicKey `json:"owner"`
+			Data    string           `json:"data"`
+		} `json:"accounts"`
+	}
+	if err := json.Unmarshal(raw, &fixture); err != nil {
+		t.Fatalf("failed to decode fixture %s: %v", name, err)
+	}
+	accounts := make(map[solana.PublicKey][]byte, len(fixture.Accounts))
+	for _, account := range fixture.Accounts {
+		data, err := base64.StdEncoding.DecodeString(account.Data)
+		if err != nil {
+			t.Fatalf("invalid data of %s in fixture %s: %v", account.Address, name, err)
+		}
+		fake.setAccount(account.Address, account.Owner, data)
+		accounts[account.Address] = data
+	}
+	return fixture.Accounts[0].Address, accounts
+}
+
+// loadCPMMFixture fetches the pool of a CPMM fixture and applies its dependent accounts
+func loadCPMMFixture(t *testing.T, name string) *raydium.CPMMPool {
+	t.Helper()
+	fake, client := newFakeRPC(t)
+	poolID, accounts := loadAccountFixture(t, fake, filepath.Join("cpmm", name))
+	fetched, err := NewRaydiumCpmm(client).FetchPoolByID(context.Background(), poolID.String())
+	if err != nil {
+		t.Fatalf("FetchPoolByID(%s): %v", poolID, err)
+	}
+	pool := fetched.(*raydium.CPMMPool)
+	for _, account := range pool.DependentAccounts() {
+		data, ok := accounts[account]
+		if !ok {
+			t.Fatalf("fixture %s has no dependent account %s", name, account)
+		}
+		if err := pool.ApplyUpdate(account, data, 1); err != nil {
+			t.Fatalf("ApplyUpdate(%s): %v", account, err)
+		}
+	}
+	return pool
+}
+
+// The expected amounts follow the program's swap_base_input and swap_base_output from the vault
+// balances less the protocol and fund fees:
+//
+//	sol_usdc_25bps:  trade fee 2500, reserves 12344098654443 WSOL and 1987509876765 USDC
+//	meme_sol_100bps: trade fee 10000, reserves 903112554000117 MEME and 75457706446 WSOL
+func TestCPMMPoolQuoteFixtures(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		fixture  string
+		zeroIn   bool
+		exactOut bool
+		amount   int64
+		want     int64
+	}{
+		{name: "sell WSOL", fixture: "sol_usdc_25bps", zeroIn: true, amount: 1_000_000_000, want: 160_593_411},
+		{name: "buy WSOL", fixture: "sol_usdc_25bps", amount: 1_000_000_000, want: 6_192_201_523},
+		{name: "dust rounds to nothing", fixture: "sol_usdc_25bps", zeroIn: true, amount: 7, want: 0},
+		{name: "large buy", fixture: "sol_usdc_25bps", amount: 50_000_000_000, want: 302_182_433_673},
+		{name: "exact out USDC", fixture: "sol_usdc_25bps", zeroIn: true, exactOut: true, amount: 150_000_000, want: 934_030_852},
+		{name: "exact out WSOL", fixture: "sol_usdc_25bps", exactOut: true, amount: 1_000_000_000, want: 161_425_520},
+		{name: "exact out one unit", fixture: "sol_usdc_25bps", zeroIn: true, exactOut: true, amount: 1, want: 8},
+		{name: "exact out large", fixture: "sol_usdc_25bps", exactOut: true, amount: 12_000_000_000, want: 1_938_834_090},
+		{name: "sell MEME", fixture: "meme_sol_100bps", zeroIn: true, amount: 1_000_000_000, want: 82_717},
+		{name: "buy MEME", fixture: "meme_sol_100bps", amount: 1_000_000_000, want: 11_695_333_581_938},
+		{name: "large MEME buy", fixture: "meme_sol_100bps", amount: 50_000_000_000, want: 357_753_616_759_319},
+		{name: "exact out WSOL from MEME", fixture: "meme_sol_100bps", zeroIn: true, exactOut: true, amount: 150_000_000, want: 1_817_015_042_846},
+		{name: "exact out MEME", fixture: "meme_sol_100bps", exactOut: true, amount: 1_000_000_000, want: 84_398},
+		{name: "exact out one lamport", fixture: "meme_sol_100bps", zeroIn: true, exactOut: true, amount: 1, want: 12_090},
+		{name: "exact out large MEME", fixture: "meme_sol_100bps", exactOut: true, amount: 12_000_000_000, want: 1_012_777},
+	} {
+		t.Run(tt.fixture+"/"+tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pool := loadCPMMFixture(t, tt.fixture)
+			inputMint, outputMint := pool.Token1Mint.String(), pool.Token0Mint.String()
+			if tt.zeroIn {
+				inputMint, outputMint = outputMint, inputMint
+			}
+
+			var result *pkg.QuoteResult
+			var err error
+			if tt.exactOut {
+				result, err = pool.QuoteExactOut(ctx, inputMint, math.NewInt(tt.amount))
+			} else {
+				result, err = pool.Quote(ctx, inputMint, math.NewInt(tt.amount))
+			}
+			if err != nil {
+				t.Fatalf("quote: %v", err)
+			}
+			if result.InputMint != inputMint || result.OutputMint != outputMint {
+				t.Errorf("quote swaps %s for %s, want %s for %s", result.InputMint, result.OutputMint, inputMint, outputMint)
+			}
+			got := result.AmountOut
+			if tt.exactOut {
+				got = result.AmountIn
+				if !result.AmountOut.Equal(math.NewInt(tt.amount)) {
+					t.Errorf("AmountOut = %v, want %d", result.AmountOut, tt.amount)
+				}
+			}
+			if !got.Equal(math.NewInt(tt.want)) {
+				t.Errorf("got %v, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCPMMPoolReservesLeaveOutFees(t *testing.T) {
+	pool := loadCPMMFixture(t, "sol_usdc_25bps")
+	if pool.TradeFeeRate != 2500 {
+		t.Errorf("TradeFeeRate = %d, want 2500 from the amm config", pool.TradeFeeRate)
+	}
+	if pool.ProtocolFeesToken0 != 1_234_567_890 || pool.FundFeesToken1 != 45_678_901 {
+		t.Errorf("decoded fees %d and %d, want 1234567890 and 45678901", pool.ProtocolFeesToken0, pool.FundFeesToken1)
+	}
+	if want := math.NewInt(12_344_098_654_443); !pool.BaseReserve.Equal(want) {
+		t.Errorf("BaseReserve = %v, want %v", pool.BaseReserve, want)
+	}
+	if want := math.NewInt(1_987_509_876_765); !pool.QuoteReserve.Equal(want) {
+		t.Errorf("QuoteReserve = %v, want %v", pool.QuoteReserve, want)
+	}
+}
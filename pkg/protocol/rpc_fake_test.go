@@ -0,0 +1,173 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// fakeAccount is an account served by fakeRPC
+type fakeAccount struct {
+	owner solana.PublicKey
+	data  []byte
+}
+
+// fakeRPC is a JSON-RPC server serving getAccountInfo, getMultipleAccounts and
+// getProgramAccounts, with its dataSize and memcmp filters, from a fixed set of accounts
+type fakeRPC struct {
+	mu       sync.Mutex
+	accounts map[solana.PublicKey]fakeAccount
+	// everyScan lists accounts every getProgramAccounts call returns whatever its filters,
+	// as a node answering overlapping queries would
+	everyScan []solana.PublicKey
+	// calls counts the calls of each method
+	calls map[string]int
+	// scans holds the filters of every getProgramAccounts call
+	scans [][]rpc.RPCFilter
+}
+
+func newFakeRPC(t *testing.T) (*fakeRPC, *sol.Client) {
+	t.Helper()
+	fake := &fakeRPC{
+		accounts: make(map[solana.PublicKey]fakeAccount),
+		calls:    make(map[string]int),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client, err := sol.NewClient(context.Background(), server.URL, "", 1000)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return fake, client
+}
+
+func (f *fakeRPC) setAccount(key, owner solana.PublicKey, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accounts[key] = fakeAccount{owner: owner, data: data}
+}
+
+func (f *fakeRPC) callCount(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// scanFilters returns the filters of every getProgramAccounts call
+func (f *fakeRPC) scanFilters() [][]rpc.RPCFilter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]rpc.RPCFilter(nil), f.scans...)
+}
+
+func (f *fakeRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.calls[req.Method]++
+	var result interface{}
+	switch req.Method {
+	case "getAccountInfo":
+		var key solana.PublicKey
+		json.Unmarshal(req.Params[0], &key)
+		result = map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": f.accountJSON(key)}
+	case "getMultipleAccounts":
+		var keys []solana.PublicKey
+		json.Unmarshal(req.Params[0], &keys)
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			values[i] = f.accountJSON(key)
+		}
+		result = map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": values}
+	case "getProgramAccounts":
+		var program solana.PublicKey
+		json.Unmarshal(req.Params[0], &program)
+		var opts struct {
+			Filters []rpc.RPCFilter `json:"filters"`
+		}
+		if len(req.Params) > 1 {
+			json.Unmarshal(req.Params[1], &opts)
+		}
+		f.scans = append(f.scans, opts.Filters)
+		matches := make([]interface{}, 0)
+		for key, account := range f.accounts {
+			if account.owner == program && (matchesFilters(account.data, opts.Filters) || containsKey(f.everyScan, key)) {
+				matches = append(matches, map[string]interface{}{"pubkey": key, "account": f.accountJSON(key)})
+			}
+		}
+		result = matches
+	default:
+		f.mu.Unlock()
+		writeRPC(w, req.ID, nil, map[string]interface{}{"code": -32601, "message": "method not found: " + req.Method})
+		return
+	}
+	f.mu.Unlock()
+	writeRPC(w, req.ID, result, nil)
+}
+
+// accountJSON returns the account in the base64 encoding of the RPC, nil when it does not exist
+func (f *fakeRPC) accountJSON(key solana.PublicKey) interface{} {
+	account, ok := f.accounts[key]
+	if !ok {
+		return nil
+	}
+	return map[string]interface{}{
+		"data":       []string{base64.StdEncoding.EncodeToString(account.data), "base64"},
+		"executable": false,
+		"lamports":   1,
+		"owner":      account.owner,
+		"rentEpoch":  0,
+	}
+}
+
+func matchesFilters(data []byte, filters []rpc.RPCFilter) bool {
+	for _, filter := range filters {
+		if filter.DataSize != 0 && uint64(len(data)) != filter.DataSize {
+			return false
+		}
+		if memcmp := filter.Memcmp; memcmp != nil {
+			end := memcmp.Offset + uint64(len(memcmp.Bytes))
+			if end > uint64(len(data)) || !bytes.Equal(data[memcmp.Offset:end], memcmp.Bytes) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsKey(keys []solana.PublicKey, key solana.PublicKey) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func writeRPC(w http.ResponseWriter, id json.RawMessage, result interface{}, rpcErr interface{}) {
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
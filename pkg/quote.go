@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"math/big"
+
+	"cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+)
+
+// QuoteResult describes a quoted swap through a single pool.
+// Prices are in raw token units: the amount of OutputMint paid for one unit of InputMint.
+type QuoteResult struct {
+	InputMint  string
+	OutputMint string
+	AmountIn   math.Int
+	AmountOut  math.Int
+
+	// FeeAmount is the trading fee charged by the pool, denominated in FeeMint
+	FeeAmount math.Int
+	FeeMint   string
+
+	// TransferFeeIn and TransferFeeOut are the Token-2022 transfer fees withheld from the input
+	// sent to the pool and from the output sent to the user. AmountIn and AmountOut include them:
+	// AmountIn is what the user sends and AmountOut what the user receives.
+	TransferFeeIn  math.Int
+	TransferFeeOut math.Int
+
+	// SpotPriceBefore and SpotPriceAfter are the marginal prices before and after the swap
+	SpotPriceBefore math.LegacyDec
+	SpotPriceAfter  math.LegacyDec
+	// PriceImpactBps is how far the execution price, excluding fees, is below SpotPriceBefore
+	PriceImpactBps int64
+
+	// TicksCrossed counts the initialized ticks (CLMM) or bins (DLMM) the swap moves through
+	TicksCrossed int
+	// Accounts lists the pool-side accounts the swap reads or writes, such as vaults,
+	// tick arrays and bin arrays
+	Accounts []solana.PublicKey
+}
+
+// SetPriceImpact computes PriceImpactBps from SpotPriceBefore and the quoted amounts
+func (q *QuoteResult) SetPriceImpact() {
+	q.PriceImpactBps = 0
+	if q.SpotPriceBefore.IsNil() || !q.SpotPriceBefore.IsPositive() {
+		return
+	}
+
+	// Take the pool and transfer fees out so the impact only reflects the curve
+	amountIn, amountOut := q.AmountIn, q.AmountOut
+	if !q.FeeAmount.IsNil() {
+		if q.FeeMint == q.InputMint {
+			amountIn = amountIn.Sub(q.FeeAmount)
+		} else if q.FeeMint == q.OutputMint {
+			amountOut = amountOut.Add(q.FeeAmount)
+		}
+	}
+	if !q.TransferFeeIn.IsNil() {
+		amountIn = amountIn.Sub(q.TransferFeeIn)
+	}
+	if !q.TransferFeeOut.IsNil() {
+		amountOut = amountOut.Add(q.TransferFeeOut)
+	}
+	if !amountIn.IsPositive() || !amountOut.IsPositive() {
+		return
+	}
+
+	executionPrice := math.LegacyNewDecFromInt(amountOut).QuoInt(amountIn)
+	impact := q.SpotPriceBefore.Sub(executionPrice).Quo(q.SpotPriceBefore).MulInt64(10000)
+	if impact.IsPositive() {
+		q.PriceImpactBps = impact.TruncateInt64()
+	}
+}
+
+// SpotPrice returns the marginal price of a constant-product pool, reserveOut / reserveIn
+func SpotPrice(reserveIn, reserveOut math.Int) math.LegacyDec {
+	if !reserveIn.IsPositive() {
+		return math.LegacyZeroDec()
+	}
+	return math.LegacyNewDecFromInt(reserveOut).QuoInt(reserveIn)
+}
+
+// DecFromFixedPoint converts an unsigned fixed-point number with fracBits fractional bits to a decimal
+func DecFromFixedPoint(value *big.Int, fracBits uint) math.LegacyDec {
+	scaled := new(big.Int).Mul(value, math.LegacyOneDec().BigInt())
+	scaled.Rsh(scaled, fracBits)
+	return math.LegacyNewDecFromBigIntWithPrec(scaled, math.LegacyPrecision)
+}
+
+// InvertPrice returns 1 / price, or zero when price is zero
+func InvertPrice(price math.LegacyDec) math.LegacyDec {
+	if !price.IsPositive() {
+		return math.LegacyZeroDec()
+	}
+	return math.LegacyOneDec().Quo(price)
+}
@@ -0,0 +1,30 @@
+package pkg
+
+import (
+	"context"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// PoolRecord is the persisted form of a pool: its account data plus the static metadata that
+// DecodePool would otherwise resolve with extra RPC calls, such as market authorities or fee rates
+type PoolRecord struct {
+	ID       solana.PublicKey  `json:"id"`
+	Protocol ProtocolName      `json:"protocol"`
+	Data     []byte            `json:"data"`
+	Static   map[string]string `json:"static,omitempty"`
+}
+
+// PersistentProtocol is implemented by protocols whose pools can be stored as PoolRecords
+// and restored without network calls
+type PersistentProtocol interface {
+	Protocol
+	// FetchAllPoolIDs lists the address of every pool without downloading the pool data
+	FetchAllPoolIDs(ctx context.Context) ([]solana.PublicKey, error)
+	// FetchAllPoolRecords enumerates every pool with its static metadata resolved
+	FetchAllPoolRecords(ctx context.Context) ([]*PoolRecord, error)
+	// FetchPoolRecords fetches the records of the given pools; pools that no longer exist are skipped
+	FetchPoolRecords(ctx context.Context, poolIDs []solana.PublicKey) ([]*PoolRecord, error)
+	// RestorePool rebuilds a pool from its record
+	RestorePool(record *PoolRecord) (Pool, error)
+}
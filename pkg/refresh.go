@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// ErrPoolStateNotLoaded is returned when quoting a pool before its dependent accounts were applied
+var ErrPoolStateNotLoaded = errors.New("pool state not loaded")
+
+// maxRefreshRounds bounds how many times RefreshPool follows dependent accounts that appear
+// after an update, such as CLMM tick arrays selected from the bitmap extension
+const maxRefreshRounds = 3
+
+// RefreshPool fetches the dependent accounts of pool and applies them
+func RefreshPool(ctx context.Context, solClient *sol.Client, pool Pool) error {
+	return RefreshPools(ctx, solClient, []Pool{pool})[pool.GetID()]
+}
+
+// RefreshPools refreshes every pool like RefreshPool in one batch: the dependent accounts of all
+// pools are collected, accounts shared by several pools are fetched once, and each round costs
+// one getMultipleAccounts request per sol.MaxMultipleAccounts accounts.
+// It returns the errors of the pools that could not be refreshed, keyed by pool ID.
+func RefreshPools(ctx context.Context, solClient *sol.Client, pools []Pool) map[string]error {
+	failed := make(map[string]error)
+	fetched := make(map[solana.PublicKey]sol.AccountData)
+	requested := make(map[solana.PublicKey]struct{})
+	applied := make([]map[solana.PublicKey]struct{}, len(pools))
+	for round := 0; round < maxRefreshRounds; round++ {
+		pending := make(map[int][]solana.PublicKey)
+		var missing []solana.PublicKey
+		for i, pool := range pools {
+			if _, ok := failed[pool.GetID()]; ok {
+				continue
+			}
+			if applied[i] == nil {
+				applied[i] = make(map[solana.PublicKey]struct{})
+			}
+			for _, account := range pool.DependentAccounts() {
+				if _, ok := applied[i][account]; ok {
+					continue
+				}
+				applied[i][account] = struct{}{}
+				pending[i] = append(pending[i], account)
+				if _, ok := requested[account]; !ok {
+					requested[account] = struct{}{}
+					missing = append(missing, account)
+				}
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		if len(missing) > 0 {
+			accounts, err := solClient.GetMultipleAccountsChunked(ctx, missing)
+			if err != nil {
+				for i := range pending {
+					failed[pools[i].GetID()] = fmt.Errorf("failed to fetch dependent accounts of pool %s: %w", pools[i].GetID(), err)
+				}
+				break
+			}
+			for account, data := range accounts {
+				fetched[account] = data
+			}
+		}
+
+		for i, accounts := range pending {
+			for _, account := range accounts {
+				data, ok := fetched[account]
+				if !ok {
+					continue
+				}
+				if err := pools[i].ApplyUpdate(account, data.Data, data.Slot); err != nil {
+					failed[pools[i].GetID()] = fmt.Errorf("failed to apply account %s to pool %s: %w", account, pools[i].GetID(), err)
+					break
+				}
+			}
+		}
+	}
+	return failed
+}
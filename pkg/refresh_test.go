@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+func TestRefreshPoolsBatchesAccounts(t *testing.T) {
+	fake, client := newFakeRPC(t)
+	owner := solana.NewWallet().PublicKey()
+	newAccount := func() solana.PublicKey {
+		key := solana.NewWallet().PublicKey()
+		fake.setAccount(key, owner, key[:])
+		return key
+	}
+
+	// Every pool has its own state account and shares a vault and a mint with the others;
+	// every third pool learns two tick arrays from its state
+	sharedVault, sharedMint := newAccount(), newAccount()
+	const poolCount = 150
+	pools := make([]Pool, poolCount)
+	fakes := make([]*fakePool, poolCount)
+	tickArrays := 0
+	for i := range pools {
+		fakes[i] = newFakePool(solana.NewWallet().PublicKey().String(), newAccount(), sharedVault, sharedMint)
+		if i%3 == 0 {
+			fakes[i].next = []solana.PublicKey{newAccount(), newAccount()}
+			tickArrays += len(fakes[i].next)
+		}
+		pools[i] = fakes[i]
+	}
+	// A pool depending on a missing account is refreshed with what exists
+	missing := newFakePool("missing", solana.NewWallet().PublicKey())
+	pools = append(pools, missing)
+
+	if failed := RefreshPools(context.Background(), client, pools); len(failed) != 0 {
+		t.Fatalf("RefreshPools failed: %v", failed)
+	}
+
+	batches := fake.multipleAccountsBatches()
+	requested := make(map[solana.PublicKey]int)
+	for _, batch := range batches {
+		if len(batch) > sol.MaxMultipleAccounts {
+			t.Errorf("getMultipleAccounts requested %d keys, more than %d", len(batch), sol.MaxMultipleAccounts)
+		}
+		for _, key := range batch {
+			requested[key]++
+		}
+	}
+	for key, count := range requested {
+		if count != 1 {
+			t.Errorf("account %s fetched %d times, want once", key, count)
+		}
+	}
+	// The first round fetches the states, the shared accounts and the missing account; the
+	// second round the tick arrays
+	firstRound, secondRound := poolCount+2+1, tickArrays
+	wantCalls := (firstRound+sol.MaxMultipleAccounts-1)/sol.MaxMultipleAccounts +
+		(secondRound+sol.MaxMultipleAccounts-1)/sol.MaxMultipleAccounts
+	if len(batches) != wantCalls {
+		t.Errorf("getMultipleAccounts called %d times, want %d", len(batches), wantCalls)
+	}
+	if len(requested) != firstRound+secondRound {
+		t.Errorf("fetched %d accounts, want %d", len(requested), firstRound+secondRound)
+	}
+	for _, key := range batches[len(batches)-1] {
+		if key == sharedVault || key == sharedMint {
+			t.Errorf("shared account %s fetched again in the second round", key)
+		}
+	}
+
+	for _, pool := range fakes {
+		for _, account := range pool.DependentAccounts() {
+			if string(pool.applied[account]) != string(account[:]) {
+				t.Errorf("pool %s did not get the data of account %s", pool.id, account)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// ProtocolFactory creates a protocol bound to a client
+type ProtocolFactory func(solClient *sol.Client) Protocol
+
+// Registry maps the program that owns a pool account to the protocol that can decode it
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[solana.PublicKey]ProtocolFactory
+	order     []solana.PublicKey
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[solana.PublicKey]ProtocolFactory),
+	}
+}
+
+// DefaultRegistry holds the protocols that register themselves on import
+var DefaultRegistry = NewRegistry()
+
+// RegisterProtocol adds a protocol to DefaultRegistry.
+// Protocol packages call it from init for the program that owns their pool accounts.
+func RegisterProtocol(programID solana.PublicKey, factory ProtocolFactory) {
+	DefaultRegistry.Register(programID, factory)
+}
+
+// Register maps programID to factory, replacing any earlier registration
+func (r *Registry) Register(programID solana.PublicKey, factory ProtocolFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.factories[programID]; !ok {
+		r.order = append(r.order, programID)
+	}
+	r.factories[programID] = factory
+}
+
+// ProgramIDs returns the registered program IDs in registration order
+func (r *Registry) ProgramIDs() []solana.PublicKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]solana.PublicKey(nil), r.order...)
+}
+
+// Protocol returns the protocol registered for programID
+func (r *Registry) Protocol(solClient *sol.Client, programID solana.PublicKey) (Protocol, bool) {
+	r.mu.RLock()
+	factory, ok := r.factories[programID]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return factory(solClient), true
+}
+
+// Protocols creates every registered protocol, in registration order
+func (r *Registry) Protocols(solClient *sol.Client) []Protocol {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	protocols := make([]Protocol, 0, len(r.order))
+	for _, programID := range r.order {
+		protocols = append(protocols, r.factories[programID](solClient))
+	}
+	return protocols
+}
+
+// FetchPoolByID fetches the pool account once, picks the protocol registered for its
+// owner program and returns the decoded pool
+func (r *Registry) FetchPoolByID(ctx context.Context, solClient *sol.Client, poolID string) (Pool, error) {
+	poolKey, err := solana.PublicKeyFromBase58(poolID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pool ID: %w", err)
+	}
+
+	account, err := solClient.GetAccountInfoWithOpts(ctx, poolKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool account %s: %w", poolID, err)
+	}
+	if account == nil || account.Value == nil {
+		return nil, fmt.Errorf("pool account %s not found", poolID)
+	}
+
+	owner := account.Value.Owner
+	protocol, ok := r.Protocol(solClient, owner)
+	if !ok {
+		return nil, fmt.Errorf("no protocol registered for program %s owning pool %s", owner, poolID)
+	}
+
+	pool, err := protocol.DecodePool(ctx, poolKey, account.Value.Data.GetBinary())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s pool %s: %w", protocol.ProtocolName(), poolID, err)
+	}
+	return pool, nil
+}
+
+// FetchPoolByID looks up a pool of any registered protocol by its address
+func FetchPoolByID(ctx context.Context, solClient *sol.Client, poolID string) (Pool, error) {
+	return DefaultRegistry.FetchPoolByID(ctx, solClient, poolID)
+}
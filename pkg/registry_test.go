@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+func TestRegistryFetchPoolByID(t *testing.T) {
+	fake, client := newFakeRPC(t)
+	programA, programB := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	registry := NewRegistry()
+	registry.Register(programA, fakeFactory("a"))
+	registry.Register(programB, fakeFactory("b"))
+
+	poolA, poolB := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	fake.setAccount(poolA, programA, []byte("pool a"))
+	fake.setAccount(poolB, programB, []byte("pool b"))
+	unowned := solana.NewWallet().PublicKey()
+	fake.setAccount(unowned, solana.NewWallet().PublicKey(), []byte("other"))
+	undecodable := solana.NewWallet().PublicKey()
+	fake.setAccount(undecodable, programA, nil)
+
+	for _, tt := range []struct {
+		name         string
+		poolID       string
+		wantProtocol ProtocolName
+		wantData     string
+		wantErr      string
+	}{
+		{"owned by a", poolA.String(), "a", "pool a", ""},
+		{"owned by b", poolB.String(), "b", "pool b", ""},
+		{"unknown owner", unowned.String(), "", "", "no protocol registered"},
+		{"missing account", solana.NewWallet().PublicKey().String(), "", "", "not found"},
+		{"decode failure", undecodable.String(), "", "", "failed to decode a pool"},
+		{"invalid ID", "not a key", "", "", "invalid pool ID"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := registry.FetchPoolByID(context.Background(), client, tt.poolID)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FetchPoolByID error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchPoolByID: %v", err)
+			}
+			decoded := pool.(*fakePool)
+			if decoded.protocol != tt.wantProtocol || decoded.GetID() != tt.poolID || !bytes.Equal(decoded.data, []byte(tt.wantData)) {
+				t.Errorf("decoded %s pool %s from %q, want %s pool %s from %q",
+					decoded.protocol, decoded.GetID(), decoded.data, tt.wantProtocol, tt.poolID, tt.wantData)
+			}
+		})
+	}
+	if got := fake.callCount("getAccountInfo"); got != 5 {
+		t.Errorf("getAccountInfo called %d times, want once per valid ID", got)
+	}
+}
+
+func TestRegistryRegisterReplaces(t *testing.T) {
+	programA, programB := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	registry := NewRegistry()
+	registry.Register(programA, fakeFactory("a"))
+	registry.Register(programB, fakeFactory("b"))
+	registry.Register(programA, fakeFactory("a2"))
+
+	if got, want := registry.ProgramIDs(), []solana.PublicKey{programA, programB}; !slices.Equal(got, want) {
+		t.Errorf("ProgramIDs = %v, want %v", got, want)
+	}
+	var names []ProtocolName
+	for _, proto := range registry.Protocols(nil) {
+		names = append(names, proto.ProtocolName())
+	}
+	if want := []ProtocolName{"a2", "b"}; !slices.Equal(names, want) {
+		t.Errorf("Protocols = %v, want %v", names, want)
+	}
+	if proto, ok := registry.Protocol(nil, programA); !ok || proto.ProtocolName() != "a2" {
+		t.Errorf("Protocol(programA) = %v, %v, want the replacement", proto, ok)
+	}
+	if _, ok := registry.Protocol(nil, solana.NewWallet().PublicKey()); ok {
+		t.Errorf("Protocol found an unregistered program")
+	}
+}
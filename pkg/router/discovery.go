@@ -0,0 +1,156 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/solana-zh/solroute/pkg"
+)
+
+// DefaultProtocolTimeout bounds the pool discovery of a single protocol
+const DefaultProtocolTimeout = 15 * time.Second
+
+// ErrTooFewProtocols is reported when fewer protocols than required returned pools
+var ErrTooFewProtocols = errors.New("too few protocols succeeded")
+
+// ProtocolError is the discovery failure of one protocol
+type ProtocolError struct {
+	Protocol pkg.ProtocolName
+	Err      error
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Protocol, e.Err)
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// QueryError reports the protocols that failed during pool discovery.
+// The pools of the protocols that succeeded are still used; errors.Is(err, ErrTooFewProtocols)
+// tells whether fewer than Required protocols succeeded.
+type QueryError struct {
+	Failed    []*ProtocolError
+	Succeeded int
+	Required  int
+}
+
+func (e *QueryError) Error() string {
+	failures := make([]string, 0, len(e.Failed))
+	for _, failure := range e.Failed {
+		failures = append(failures, failure.Error())
+	}
+	msg := fmt.Sprintf("%d of %d protocols failed: %s", len(e.Failed), len(e.Failed)+e.Succeeded, strings.Join(failures, "; "))
+	if e.Succeeded < e.Required {
+		msg = fmt.Sprintf("%v (%d succeeded, %d required): %s", ErrTooFewProtocols, e.Succeeded, e.Required, msg)
+	}
+	return msg
+}
+
+func (e *QueryError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed)+1)
+	if e.Succeeded < e.Required {
+		errs = append(errs, ErrTooFewProtocols)
+	}
+	for _, failure := range e.Failed {
+		errs = append(errs, failure)
+	}
+	return errs
+}
+
+// discoverPools fetches the pools of every pair from every protocol concurrently.
+// Each protocol gets timeout to answer all of its pairs; a protocol fails when any of its
+// pairs fails. The returned error is a *QueryError when at least one protocol failed.
+func discoverPools(
+	ctx context.Context,
+	protocols []pkg.Protocol,
+	pairs [][2]string,
+	timeout time.Duration,
+	minSuccessful int,
+) ([]pkg.Pool, error) {
+	return collectPools(ctx, protocols, minSuccessful, func(ctx context.Context, proto pkg.Protocol) ([]pkg.Pool, error) {
+		return fetchProtocolPools(ctx, proto, pairs, timeout)
+	})
+}
+
+// collectPools runs fetch for every protocol concurrently and merges the pools of the
+// protocols that succeeded. The returned error is a *QueryError when at least one protocol
+// failed or fewer than minSuccessful succeeded.
+func collectPools(
+	ctx context.Context,
+	protocols []pkg.Protocol,
+	minSuccessful int,
+	fetch func(ctx context.Context, proto pkg.Protocol) ([]pkg.Pool, error),
+) ([]pkg.Pool, error) {
+	poolsByProtocol := make([][]pkg.Pool, len(protocols))
+	errsByProtocol := make([]error, len(protocols))
+
+	var wg sync.WaitGroup
+	for i, proto := range protocols {
+		wg.Add(1)
+		go func(i int, proto pkg.Protocol) {
+			defer wg.Done()
+			poolsByProtocol[i], errsByProtocol[i] = fetch(ctx, proto)
+		}(i, proto)
+	}
+	wg.Wait()
+
+	var allPools []pkg.Pool
+	queryErr := &QueryError{Required: minSuccessful}
+	for i, proto := range protocols {
+		if errsByProtocol[i] != nil {
+			log.Printf("error fetching pools from protocol %v: %v", proto.ProtocolName(), errsByProtocol[i])
+			queryErr.Failed = append(queryErr.Failed, &ProtocolError{Protocol: proto.ProtocolName(), Err: errsByProtocol[i]})
+			continue
+		}
+		queryErr.Succeeded++
+		allPools = append(allPools, poolsByProtocol[i]...)
+	}
+
+	if len(queryErr.Failed) > 0 || queryErr.Succeeded < queryErr.Required {
+		return allPools, queryErr
+	}
+	return allPools, nil
+}
+
+// fetchProtocolPools fetches the pools of every pair from one protocol within timeout
+func fetchProtocolPools(ctx context.Context, proto pkg.Protocol, pairs [][2]string, timeout time.Duration) ([]pkg.Pool, error) {
+	ctx, cancel := protocolContext(ctx, timeout)
+	defer cancel()
+
+	poolsByPair := make([][]pkg.Pool, len(pairs))
+	errsByPair := make([]error, len(pairs))
+	var wg sync.WaitGroup
+	for i, pair := range pairs {
+		wg.Add(1)
+		go func(i int, baseMint, quoteMint string) {
+			defer wg.Done()
+			log.Printf("😈Fetching %s/%s pools from protocol: %v", baseMint, quoteMint, proto.ProtocolName())
+			poolsByPair[i], errsByPair[i] = proto.FetchPoolsByPair(ctx, baseMint, quoteMint)
+		}(i, pair[0], pair[1])
+	}
+	wg.Wait()
+
+	var pools []pkg.Pool
+	for i, err := range errsByPair {
+		if err != nil {
+			return nil, fmt.Errorf("pair %s/%s: %w", pairs[i][0], pairs[i][1], err)
+		}
+		pools = append(pools, poolsByPair[i]...)
+	}
+	return pools, nil
+}
+
+// protocolContext bounds ctx by timeout; zero disables the bound
+func protocolContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,116 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/solana-zh/solroute/pkg"
+)
+
+var errFakeRPC = errors.New("rpc unavailable")
+
+// poolIDs returns the sorted IDs of pools
+func poolIDs(pools []pkg.Pool) []string {
+	ids := make([]string, len(pools))
+	for i, pool := range pools {
+		ids[i] = pool.GetID()
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestQueryAllPoolsKeepsSucceededProtocols(t *testing.T) {
+	failing := newFakeProtocol("failing", newFakePool("failing-pool", "IN", "OUT", 1e9, 1e9))
+	failing.err = errFakeRPC
+	hanging := newFakeProtocol("hanging", newFakePool("hanging-pool", "IN", "OUT", 1e9, 1e9))
+	hanging.hang = true
+	r := NewSimpleRouter(
+		newFakeProtocol("good", newFakePool("good-pool", "IN", "OUT", 1e9, 1e9), newFakePool("other-pool", "IN", "X", 1e9, 1e9)),
+		failing,
+		hanging,
+	)
+	r.ProtocolTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	err := r.QueryAllPools(context.Background(), "IN", "OUT")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("QueryAllPools waited %v for the hanging protocol", elapsed)
+	}
+	if got, want := poolIDs(r.Pools), []string{"good-pool"}; !slices.Equal(got, want) {
+		t.Errorf("pools = %v, want %v", got, want)
+	}
+
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) {
+		t.Fatalf("QueryAllPools error %v is not a *QueryError", err)
+	}
+	if queryErr.Succeeded != 1 || len(queryErr.Failed) != 2 {
+		t.Errorf("QueryError has %d succeeded and %d failed, want 1 and 2", queryErr.Succeeded, len(queryErr.Failed))
+	}
+	failed := map[pkg.ProtocolName]error{}
+	for _, failure := range queryErr.Failed {
+		failed[failure.Protocol] = failure
+	}
+	if !errors.Is(failed["failing"], errFakeRPC) {
+		t.Errorf("failing protocol reported %v, want %v", failed["failing"], errFakeRPC)
+	}
+	if !errors.Is(failed["hanging"], context.DeadlineExceeded) {
+		t.Errorf("hanging protocol reported %v, want %v", failed["hanging"], context.DeadlineExceeded)
+	}
+	// errors.Is sees every failure through Unwrap() []error
+	if !errors.Is(err, errFakeRPC) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("errors.Is does not find the protocol failures in %v", err)
+	}
+	if errors.Is(err, ErrTooFewProtocols) {
+		t.Errorf("one succeeded protocol of one required reported %v", ErrTooFewProtocols)
+	}
+}
+
+func TestQueryAllPoolsTooFewProtocols(t *testing.T) {
+	failing := newFakeProtocol("failing")
+	failing.err = errFakeRPC
+	for _, tt := range []struct {
+		name          string
+		protocols     []pkg.Protocol
+		minSuccessful int
+		wantTooFew    bool
+		wantErr       bool
+	}{
+		{"all succeed", []pkg.Protocol{newFakeProtocol("a"), newFakeProtocol("b")}, 2, false, false},
+		{"one of two required", []pkg.Protocol{newFakeProtocol("a"), failing}, 1, false, true},
+		{"two of two required", []pkg.Protocol{newFakeProtocol("a"), failing}, 2, true, true},
+		{"no protocols", nil, 1, true, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewSimpleRouter(tt.protocols...)
+			r.MinSuccessfulProtocols = tt.minSuccessful
+			err := r.QueryAllPools(context.Background(), "IN", "OUT")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("QueryAllPools error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrTooFewProtocols); got != tt.wantTooFew {
+				t.Errorf("errors.Is(%v, ErrTooFewProtocols) = %v, want %v", err, got, tt.wantTooFew)
+			}
+		})
+	}
+}
+
+func TestMultiHopQueryAllPoolsFailsProtocolOnAnyPair(t *testing.T) {
+	// Only the pools of the protocol that answered every candidate pair reach the graph
+	good := newFakeProtocol("good", newFakePool("in-a", "IN", "A", 1e9, 1e9), newFakePool("a-out", "A", "OUT", 1e9, 1e9))
+	failing := newFakeProtocol("failing", newFakePool("in-out", "IN", "OUT", 1e9, 1e9))
+	failing.err = errFakeRPC
+	r := NewMultiHopRouter([]string{"A"}, good, failing)
+	r.ProtocolTimeout = 50 * time.Millisecond
+
+	err := r.QueryAllPools(context.Background(), "IN", "OUT")
+	if !errors.Is(err, errFakeRPC) {
+		t.Errorf("QueryAllPools error = %v, want %v", err, errFakeRPC)
+	}
+	if got, want := poolIDs(r.Graph.Pools()), []string{"a-out", "in-a"}; !slices.Equal(got, want) {
+		t.Errorf("graph pools = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,273 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+const (
+	// DefaultPollInterval is how often a PoolFeed without subscriptions refreshes its pools
+	DefaultPollInterval = 2 * time.Second
+
+	// feedRefreshTimeout bounds the fetch of accounts a pool starts depending on after an update
+	feedRefreshTimeout = 10 * time.Second
+)
+
+// PoolFeed keeps the state of pools fresh in the background, so quoting them needs no network call.
+// With Subscriptions set it applies every change of a dependent account pushed over WebSocket;
+// otherwise Run polls the dependent accounts every PollInterval.
+type PoolFeed struct {
+	SolClient     *sol.Client
+	Subscriptions *sol.SubscriptionManager
+	PollInterval  time.Duration
+
+	// mu guards the maps below and serializes every ApplyUpdate made by the feed
+	mu       sync.Mutex
+	pools    map[string]pkg.Pool
+	accounts map[string][]solana.PublicKey
+	watchers map[solana.PublicKey]map[string]pkg.Pool
+	subIDs   map[solana.PublicKey]uint64
+	slots    map[solana.PublicKey]uint64
+}
+
+// NewPoolFeed creates a feed that fetches accounts with solClient.
+// subscriptions may be nil to poll instead; when set it must already be started.
+func NewPoolFeed(solClient *sol.Client, subscriptions *sol.SubscriptionManager) *PoolFeed {
+	return &PoolFeed{
+		SolClient:     solClient,
+		Subscriptions: subscriptions,
+		PollInterval:  DefaultPollInterval,
+		pools:         make(map[string]pkg.Pool),
+		accounts:      make(map[string][]solana.PublicKey),
+		watchers:      make(map[solana.PublicKey]map[string]pkg.Pool),
+		subIDs:        make(map[solana.PublicKey]uint64),
+		slots:         make(map[solana.PublicKey]uint64),
+	}
+}
+
+// Add starts keeping pools fresh and loads their current state in one batched RPC round.
+// Pools already in the feed are skipped. Pools whose state cannot be loaded are not added
+// and reported in the returned error.
+func (f *PoolFeed) Add(ctx context.Context, pools ...pkg.Pool) error {
+	var errs []error
+	var added []pkg.Pool
+	f.mu.Lock()
+	for _, pool := range pools {
+		if _, ok := f.pools[pool.GetID()]; ok {
+			continue
+		}
+		f.pools[pool.GetID()] = pool
+		// Subscribe before fetching so no change between the two is missed
+		if _, err := f.watchLocked(pool); err != nil {
+			errs = append(errs, err)
+		}
+		added = append(added, pool)
+	}
+	f.mu.Unlock()
+
+	for id, err := range f.refresh(ctx, added) {
+		f.Remove(id)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Remove stops updating the pools with the given IDs
+func (f *PoolFeed) Remove(poolIDs ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range poolIDs {
+		if _, ok := f.pools[id]; !ok {
+			continue
+		}
+		for _, account := range f.accounts[id] {
+			f.unwatchLocked(account, id)
+		}
+		delete(f.pools, id)
+		delete(f.accounts, id)
+	}
+}
+
+// Pool returns the pool with the given ID when it is in the feed
+func (f *PoolFeed) Pool(poolID string) (pkg.Pool, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pool, ok := f.pools[poolID]
+	return pool, ok
+}
+
+// Run polls the pools every PollInterval until ctx is done. With Subscriptions set
+// updates are pushed and Run only waits for ctx.
+func (f *PoolFeed) Run(ctx context.Context) error {
+	if f.Subscriptions != nil {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ticker := time.NewTicker(f.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		f.mu.Lock()
+		pools := make([]pkg.Pool, 0, len(f.pools))
+		for _, pool := range f.pools {
+			pools = append(pools, pool)
+		}
+		f.mu.Unlock()
+
+		for id, err := range f.refresh(ctx, pools) {
+			log.Printf("failed to refresh pool %s: %v", id, err)
+		}
+	}
+}
+
+// refresh fetches the dependent accounts of pools in one batch and watches the accounts they
+// depend on afterwards. It returns the errors of the pools that could not be refreshed.
+func (f *PoolFeed) refresh(ctx context.Context, pools []pkg.Pool) map[string]error {
+	wrapped := make([]pkg.Pool, len(pools))
+	for i, pool := range pools {
+		wrapped[i] = feedPool{Pool: pool, feed: f}
+	}
+	failed := pkg.RefreshPools(ctx, f.SolClient, wrapped)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, pool := range pools {
+		if _, ok := failed[pool.GetID()]; ok {
+			continue
+		}
+		if _, ok := f.pools[pool.GetID()]; !ok {
+			continue
+		}
+		if _, err := f.watchLocked(pool); err != nil {
+			log.Printf("failed to watch accounts of pool %s: %v", pool.GetID(), err)
+		}
+	}
+	return failed
+}
+
+// handleUpdate applies a pushed account change to every pool that depends on the account
+func (f *PoolFeed) handleUpdate(update sol.AccountUpdate) {
+	var moved []pkg.Pool
+	f.mu.Lock()
+	if update.Slot < f.slots[update.Account] {
+		f.mu.Unlock()
+		return
+	}
+	f.slots[update.Account] = update.Slot
+	for _, pool := range f.watchers[update.Account] {
+		if err := pool.ApplyUpdate(update.Account, update.Data, update.Slot); err != nil {
+			log.Printf("failed to apply account %s to pool %s: %v", update.Account, pool.GetID(), err)
+			continue
+		}
+		added, err := f.watchLocked(pool)
+		if err != nil {
+			log.Printf("failed to watch accounts of pool %s: %v", pool.GetID(), err)
+		}
+		if added > 0 {
+			moved = append(moved, pool)
+		}
+	}
+	f.mu.Unlock()
+
+	// Load the state of accounts the pools started depending on, e.g. the next tick arrays
+	if len(moved) > 0 {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), feedRefreshTimeout)
+			defer cancel()
+			for id, err := range f.refresh(ctx, moved) {
+				log.Printf("failed to refresh pool %s: %v", id, err)
+			}
+		}()
+	}
+}
+
+// watchLocked brings the watched accounts of pool in line with its current dependent accounts
+// and returns how many accounts the pool started depending on
+func (f *PoolFeed) watchLocked(pool pkg.Pool) (int, error) {
+	id := pool.GetID()
+	current := pool.DependentAccounts()
+	keep := make(map[solana.PublicKey]struct{}, len(current))
+	for _, account := range current {
+		keep[account] = struct{}{}
+	}
+	for _, account := range f.accounts[id] {
+		if _, ok := keep[account]; !ok {
+			f.unwatchLocked(account, id)
+		}
+	}
+	f.accounts[id] = current
+
+	added := 0
+	var errs []error
+	for _, account := range current {
+		if _, ok := f.watchers[account][id]; !ok {
+			added++
+		}
+		if f.watchers[account] == nil {
+			if f.Subscriptions != nil {
+				subID, err := f.Subscriptions.SubscribeAccount(account, f.handleUpdate)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("failed to subscribe to account %s: %w", account, err))
+					continue
+				}
+				f.subIDs[account] = subID
+			}
+			f.watchers[account] = make(map[string]pkg.Pool)
+		}
+		f.watchers[account][id] = pool
+	}
+	return added, errors.Join(errs...)
+}
+
+// unwatchLocked stops delivering account to the pool, unsubscribing when no pool watches it
+func (f *PoolFeed) unwatchLocked(account solana.PublicKey, poolID string) {
+	delete(f.watchers[account], poolID)
+	if len(f.watchers[account]) > 0 {
+		return
+	}
+	delete(f.watchers, account)
+	delete(f.slots, account)
+	if subID, ok := f.subIDs[account]; ok {
+		f.Subscriptions.Unsubscribe(subID)
+		delete(f.subIDs, account)
+	}
+}
+
+// feedPool serializes the updates RefreshPool applies to a pool with the ones pushed to the feed,
+// and skips account data older than what the feed already applied
+type feedPool struct {
+	pkg.Pool
+	feed *PoolFeed
+}
+
+func (p feedPool) DependentAccounts() []solana.PublicKey {
+	p.feed.mu.Lock()
+	defer p.feed.mu.Unlock()
+	return p.Pool.DependentAccounts()
+}
+
+func (p feedPool) ApplyUpdate(account solana.PublicKey, data []byte, slot uint64) error {
+	p.feed.mu.Lock()
+	defer p.feed.mu.Unlock()
+	if slot < p.feed.slots[account] {
+		return nil
+	}
+	if _, ok := p.feed.watchers[account]; ok {
+		p.feed.slots[account] = slot
+	}
+	return p.Pool.ApplyUpdate(account, data, slot)
+}
@@ -0,0 +1,63 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+func TestPoolFeedAddLoadsPoolsInOneBatch(t *testing.T) {
+	fake, client := newFakeRPC(t)
+	newAccount := func() solana.PublicKey {
+		key := solana.NewWallet().PublicKey()
+		fake.setAccount(key, key[:])
+		return key
+	}
+
+	// The pools share a mint account and have their own vaults
+	sharedMint := newAccount()
+	pools := make([]*fakePool, 60)
+	for i := range pools {
+		pools[i] = newFakePool(solana.NewWallet().PublicKey().String(), "IN", "OUT", 1e9, 1e9)
+		pools[i].accounts = []solana.PublicKey{newAccount(), newAccount(), sharedMint}
+	}
+
+	feed := NewPoolFeed(client, nil)
+	for _, pool := range pools[:30] {
+		if err := feed.Add(context.Background(), pool); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	before := len(fake.multipleAccountsBatches())
+	all := make([]pkg.Pool, len(pools))
+	for i, pool := range pools {
+		all[i] = pool
+	}
+	if err := feed.Add(context.Background(), all...); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	// Only the 30 pools not in the feed yet are loaded: 60 vaults and the shared mint
+	batches := fake.multipleAccountsBatches()[before:]
+	keys := 0
+	for _, batch := range batches {
+		keys += len(batch)
+	}
+	wantKeys := 30*2 + 1
+	if len(batches) != (wantKeys+sol.MaxMultipleAccounts-1)/sol.MaxMultipleAccounts || keys != wantKeys {
+		t.Errorf("Add fetched %d keys in %d calls, want %d keys in one call per %d", keys, len(batches), wantKeys, sol.MaxMultipleAccounts)
+	}
+	for _, pool := range pools {
+		if _, ok := feed.Pool(pool.id); !ok {
+			t.Errorf("pool %s not in the feed", pool.id)
+		}
+		for _, account := range pool.accounts {
+			if string(pool.appliedData(account)) != string(account[:]) {
+				t.Errorf("pool %s did not get the data of account %s", pool.id, account)
+			}
+		}
+	}
+}
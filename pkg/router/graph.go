@@ -0,0 +1,128 @@
+package router
+
+import (
+	"github.com/solana-zh/solroute/pkg"
+)
+
+// TokenGraph indexes pools by the pair of mints they connect
+type TokenGraph struct {
+	edges   map[string]map[string][]pkg.Pool
+	poolIDs map[string]struct{}
+	pools   []pkg.Pool
+}
+
+// NewTokenGraph creates an empty token graph
+func NewTokenGraph() *TokenGraph {
+	return &TokenGraph{
+		edges:   make(map[string]map[string][]pkg.Pool),
+		poolIDs: make(map[string]struct{}),
+	}
+}
+
+// AddPool adds a pool as an edge in both directions, ignoring pools already present
+func (g *TokenGraph) AddPool(pool pkg.Pool) {
+	if _, ok := g.poolIDs[pool.GetID()]; ok {
+		return
+	}
+	g.poolIDs[pool.GetID()] = struct{}{}
+	g.pools = append(g.pools, pool)
+
+	baseMint, quoteMint := pool.GetTokens()
+	g.addEdge(baseMint, quoteMint, pool)
+	g.addEdge(quoteMint, baseMint, pool)
+}
+
+func (g *TokenGraph) addEdge(from, to string, pool pkg.Pool) {
+	if g.edges[from] == nil {
+		g.edges[from] = make(map[string][]pkg.Pool)
+	}
+	g.edges[from][to] = append(g.edges[from][to], pool)
+}
+
+// RemovePool removes the pool with the given ID and its edges
+func (g *TokenGraph) RemovePool(poolID string) {
+	if _, ok := g.poolIDs[poolID]; !ok {
+		return
+	}
+	delete(g.poolIDs, poolID)
+	for _, pool := range g.pools {
+		if pool.GetID() != poolID {
+			continue
+		}
+		g.pools = withoutPool(g.pools, poolID)
+		baseMint, quoteMint := pool.GetTokens()
+		g.removeEdge(baseMint, quoteMint, poolID)
+		g.removeEdge(quoteMint, baseMint, poolID)
+		break
+	}
+}
+
+func (g *TokenGraph) removeEdge(from, to, poolID string) {
+	pools := withoutPool(g.edges[from][to], poolID)
+	if len(pools) > 0 {
+		g.edges[from][to] = pools
+		return
+	}
+	delete(g.edges[from], to)
+	if len(g.edges[from]) == 0 {
+		delete(g.edges, from)
+	}
+}
+
+// withoutPool returns a copy of pools without the pool with the given ID, leaving pools
+// unchanged for callers still holding it
+func withoutPool(pools []pkg.Pool, poolID string) []pkg.Pool {
+	kept := make([]pkg.Pool, 0, len(pools))
+	for _, pool := range pools {
+		if pool.GetID() != poolID {
+			kept = append(kept, pool)
+		}
+	}
+	return kept
+}
+
+// PoolsBetween returns every pool that swaps between the two mints
+func (g *TokenGraph) PoolsBetween(mintA, mintB string) []pkg.Pool {
+	return g.edges[mintA][mintB]
+}
+
+// Pools returns every distinct pool in the graph
+func (g *TokenGraph) Pools() []pkg.Pool {
+	return g.pools
+}
+
+// PoolCount returns the number of distinct pools in the graph
+func (g *TokenGraph) PoolCount() int {
+	return len(g.poolIDs)
+}
+
+// Paths returns every mint path from inputMint to outputMint with at most maxHops hops,
+// where each intermediate mint is taken from via and appears at most once
+func (g *TokenGraph) Paths(inputMint, outputMint string, via []string, maxHops int) [][]string {
+	var paths [][]string
+	visited := map[string]bool{inputMint: true}
+
+	var walk func(path []string)
+	walk = func(path []string) {
+		current := path[len(path)-1]
+		hops := len(path) - 1
+
+		if len(g.PoolsBetween(current, outputMint)) > 0 {
+			paths = append(paths, append(append([]string{}, path...), outputMint))
+		}
+		if hops+1 >= maxHops {
+			return
+		}
+		for _, mid := range via {
+			if visited[mid] || mid == outputMint || len(g.PoolsBetween(current, mid)) == 0 {
+				continue
+			}
+			visited[mid] = true
+			walk(append(path, mid))
+			visited[mid] = false
+		}
+	}
+	walk([]string{inputMint})
+
+	return paths
+}
@@ -0,0 +1,104 @@
+package router
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/solana-zh/solroute/pkg"
+)
+
+// DefaultIndexTimeout bounds the full pool scan of a single protocol
+const DefaultIndexTimeout = 5 * time.Minute
+
+// PoolIndex holds every known pool keyed by ID, by mint and by pair, so routers can
+// select pools without querying the network for each request
+type PoolIndex struct {
+	byID   map[string]pkg.Pool
+	byMint map[string][]pkg.Pool
+	graph  *TokenGraph
+}
+
+// NewPoolIndex creates an empty pool index
+func NewPoolIndex() *PoolIndex {
+	return &PoolIndex{
+		byID:   make(map[string]pkg.Pool),
+		byMint: make(map[string][]pkg.Pool),
+		graph:  NewTokenGraph(),
+	}
+}
+
+// BuildPoolIndex enumerates the pools of every protocol with FetchAllPools and indexes them.
+// Each protocol gets timeout to finish its scan; failures are reported as a *QueryError
+// like QueryAllPools, and the pools of the protocols that succeeded are still indexed.
+func BuildPoolIndex(ctx context.Context, protocols []pkg.Protocol, timeout time.Duration, minSuccessful int) (*PoolIndex, error) {
+	pools, err := collectPools(ctx, protocols, minSuccessful, func(ctx context.Context, proto pkg.Protocol) ([]pkg.Pool, error) {
+		ctx, cancel := protocolContext(ctx, timeout)
+		defer cancel()
+		log.Printf("😈Indexing all pools from protocol: %v", proto.ProtocolName())
+		return proto.FetchAllPools(ctx)
+	})
+
+	index := NewPoolIndex()
+	for _, pool := range pools {
+		index.Add(pool)
+	}
+	return index, err
+}
+
+// Add indexes a pool, ignoring pools already present
+func (idx *PoolIndex) Add(pool pkg.Pool) {
+	if _, ok := idx.byID[pool.GetID()]; ok {
+		return
+	}
+	idx.byID[pool.GetID()] = pool
+
+	baseMint, quoteMint := pool.GetTokens()
+	idx.byMint[baseMint] = append(idx.byMint[baseMint], pool)
+	if quoteMint != baseMint {
+		idx.byMint[quoteMint] = append(idx.byMint[quoteMint], pool)
+	}
+	idx.graph.AddPool(pool)
+}
+
+// Remove drops the pools with the given IDs from the index
+func (idx *PoolIndex) Remove(poolIDs ...string) {
+	for _, id := range poolIDs {
+		pool, ok := idx.byID[id]
+		if !ok {
+			continue
+		}
+		delete(idx.byID, id)
+
+		baseMint, quoteMint := pool.GetTokens()
+		for _, mint := range []string{baseMint, quoteMint} {
+			if pools := withoutPool(idx.byMint[mint], id); len(pools) > 0 {
+				idx.byMint[mint] = pools
+			} else {
+				delete(idx.byMint, mint)
+			}
+		}
+		idx.graph.RemovePool(id)
+	}
+}
+
+// Pool returns the pool with the given ID
+func (idx *PoolIndex) Pool(poolID string) (pkg.Pool, bool) {
+	pool, ok := idx.byID[poolID]
+	return pool, ok
+}
+
+// PoolsByMint returns every pool that holds mint on either side
+func (idx *PoolIndex) PoolsByMint(mint string) []pkg.Pool {
+	return idx.byMint[mint]
+}
+
+// PoolsByPair returns every pool that swaps between the two mints, in either orientation
+func (idx *PoolIndex) PoolsByPair(mintA, mintB string) []pkg.Pool {
+	return idx.graph.PoolsBetween(mintA, mintB)
+}
+
+// Len returns the number of indexed pools
+func (idx *PoolIndex) Len() int {
+	return len(idx.byID)
+}
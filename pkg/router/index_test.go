@@ -0,0 +1,90 @@
+package router
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/solana-zh/solroute/pkg"
+)
+
+func TestPoolIndex(t *testing.T) {
+	index := NewPoolIndex()
+	index.Add(newFakePool("in-out", "IN", "OUT", 1e9, 1e9))
+	index.Add(newFakePool("out-in", "OUT", "IN", 1e9, 1e9))
+	index.Add(newFakePool("in-a", "IN", "A", 1e9, 1e9))
+	// A pool already indexed is ignored
+	index.Add(newFakePool("in-a", "IN", "A", 1, 1))
+
+	if index.Len() != 3 {
+		t.Errorf("Len = %d, want 3", index.Len())
+	}
+	if pool, ok := index.Pool("in-a"); !ok || pool.(*fakePool).baseReserve.Int64() != 1e9 {
+		t.Errorf("Pool(in-a) = %v, %v, want the first pool added", pool, ok)
+	}
+	for _, tt := range []struct {
+		name string
+		got  []pkg.Pool
+		want []string
+	}{
+		{"pair IN/OUT", index.PoolsByPair("IN", "OUT"), []string{"in-out", "out-in"}},
+		{"pair OUT/IN", index.PoolsByPair("OUT", "IN"), []string{"in-out", "out-in"}},
+		{"pair A/IN", index.PoolsByPair("A", "IN"), []string{"in-a"}},
+		{"pair A/OUT", index.PoolsByPair("A", "OUT"), nil},
+		{"mint IN", index.PoolsByMint("IN"), []string{"in-a", "in-out", "out-in"}},
+		{"mint A", index.PoolsByMint("A"), []string{"in-a"}},
+	} {
+		if got := poolIDs(tt.got); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: pools = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	index.Remove("in-out", "in-a", "unknown")
+	if index.Len() != 1 {
+		t.Errorf("Len after Remove = %d, want 1", index.Len())
+	}
+	if _, ok := index.Pool("in-out"); ok {
+		t.Errorf("Pool(in-out) found a removed pool")
+	}
+	if got, want := poolIDs(index.PoolsByPair("OUT", "IN")), []string{"out-in"}; !slices.Equal(got, want) {
+		t.Errorf("PoolsByPair after Remove = %v, want %v", got, want)
+	}
+	if got := index.PoolsByPair("IN", "A"); len(got) != 0 {
+		t.Errorf("PoolsByPair(IN, A) after Remove = %v, want none", poolIDs(got))
+	}
+	if got := index.PoolsByMint("A"); len(got) != 0 {
+		t.Errorf("PoolsByMint(A) after Remove = %v, want none", poolIDs(got))
+	}
+	if got, want := poolIDs(index.PoolsByMint("IN")), []string{"out-in"}; !slices.Equal(got, want) {
+		t.Errorf("PoolsByMint(IN) after Remove = %v, want %v", got, want)
+	}
+}
+
+func TestRoutersUseIndex(t *testing.T) {
+	index := NewPoolIndex()
+	index.Add(newFakePool("in-out", "IN", "OUT", 1e9, 1e9))
+	index.Add(newFakePool("in-a", "IN", "A", 1e9, 1e9))
+	index.Add(newFakePool("a-out", "A", "OUT", 1e9, 1e9))
+	index.Add(newFakePool("a-b", "A", "B", 1e9, 1e9))
+	// The protocol fails any query, so the pools can only come from the index
+	failing := newFakeProtocol("failing")
+	failing.err = errFakeRPC
+
+	simple := NewSimpleRouter(failing)
+	simple.Index = index
+	if err := simple.QueryAllPools(context.Background(), "OUT", "IN"); err != nil {
+		t.Fatalf("SimpleRouter.QueryAllPools: %v", err)
+	}
+	if got, want := poolIDs(simple.Pools), []string{"in-out"}; !slices.Equal(got, want) {
+		t.Errorf("SimpleRouter pools = %v, want %v", got, want)
+	}
+
+	multiHop := NewMultiHopRouter([]string{"A"}, failing)
+	multiHop.Index = index
+	if err := multiHop.QueryAllPools(context.Background(), "IN", "OUT"); err != nil {
+		t.Fatalf("MultiHopRouter.QueryAllPools: %v", err)
+	}
+	if got, want := poolIDs(multiHop.Graph.Pools()), []string{"a-out", "in-a", "in-out"}; !slices.Equal(got, want) {
+		t.Errorf("MultiHopRouter pools = %v, want %v", got, want)
+	}
+}
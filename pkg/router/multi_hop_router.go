@@ -0,0 +1,185 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cosmossdk.io/math"
+	"github.com/solana-zh/solroute/pkg"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// DefaultIntermediateMints are the liquid mints routes may pass through
+var DefaultIntermediateMints = []string{
+	sol.WSOL.String(),
+	sol.USDC.String(),
+	sol.USDT.String(),
+}
+
+// DefaultMaxHops is the longest route searched by default
+const DefaultMaxHops = 3
+
+// MultiHopRouter finds routes that pass through intermediate mints when
+// the direct pools of a pair are shallow or missing
+type MultiHopRouter struct {
+	Protocols         []pkg.Protocol
+	IntermediateMints []string
+	MaxHops           int
+	Graph             *TokenGraph
+
+	// ProtocolTimeout bounds the pool discovery of each protocol; zero disables it
+	ProtocolTimeout time.Duration
+	// MinSuccessfulProtocols is the number of protocols that must answer without error for
+	// QueryAllPools to succeed; a protocol with no pool of the pair still counts
+	MinSuccessfulProtocols int
+	// Index, when set, answers QueryAllPools from memory instead of the protocols
+	Index *PoolIndex
+	// Feed, when set, keeps the graph's pools fresh in the background so quotes make no
+	// network call; otherwise the pools are refreshed before each route search
+	Feed *PoolFeed
+}
+
+// NewMultiHopRouter creates a router that searches 2- and 3-hop routes through intermediateMints.
+// DefaultIntermediateMints is used when intermediateMints is empty.
+func NewMultiHopRouter(intermediateMints []string, protocols ...pkg.Protocol) *MultiHopRouter {
+	if len(intermediateMints) == 0 {
+		intermediateMints = DefaultIntermediateMints
+	}
+	return &MultiHopRouter{
+		Protocols:              protocols,
+		IntermediateMints:      intermediateMints,
+		MaxHops:                DefaultMaxHops,
+		Graph:                  NewTokenGraph(),
+		ProtocolTimeout:        DefaultProtocolTimeout,
+		MinSuccessfulProtocols: 1,
+	}
+}
+
+// QueryAllPools fetches the pools of every pair that can appear on a route
+// between inputMint and outputMint and rebuilds the token graph from them.
+// Protocols are queried concurrently and failures are reported like SimpleRouter.QueryAllPools.
+// When Index is set the pools are taken from it without any network call.
+// When Feed is set the pools are added to it, which loads the state of pools it did not hold yet.
+func (r *MultiHopRouter) QueryAllPools(ctx context.Context, inputMint, outputMint string) error {
+	var pools []pkg.Pool
+	var err error
+	if r.Index != nil {
+		for _, pair := range r.candidatePairs(inputMint, outputMint) {
+			pools = append(pools, r.Index.PoolsByPair(pair[0], pair[1])...)
+		}
+	} else {
+		pools, err = discoverPools(ctx, r.Protocols, r.candidatePairs(inputMint, outputMint), r.ProtocolTimeout, r.MinSuccessfulProtocols)
+	}
+
+	graph := NewTokenGraph()
+	for _, pool := range pools {
+		graph.AddPool(pool)
+	}
+	r.Graph = graph
+	if r.Feed != nil {
+		if feedErr := r.Feed.Add(ctx, graph.Pools()...); feedErr != nil {
+			log.Printf("failed to add pools to feed: %v", feedErr)
+		}
+	}
+	return err
+}
+
+// candidatePairs lists the mint pairs a route of at most MaxHops hops can use
+func (r *MultiHopRouter) candidatePairs(inputMint, outputMint string) [][2]string {
+	pairs := [][2]string{{inputMint, outputMint}}
+	if r.MaxHops < 2 {
+		return pairs
+	}
+
+	mids := make([]string, 0, len(r.IntermediateMints))
+	for _, mint := range r.IntermediateMints {
+		if mint != inputMint && mint != outputMint {
+			mids = append(mids, mint)
+		}
+	}
+	for _, mid := range mids {
+		pairs = append(pairs, [2]string{inputMint, mid}, [2]string{mid, outputMint})
+	}
+	if r.MaxHops >= 3 {
+		for i := 0; i < len(mids); i++ {
+			for j := i + 1; j < len(mids); j++ {
+				pairs = append(pairs, [2]string{mids[i], mids[j]})
+			}
+		}
+	}
+	return pairs
+}
+
+// GetBestRoute searches every path in the token graph and returns the route with the largest output.
+// Each hop uses the pool that gives the best quote for the amount arriving from the previous hop.
+func (r *MultiHopRouter) GetBestRoute(ctx context.Context, solClient *sol.Client, inputMint, outputMint string, amountIn math.Int) (*Route, error) {
+	graph := r.Graph
+	if r.Feed == nil {
+		graph = NewTokenGraph()
+		for _, pool := range refreshPools(ctx, solClient, r.Graph.Pools()) {
+			graph.AddPool(pool)
+		}
+	}
+
+	type hopKey struct {
+		inputMint  string
+		outputMint string
+		amountIn   string
+	}
+	hopCache := make(map[hopKey]*Hop)
+
+	quoteHop := func(hopIn, hopOut string, hopAmount math.Int) (*Hop, error) {
+		key := hopKey{hopIn, hopOut, hopAmount.String()}
+		if hop, ok := hopCache[key]; ok {
+			return hop, nil
+		}
+		pool, quote, err := bestPool(ctx, graph.PoolsBetween(hopIn, hopOut), hopIn, hopAmount)
+		if err != nil {
+			return nil, err
+		}
+		hop := &Hop{
+			Pool:       pool,
+			InputMint:  hopIn,
+			OutputMint: hopOut,
+			AmountIn:   hopAmount,
+			AmountOut:  quote.AmountOut,
+			Quote:      quote,
+		}
+		hopCache[key] = hop
+		return hop, nil
+	}
+
+	var best *Route
+	for _, path := range graph.Paths(inputMint, outputMint, r.IntermediateMints, r.MaxHops) {
+		route := &Route{
+			InputMint:  inputMint,
+			OutputMint: outputMint,
+			AmountIn:   amountIn,
+		}
+		hopAmount := amountIn
+		for i := 0; i+1 < len(path); i++ {
+			hop, err := quoteHop(path[i], path[i+1], hopAmount)
+			if err != nil {
+				log.Printf("error quoting hop %s -> %s: %v", path[i], path[i+1], err)
+				route = nil
+				break
+			}
+			route.Hops = append(route.Hops, *hop)
+			hopAmount = hop.AmountOut
+		}
+		if route == nil || !hopAmount.IsPositive() {
+			continue
+		}
+		route.AmountOut = hopAmount
+		if best == nil || route.AmountOut.GT(best.AmountOut) {
+			best = route
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no route found")
+	}
+	return best, nil
+}
@@ -0,0 +1,160 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// fakePool is a constant product pool of two mints with a fee on the input. Its curve is concave
+// like the curves of real pools. It records the account data applied to it and the swaps it
+// builds instructions for.
+type fakePool struct {
+	id                  string
+	protocol            pkg.ProtocolName
+	baseMint, quoteMint string
+	baseReserve         math.Int
+	quoteReserve        math.Int
+	feeBps              int64
+	accounts            []solana.PublicKey
+	mu                  sync.Mutex
+	applied             map[solana.PublicKey][]byte
+	swaps               []fakeSwap
+}
+
+// fakeSwap is a call of fakePool.BuildSwapInstructions
+type fakeSwap struct {
+	inputMint    string
+	inputAmount  math.Int
+	outputAmount math.Int
+	mode         pkg.SwapMode
+}
+
+func newFakePool(id, baseMint, quoteMint string, baseReserve, quoteReserve int64) *fakePool {
+	return &fakePool{
+		id:           id,
+		protocol:     "fake",
+		baseMint:     baseMint,
+		quoteMint:    quoteMint,
+		baseReserve:  math.NewInt(baseReserve),
+		quoteReserve: math.NewInt(quoteReserve),
+		feeBps:       25,
+	}
+}
+
+func (p *fakePool) ProtocolName() pkg.ProtocolName        { return p.protocol }
+func (p *fakePool) GetProgramID() solana.PublicKey        { return solana.PublicKey{} }
+func (p *fakePool) GetID() string                         { return p.id }
+func (p *fakePool) GetTokens() (string, string)           { return p.baseMint, p.quoteMint }
+func (p *fakePool) DependentAccounts() []solana.PublicKey { return p.accounts }
+func (p *fakePool) Snapshot() pkg.PoolSnapshot            { return p }
+func (p *fakePool) Slot() uint64                          { return 1 }
+
+func (p *fakePool) ApplyUpdate(account solana.PublicKey, data []byte, _ uint64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.applied == nil {
+		p.applied = make(map[solana.PublicKey][]byte)
+	}
+	p.applied[account] = data
+	return nil
+}
+
+// appliedData returns the data last applied for account
+func (p *fakePool) appliedData(account solana.PublicKey) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.applied[account]
+}
+
+// reserves returns the output mint and the input and output reserves of a swap from inputMint
+func (p *fakePool) reserves(inputMint string) (string, math.Int, math.Int, error) {
+	switch inputMint {
+	case p.baseMint:
+		return p.quoteMint, p.baseReserve, p.quoteReserve, nil
+	case p.quoteMint:
+		return p.baseMint, p.quoteReserve, p.baseReserve, nil
+	default:
+		return "", math.Int{}, math.Int{}, fmt.Errorf("mint %s is not in pool %s", inputMint, p.id)
+	}
+}
+
+func (p *fakePool) Quote(_ context.Context, inputMint string, inputAmount math.Int) (*pkg.QuoteResult, error) {
+	outputMint, reserveIn, reserveOut, err := p.reserves(inputMint)
+	if err != nil {
+		return nil, err
+	}
+	fee := inputAmount.MulRaw(p.feeBps).QuoRaw(10000)
+	less := inputAmount.Sub(fee)
+	out := reserveOut.Mul(less).Quo(reserveIn.Add(less))
+	return &pkg.QuoteResult{
+		InputMint:  inputMint,
+		OutputMint: outputMint,
+		AmountIn:   inputAmount,
+		AmountOut:  out,
+		FeeAmount:  fee,
+		FeeMint:    inputMint,
+	}, nil
+}
+
+func (p *fakePool) QuoteExactOut(_ context.Context, inputMint string, outputAmount math.Int) (*pkg.QuoteResult, error) {
+	outputMint, reserveIn, reserveOut, err := p.reserves(inputMint)
+	if err != nil {
+		return nil, err
+	}
+	if outputAmount.GTE(reserveOut) {
+		return nil, fmt.Errorf("output %v exceeds the reserve of pool %s", outputAmount, p.id)
+	}
+	less := ceilDiv(reserveIn.Mul(outputAmount), reserveOut.Sub(outputAmount))
+	in := ceilDiv(less.MulRaw(10000), math.NewInt(10000-p.feeBps))
+	return &pkg.QuoteResult{
+		InputMint:  inputMint,
+		OutputMint: outputMint,
+		AmountIn:   in,
+		AmountOut:  outputAmount,
+		FeeAmount:  in.Sub(less),
+		FeeMint:    inputMint,
+	}, nil
+}
+
+func ceilDiv(a, b math.Int) math.Int {
+	return a.Add(b).SubRaw(1).Quo(b)
+}
+
+func (p *fakePool) BuildSwapInstructions(
+	_ context.Context,
+	_ *sol.Client,
+	_ solana.PublicKey,
+	inputMint string,
+	inputAmount math.Int,
+	outputAmount math.Int,
+	mode pkg.SwapMode,
+	_ solana.PublicKey,
+	_ solana.PublicKey,
+) ([]solana.Instruction, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.swaps = append(p.swaps, fakeSwap{inputMint, inputAmount, outputAmount, mode})
+	return []solana.Instruction{solana.NewInstruction(solana.SystemProgramID, nil, []byte(p.id))}, nil
+}
+
+// builtSwaps returns the swaps the pool built instructions for
+func (p *fakePool) builtSwaps() []fakeSwap {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]fakeSwap(nil), p.swaps...)
+}
+
+// tokenAccountsFor maps every mint to a fresh token account
+func tokenAccountsFor(mints ...string) map[string]solana.PublicKey {
+	accounts := make(map[string]solana.PublicKey, len(mints))
+	for _, mint := range mints {
+		accounts[mint] = solana.NewWallet().PublicKey()
+	}
+	return accounts
+}
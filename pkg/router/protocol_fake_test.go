@@ -0,0 +1,74 @@
+package router
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
+)
+
+// fakeProtocol serves a fixed set of pools. It fails every call with err when err is set,
+// and blocks until its context is done when hang is set.
+type fakeProtocol struct {
+	name  pkg.ProtocolName
+	pools []pkg.Pool
+	err   error
+	hang  bool
+}
+
+func newFakeProtocol(name string, pools ...*fakePool) *fakeProtocol {
+	proto := &fakeProtocol{name: pkg.ProtocolName(name)}
+	for _, pool := range pools {
+		proto.pools = append(proto.pools, pool)
+	}
+	return proto
+}
+
+func (p *fakeProtocol) ProtocolName() pkg.ProtocolName { return p.name }
+
+// wait returns the error the protocol answers with
+func (p *fakeProtocol) wait(ctx context.Context) error {
+	if p.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return p.err
+}
+
+func (p *fakeProtocol) FetchPoolsByPair(ctx context.Context, baseMint, quoteMint string) ([]pkg.Pool, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+	var pools []pkg.Pool
+	for _, pool := range p.pools {
+		base, quote := pool.GetTokens()
+		if (base == baseMint && quote == quoteMint) || (base == quoteMint && quote == baseMint) {
+			pools = append(pools, pool)
+		}
+	}
+	return pools, nil
+}
+
+func (p *fakeProtocol) FetchPoolByID(ctx context.Context, poolID string) (pkg.Pool, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+	for _, pool := range p.pools {
+		if pool.GetID() == poolID {
+			return pool, nil
+		}
+	}
+	return nil, fmt.Errorf("pool %s not found", poolID)
+}
+
+func (p *fakeProtocol) FetchAllPools(ctx context.Context) ([]pkg.Pool, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+	return p.pools, nil
+}
+
+func (p *fakeProtocol) DecodePool(context.Context, solana.PublicKey, []byte) (pkg.Pool, error) {
+	return nil, fmt.Errorf("protocol %s decodes no pools", p.name)
+}
@@ -0,0 +1,120 @@
+package router
+
+import (
+	"context"
+	"fmt"
+
+	"cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// Hop is a single swap through one pool inside a route
+type Hop struct {
+	Pool       pkg.Pool
+	InputMint  string
+	OutputMint string
+	AmountIn   math.Int
+	AmountOut  math.Int
+	Quote      *pkg.QuoteResult
+}
+
+// Route is an ordered list of hops that takes InputMint to OutputMint
+type Route struct {
+	InputMint  string
+	OutputMint string
+	AmountIn   math.Int
+	AmountOut  math.Int
+	Hops       []Hop
+}
+
+// FeeAmounts returns the fees paid on every hop, summed per fee mint
+func (r *Route) FeeAmounts() map[string]math.Int {
+	fees := make(map[string]math.Int)
+	for _, hop := range r.Hops {
+		if hop.Quote == nil || hop.Quote.FeeAmount.IsNil() {
+			continue
+		}
+		if fee, ok := fees[hop.Quote.FeeMint]; ok {
+			fees[hop.Quote.FeeMint] = fee.Add(hop.Quote.FeeAmount)
+		} else {
+			fees[hop.Quote.FeeMint] = hop.Quote.FeeAmount
+		}
+	}
+	return fees
+}
+
+// Mints returns the mint path of the route, starting with the input mint
+func (r *Route) Mints() []string {
+	mints := []string{r.InputMint}
+	for _, hop := range r.Hops {
+		mints = append(mints, hop.OutputMint)
+	}
+	return mints
+}
+
+// BuildSwapInstructions builds the instructions of every hop into a single list.
+// tokenAccounts maps each mint on the route to the user's token account for it.
+// Every hop spends its quoted input, so each intermediate hop must deliver at least the quoted
+// amount the next hop spends; slippageBps only applies once, to the output of the last hop.
+func (r *Route) BuildSwapInstructions(
+	ctx context.Context,
+	solClient *sol.Client,
+	user solana.PublicKey,
+	tokenAccounts map[string]solana.PublicKey,
+	slippageBps int,
+) ([]solana.Instruction, error) {
+	if len(r.Hops) == 0 {
+		return nil, fmt.Errorf("route has no hops")
+	}
+	if err := checkSlippage(slippageBps); err != nil {
+		return nil, err
+	}
+
+	instrs := make([]solana.Instruction, 0)
+	for i, hop := range r.Hops {
+		minOut := hop.AmountOut
+		if i == len(r.Hops)-1 {
+			minOut = applySlippage(hop.AmountOut, slippageBps)
+		}
+
+		userBaseAccount, userQuoteAccount, err := poolUserAccounts(hop.Pool, tokenAccounts)
+		if err != nil {
+			return nil, fmt.Errorf("hop %d: %w", i, err)
+		}
+		hopInstrs, err := hop.Pool.BuildSwapInstructions(ctx, solClient, user, hop.InputMint, hop.AmountIn, minOut, pkg.SwapModeExactIn, userBaseAccount, userQuoteAccount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build instructions for hop %d through pool %s: %w", i, hop.Pool.GetID(), err)
+		}
+		instrs = append(instrs, hopInstrs...)
+	}
+	return instrs, nil
+}
+
+// poolUserAccounts picks the user's token accounts in the base/quote order of the pool
+func poolUserAccounts(pool pkg.Pool, tokenAccounts map[string]solana.PublicKey) (solana.PublicKey, solana.PublicKey, error) {
+	baseMint, quoteMint := pool.GetTokens()
+	userBaseAccount, ok := tokenAccounts[baseMint]
+	if !ok {
+		return solana.PublicKey{}, solana.PublicKey{}, fmt.Errorf("no token account for mint %s", baseMint)
+	}
+	userQuoteAccount, ok := tokenAccounts[quoteMint]
+	if !ok {
+		return solana.PublicKey{}, solana.PublicKey{}, fmt.Errorf("no token account for mint %s", quoteMint)
+	}
+	return userBaseAccount, userQuoteAccount, nil
+}
+
+// checkSlippage rejects slippage outside 0 to 10000 basis points
+func checkSlippage(slippageBps int) error {
+	if slippageBps < 0 || slippageBps > 10000 {
+		return fmt.Errorf("slippage of %d bps is outside 0 to 10000", slippageBps)
+	}
+	return nil
+}
+
+// applySlippage reduces amount by slippageBps basis points, which checkSlippage accepted
+func applySlippage(amount math.Int, slippageBps int) math.Int {
+	return amount.Mul(math.NewInt(int64(10000 - slippageBps))).Quo(math.NewInt(10000))
+}
@@ -0,0 +1,179 @@
+package router
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
+)
+
+// testGraph builds a token graph of pools
+func testGraph(pools ...*fakePool) *TokenGraph {
+	graph := NewTokenGraph()
+	for _, pool := range pools {
+		graph.AddPool(pool)
+	}
+	return graph
+}
+
+func TestTokenGraphPaths(t *testing.T) {
+	graph := testGraph(
+		newFakePool("in-out", "IN", "OUT", 1e9, 1e9),
+		newFakePool("in-a", "IN", "A", 1e9, 1e9),
+		newFakePool("a-out", "A", "OUT", 1e9, 1e9),
+		newFakePool("a-b", "A", "B", 1e9, 1e9),
+		newFakePool("out-b", "OUT", "B", 1e9, 1e9),
+		// C is not an intermediate mint
+		newFakePool("in-c", "IN", "C", 1e9, 1e9),
+		newFakePool("c-out", "C", "OUT", 1e9, 1e9),
+	)
+	for _, tt := range []struct {
+		maxHops int
+		want    []string
+	}{
+		{1, []string{"IN-OUT"}},
+		{2, []string{"IN-OUT", "IN-A-OUT"}},
+		{3, []string{"IN-OUT", "IN-A-OUT", "IN-A-B-OUT"}},
+	} {
+		var got []string
+		for _, path := range graph.Paths("IN", "OUT", []string{"A", "B"}, tt.maxHops) {
+			got = append(got, strings.Join(path, "-"))
+		}
+		slices.Sort(got)
+		slices.Sort(tt.want)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Paths with %d hops = %v, want %v", tt.maxHops, got, tt.want)
+		}
+	}
+}
+
+// chainQuotes quotes amountIn through pools in turn, starting with inputMint
+func chainQuotes(t *testing.T, inputMint string, amountIn math.Int, pools ...*fakePool) math.Int {
+	t.Helper()
+	amount := amountIn
+	for _, pool := range pools {
+		quote, err := pool.Quote(context.Background(), inputMint, amount)
+		if err != nil {
+			t.Fatalf("Quote(%s): %v", pool.id, err)
+		}
+		inputMint, amount = quote.OutputMint, quote.AmountOut
+	}
+	return amount
+}
+
+// routePools returns the IDs of the pools of route's hops
+func routePools(route *Route) []string {
+	ids := make([]string, len(route.Hops))
+	for i, hop := range route.Hops {
+		ids[i] = hop.Pool.GetID()
+	}
+	return ids
+}
+
+func TestGetBestRoute(t *testing.T) {
+	inA := newFakePool("in-a", "IN", "A", 1e12, 1e12)
+	aOut := newFakePool("a-out", "A", "OUT", 1e12, 1e12)
+	aOutShallow := newFakePool("a-out-shallow", "A", "OUT", 1e7, 1e7)
+	direct := newFakePool("in-out", "IN", "OUT", 1e7, 1e7)
+	aB := newFakePool("a-b", "A", "B", 1e12, 1e12)
+	bOut := newFakePool("b-out", "B", "OUT", 1e8, 1e8)
+	r := &MultiHopRouter{
+		IntermediateMints: []string{"A", "B"},
+		MaxHops:           3,
+		Graph:             testGraph(direct, inA, aOutShallow, aOut, aB, bOut),
+	}
+
+	amountIn := math.NewInt(1_000_000)
+	route, err := r.GetBestRoute(context.Background(), nil, "IN", "OUT", amountIn)
+	if err != nil {
+		t.Fatalf("GetBestRoute: %v", err)
+	}
+	// The deep pools through A beat the shallow direct pool and the 3-hop route through B,
+	// and the A -> OUT hop takes the deeper of its two pools
+	if got, want := routePools(route), []string{"in-a", "a-out"}; !slices.Equal(got, want) {
+		t.Fatalf("route through %v, want %v", got, want)
+	}
+	if want := chainQuotes(t, "IN", amountIn, inA, aOut); !route.AmountOut.Equal(want) {
+		t.Errorf("AmountOut = %v, want %v", route.AmountOut, want)
+	}
+	if got, want := route.Mints(), []string{"IN", "A", "OUT"}; !slices.Equal(got, want) {
+		t.Errorf("Mints = %v, want %v", got, want)
+	}
+
+	r.MaxHops = 1
+	route, err = r.GetBestRoute(context.Background(), nil, "IN", "OUT", amountIn)
+	if err != nil {
+		t.Fatalf("GetBestRoute with 1 hop: %v", err)
+	}
+	if got, want := routePools(route), []string{"in-out"}; !slices.Equal(got, want) {
+		t.Errorf("1-hop route through %v, want %v", got, want)
+	}
+
+	if _, err := r.GetBestRoute(context.Background(), nil, "IN", "NONE", amountIn); err == nil {
+		t.Errorf("GetBestRoute found a route to a mint without pools")
+	}
+}
+
+func TestRouteBuildSwapInstructions(t *testing.T) {
+	inA := newFakePool("in-a", "IN", "A", 1e12, 2e12)
+	aB := newFakePool("a-b", "A", "B", 1e12, 1e12)
+	bOut := newFakePool("b-out", "OUT", "B", 3e12, 1e12)
+	r := &MultiHopRouter{
+		IntermediateMints: []string{"A", "B"},
+		MaxHops:           3,
+		Graph:             testGraph(inA, aB, bOut),
+	}
+	route, err := r.GetBestRoute(context.Background(), nil, "IN", "OUT", math.NewInt(1_000_000))
+	if err != nil {
+		t.Fatalf("GetBestRoute: %v", err)
+	}
+	if len(route.Hops) != 3 {
+		t.Fatalf("route has %d hops, want 3", len(route.Hops))
+	}
+
+	user := solana.NewWallet().PublicKey()
+	accounts := tokenAccountsFor("IN", "A", "B", "OUT")
+	for _, slippageBps := range []int{-1, 10001} {
+		if _, err := route.BuildSwapInstructions(context.Background(), nil, user, accounts, slippageBps); err == nil {
+			t.Errorf("BuildSwapInstructions accepted slippage of %d bps", slippageBps)
+		}
+	}
+	instrs, err := route.BuildSwapInstructions(context.Background(), nil, user, accounts, 50)
+	if err != nil {
+		t.Fatalf("BuildSwapInstructions: %v", err)
+	}
+	if len(instrs) != 3 {
+		t.Errorf("built %d instructions, want one per hop", len(instrs))
+	}
+
+	// Each hop spends what the previous one was quoted to deliver, which intermediate hops must
+	// deliver in full; only the final output is reduced by the slippage
+	hop0, hop1 := route.Hops[0], route.Hops[1]
+	for _, tt := range []struct {
+		pool      *fakePool
+		inputMint string
+		amountIn  math.Int
+		minOut    math.Int
+	}{
+		{inA, "IN", route.AmountIn, hop0.AmountOut},
+		{aB, "A", hop0.AmountOut, hop1.AmountOut},
+		{bOut, "B", hop1.AmountOut, route.AmountOut.MulRaw(9950).QuoRaw(10000)},
+	} {
+		swaps := tt.pool.builtSwaps()
+		if len(swaps) != 1 {
+			t.Fatalf("pool %s built %d swaps, want 1", tt.pool.id, len(swaps))
+		}
+		swap := swaps[0]
+		if swap.mode != pkg.SwapModeExactIn || swap.inputMint != tt.inputMint {
+			t.Errorf("pool %s swaps %s in mode %v, want exact in of %s", tt.pool.id, swap.inputMint, swap.mode, tt.inputMint)
+		}
+		if !swap.inputAmount.Equal(tt.amountIn) || !swap.outputAmount.Equal(tt.minOut) {
+			t.Errorf("pool %s swaps %v for at least %v, want %v for at least %v",
+				tt.pool.id, swap.inputAmount, swap.outputAmount, tt.amountIn, tt.minOut)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// fakeRPC is a JSON-RPC server serving getMultipleAccounts from a fixed set of accounts.
+// It records the keys of every request.
+type fakeRPC struct {
+	mu       sync.Mutex
+	accounts map[solana.PublicKey][]byte
+	batches  [][]solana.PublicKey
+}
+
+func newFakeRPC(t *testing.T) (*fakeRPC, *sol.Client) {
+	t.Helper()
+	fake := &fakeRPC{accounts: make(map[solana.PublicKey][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client, err := sol.NewClient(context.Background(), server.URL, "", 1000)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return fake, client
+}
+
+func (f *fakeRPC) setAccount(key solana.PublicKey, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accounts[key] = data
+}
+
+// multipleAccountsBatches returns the keys of every getMultipleAccounts request
+func (f *fakeRPC) multipleAccountsBatches() [][]solana.PublicKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]solana.PublicKey(nil), f.batches...)
+}
+
+func (f *fakeRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if req.Method != "getMultipleAccounts" {
+		resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found: " + req.Method}
+	} else {
+		var keys []solana.PublicKey
+		json.Unmarshal(req.Params[0], &keys)
+		f.mu.Lock()
+		f.batches = append(f.batches, keys)
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			data, ok := f.accounts[key]
+			if !ok {
+				continue
+			}
+			values[i] = map[string]interface{}{
+				"data":       []string{base64.StdEncoding.EncodeToString(data), "base64"},
+				"executable": false,
+				"lamports":   1,
+				"owner":      solana.SystemProgramID,
+				"rentEpoch":  0,
+			}
+		}
+		f.mu.Unlock()
+		resp["result"] = map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": values}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
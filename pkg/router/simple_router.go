@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"cosmossdk.io/math"
 	"github.com/solana-zh/solroute/pkg"
@@ -14,54 +15,106 @@ import (
 type SimpleRouter struct {
 	Protocols []pkg.Protocol
 	Pools     []pkg.Pool
+
+	// ProtocolTimeout bounds the pool discovery of each protocol; zero disables it
+	ProtocolTimeout time.Duration
+	// MinSuccessfulProtocols is the number of protocols that must answer without error for
+	// QueryAllPools to succeed; a protocol with no pool of the pair still counts
+	MinSuccessfulProtocols int
+	// Index, when set, answers QueryAllPools from memory instead of the protocols
+	Index *PoolIndex
+	// Feed, when set, keeps the queried pools fresh in the background so quotes make no
+	// network call; otherwise the pools are refreshed before each quote
+	Feed *PoolFeed
 }
 
 func NewSimpleRouter(protocols ...pkg.Protocol) *SimpleRouter {
 	return &SimpleRouter{
-		Protocols: protocols,
-		Pools:     []pkg.Pool{},
+		Protocols:              protocols,
+		Pools:                  []pkg.Pool{},
+		ProtocolTimeout:        DefaultProtocolTimeout,
+		MinSuccessfulProtocols: 1,
 	}
 }
 
+// QueryAllPools fetches the pools of the pair from every protocol concurrently.
+// The pools of the protocols that answered are kept even when others fail; the failures are
+// reported as a *QueryError, which wraps ErrTooFewProtocols when fewer than
+// MinSuccessfulProtocols protocols succeeded.
+// When Index is set the pools are taken from it without any network call.
+// When Feed is set the pools are added to it, which loads the state of pools it did not hold yet.
 func (r *SimpleRouter) QueryAllPools(ctx context.Context, baseMint, quoteMint string) error {
-	var allPools []pkg.Pool
-
-	// Loop through each protocol sequentially
-	for _, proto := range r.Protocols {
-		log.Printf("😈Fetching pools from protocol: %v", proto.ProtocolName())
-		pools, err := proto.FetchPoolsByPair(ctx, baseMint, quoteMint)
-		if err != nil {
-			log.Printf("error fetching pools from protocol: %v", err)
-			continue
+	var pools []pkg.Pool
+	var err error
+	if r.Index != nil {
+		pools = r.Index.PoolsByPair(baseMint, quoteMint)
+	} else {
+		pools, err = discoverPools(ctx, r.Protocols, [][2]string{{baseMint, quoteMint}}, r.ProtocolTimeout, r.MinSuccessfulProtocols)
+	}
+	r.Pools = pools
+	if r.Feed != nil {
+		if feedErr := r.Feed.Add(ctx, pools...); feedErr != nil {
+			log.Printf("failed to add pools to feed: %v", feedErr)
 		}
-		allPools = append(allPools, pools...)
 	}
+	return err
+}
+
+// freshPools returns the router's pools with up-to-date state
+func (r *SimpleRouter) freshPools(ctx context.Context, solClient *sol.Client) []pkg.Pool {
+	if r.Feed != nil {
+		return r.Pools
+	}
+	return refreshPools(ctx, solClient, r.Pools)
+}
+
+// GetBestPool returns the pool with the largest output for amountIn and its quote
+func (r *SimpleRouter) GetBestPool(ctx context.Context, solClient *sol.Client, tokenIn string, amountIn math.Int) (pkg.Pool, *pkg.QuoteResult, error) {
+	return bestPool(ctx, r.freshPools(ctx, solClient), tokenIn, amountIn)
+}
 
-	r.Pools = allPools
-	return nil
+// GetBestPoolExactOut returns the pool that needs the smallest input to deliver exactly amountOut
+func (r *SimpleRouter) GetBestPoolExactOut(ctx context.Context, solClient *sol.Client, tokenIn string, amountOut math.Int) (pkg.Pool, *pkg.QuoteResult, error) {
+	return bestPoolExactOut(ctx, r.freshPools(ctx, solClient), tokenIn, amountOut)
 }
 
-func (r *SimpleRouter) GetBestPool(ctx context.Context, solClient *sol.Client, tokenIn string, amountIn math.Int) (pkg.Pool, math.Int, error) {
-	type quoteResult struct {
-		pool      pkg.Pool
-		outAmount math.Int
-		err       error
+// refreshPools loads the current state of every pool in one batched RPC round and returns the
+// pools that were refreshed; pools that fail are logged and left out so they are not quoted on stale state
+func refreshPools(ctx context.Context, solClient *sol.Client, pools []pkg.Pool) []pkg.Pool {
+	failed := pkg.RefreshPools(ctx, solClient, pools)
+	fresh := make([]pkg.Pool, 0, len(pools))
+	for _, pool := range pools {
+		if err, ok := failed[pool.GetID()]; ok {
+			log.Printf("error refreshing pool %s: %v", pool.GetID(), err)
+			continue
+		}
+		fresh = append(fresh, pool)
 	}
+	return fresh
+}
+
+type poolQuote struct {
+	pool  pkg.Pool
+	quote *pkg.QuoteResult
+	err   error
+}
 
+// quotePools runs quote for every pool concurrently and streams the results
+func quotePools(pools []pkg.Pool, quote func(pkg.Pool) (*pkg.QuoteResult, error)) <-chan poolQuote {
 	// Create a channel to collect results
-	resultChan := make(chan quoteResult, len(r.Pools))
+	resultChan := make(chan poolQuote, len(pools))
 	var wg sync.WaitGroup
 
 	// Launch goroutines for each pool
-	for _, pool := range r.Pools {
+	for _, pool := range pools {
 		wg.Add(1)
 		go func(p pkg.Pool) {
 			defer wg.Done()
-			outAmount, err := p.Quote(ctx, solClient, tokenIn, amountIn)
-			resultChan <- quoteResult{
-				pool:      p,
-				outAmount: outAmount,
-				err:       err,
+			result, err := quote(p)
+			resultChan <- poolQuote{
+				pool:  p,
+				quote: result,
+				err:   err,
 			}
 		}(pool)
 	}
@@ -71,24 +124,64 @@ func (r *SimpleRouter) GetBestPool(ctx context.Context, solClient *sol.Client, t
 		wg.Wait()
 		close(resultChan)
 	}()
+	return resultChan
+}
+
+// bestPool quotes every pool concurrently and returns the one with the largest output
+func bestPool(ctx context.Context, pools []pkg.Pool, tokenIn string, amountIn math.Int) (pkg.Pool, *pkg.QuoteResult, error) {
+	results := quotePools(pools, func(p pkg.Pool) (*pkg.QuoteResult, error) {
+		return p.Quote(ctx, tokenIn, amountIn)
+	})
 
 	// Collect results and find the best one
 	var best pkg.Pool
-	maxOut := math.NewInt(0)
+	var bestQuote *pkg.QuoteResult
 
-	for result := range resultChan {
+	for result := range results {
 		if result.err != nil {
 			log.Printf("error quoting pool %s: %v", result.pool.GetID(), result.err)
 			continue
 		}
-		if result.outAmount.GT(maxOut) {
-			maxOut = result.outAmount
+		if !result.quote.AmountOut.IsPositive() {
+			continue
+		}
+		if bestQuote == nil || result.quote.AmountOut.GT(bestQuote.AmountOut) {
+			bestQuote = result.quote
+			best = result.pool
+		}
+	}
+
+	if best == nil {
+		return nil, nil, fmt.Errorf("no route found")
+	}
+	return best, bestQuote, nil
+}
+
+// bestPoolExactOut quotes every pool concurrently and returns the one with the smallest required input
+func bestPoolExactOut(ctx context.Context, pools []pkg.Pool, tokenIn string, amountOut math.Int) (pkg.Pool, *pkg.QuoteResult, error) {
+	results := quotePools(pools, func(p pkg.Pool) (*pkg.QuoteResult, error) {
+		return p.QuoteExactOut(ctx, tokenIn, amountOut)
+	})
+
+	var best pkg.Pool
+	var bestQuote *pkg.QuoteResult
+
+	for result := range results {
+		if result.err != nil {
+			log.Printf("error quoting exact out for pool %s: %v", result.pool.GetID(), result.err)
+			continue
+		}
+		if !result.quote.AmountIn.IsPositive() {
+			continue
+		}
+		if bestQuote == nil || result.quote.AmountIn.LT(bestQuote.AmountIn) {
+			bestQuote = result.quote
 			best = result.pool
 		}
 	}
 
 	if best == nil {
-		return nil, math.ZeroInt(), fmt.Errorf("no route found")
+		return nil, nil, fmt.Errorf("no route found")
 	}
-	return best, maxOut, nil
+	return best, bestQuote, nil
 }
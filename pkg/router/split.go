@@ -0,0 +1,176 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// DefaultSplitStepPercent is the granularity used to divide a split order
+const DefaultSplitStepPercent = 10
+
+// Allocation is the part of a split order sent through one pool
+type Allocation struct {
+	Pool      pkg.Pool
+	Percent   int
+	AmountIn  math.Int
+	AmountOut math.Int
+	Quote     *pkg.QuoteResult
+}
+
+// SplitRoute divides one order for a pair across several pools
+type SplitRoute struct {
+	InputMint   string
+	OutputMint  string
+	AmountIn    math.Int
+	AmountOut   math.Int
+	Allocations []Allocation
+}
+
+// BuildSwapInstructions builds the swap instructions of every allocation into a single list.
+// tokenAccounts maps the input and output mints to the user's token accounts for them.
+func (s *SplitRoute) BuildSwapInstructions(
+	ctx context.Context,
+	solClient *sol.Client,
+	user solana.PublicKey,
+	tokenAccounts map[string]solana.PublicKey,
+	slippageBps int,
+) ([]solana.Instruction, error) {
+	if err := checkSlippage(slippageBps); err != nil {
+		return nil, err
+	}
+	instrs := make([]solana.Instruction, 0)
+	for _, alloc := range s.Allocations {
+		userBaseAccount, userQuoteAccount, err := poolUserAccounts(alloc.Pool, tokenAccounts)
+		if err != nil {
+			return nil, err
+		}
+		minOut := applySlippage(alloc.AmountOut, slippageBps)
+		allocInstrs, err := alloc.Pool.BuildSwapInstructions(ctx, solClient, user, s.InputMint, alloc.AmountIn, minOut, pkg.SwapModeExactIn, userBaseAccount, userQuoteAccount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build instructions for pool %s: %w", alloc.Pool.GetID(), err)
+		}
+		instrs = append(instrs, allocInstrs...)
+	}
+	return instrs, nil
+}
+
+// GetBestSplit divides amountIn into stepPercent slices and assigns each slice to the pool
+// with the largest marginal output. Pool curves are concave, so assigning slices greedily
+// maximizes the total output for the chosen step size.
+func (r *SimpleRouter) GetBestSplit(ctx context.Context, solClient *sol.Client, tokenIn string, amountIn math.Int, stepPercent int) (*SplitRoute, error) {
+	if stepPercent <= 0 || stepPercent > 100 || 100%stepPercent != 0 {
+		return nil, fmt.Errorf("invalid split step %d%%: must divide 100", stepPercent)
+	}
+	pools := r.freshPools(ctx, solClient)
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("no route found")
+	}
+	steps := 100 / stepPercent
+
+	stepAmount := func(k int) math.Int {
+		return amountIn.MulRaw(int64(k * stepPercent)).QuoRaw(100)
+	}
+
+	// quotes[i][k] is the quote of pool i for k slices; nil when the pool can't fill it
+	quotes := make([][]*pkg.QuoteResult, len(pools))
+	snapshots := make([]pkg.PoolSnapshot, len(pools))
+	var wg sync.WaitGroup
+	for i, pool := range pools {
+		wg.Add(1)
+		go func(i int, p pkg.Pool) {
+			defer wg.Done()
+			quotes[i] = make([]*pkg.QuoteResult, steps+1)
+			quotes[i][0] = &pkg.QuoteResult{AmountIn: math.ZeroInt(), AmountOut: math.ZeroInt()}
+			// Quote every step against one snapshot so the steps see the same pool state
+			snapshot := p.Snapshot()
+			if snapshot == nil {
+				log.Printf("error quoting pool %s: %v", p.GetID(), pkg.ErrPoolStateNotLoaded)
+				return
+			}
+			snapshots[i] = snapshot
+			for k := 1; k <= steps; k++ {
+				quote, err := snapshot.Quote(ctx, tokenIn, stepAmount(k))
+				if err != nil {
+					log.Printf("error quoting pool %s for %d%%: %v", p.GetID(), k*stepPercent, err)
+					return
+				}
+				quotes[i][k] = quote
+			}
+		}(i, pool)
+	}
+	wg.Wait()
+
+	allocated := make([]int, len(pools))
+	for step := 0; step < steps; step++ {
+		bestIdx := -1
+		var bestGain math.Int
+		for i := range pools {
+			next := allocated[i] + 1
+			if next > steps || quotes[i][next] == nil {
+				continue
+			}
+			gain := quotes[i][next].AmountOut.Sub(quotes[i][allocated[i]].AmountOut)
+			if bestIdx < 0 || gain.GT(bestGain) {
+				bestIdx, bestGain = i, gain
+			}
+		}
+		if bestIdx < 0 {
+			return nil, fmt.Errorf("pools can only fill %d%% of the order", step*stepPercent)
+		}
+		allocated[bestIdx]++
+	}
+
+	split := &SplitRoute{
+		InputMint: tokenIn,
+		AmountIn:  amountIn,
+		AmountOut: math.ZeroInt(),
+	}
+	largest, largestPool := -1, -1
+	assigned := math.ZeroInt()
+	for i, k := range allocated {
+		if k == 0 {
+			continue
+		}
+		alloc := Allocation{
+			Pool:      pools[i],
+			Percent:   k * stepPercent,
+			AmountIn:  stepAmount(k),
+			AmountOut: quotes[i][k].AmountOut,
+			Quote:     quotes[i][k],
+		}
+		split.Allocations = append(split.Allocations, alloc)
+		assigned = assigned.Add(alloc.AmountIn)
+		if largest < 0 || alloc.Percent > split.Allocations[largest].Percent {
+			largest, largestPool = len(split.Allocations)-1, i
+		}
+	}
+	// Slices are rounded down; the dust goes to the largest allocation, re-quoted against the
+	// snapshot its steps were quoted from
+	if dust := amountIn.Sub(assigned); dust.IsPositive() {
+		alloc := &split.Allocations[largest]
+		amount := alloc.AmountIn.Add(dust)
+		quote, err := snapshots[largestPool].Quote(ctx, tokenIn, amount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to quote pool %s with the rounding dust: %w", alloc.Pool.GetID(), err)
+		}
+		alloc.AmountIn, alloc.AmountOut, alloc.Quote = amount, quote.AmountOut, quote
+	}
+	for _, alloc := range split.Allocations {
+		split.AmountOut = split.AmountOut.Add(alloc.AmountOut)
+	}
+
+	baseMint, quoteMint := split.Allocations[0].Pool.GetTokens()
+	if tokenIn == baseMint {
+		split.OutputMint = quoteMint
+	} else {
+		split.OutputMint = baseMint
+	}
+	return split, nil
+}
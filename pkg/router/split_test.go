@@ -0,0 +1,99 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	"cosmossdk.io/math"
+	"github.com/solana-zh/solroute/pkg"
+)
+
+func TestGetBestSplit(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		pools       []*fakePool
+		amountIn    int64
+		wantPercent map[string]int
+	}{
+		{
+			name:        "one pool",
+			pools:       []*fakePool{newFakePool("only", "IN", "OUT", 1e9, 1e9)},
+			amountIn:    1_000_003,
+			wantPercent: map[string]int{"only": 100},
+		},
+		{
+			name: "two equal pools",
+			pools: []*fakePool{
+				newFakePool("first", "IN", "OUT", 1e7, 1e7),
+				newFakePool("second", "OUT", "IN", 1e7, 1e7),
+			},
+			amountIn:    1_000_001,
+			wantPercent: map[string]int{"first": 50, "second": 50},
+		},
+		{
+			name: "one dominant pool",
+			pools: []*fakePool{
+				newFakePool("shallow", "IN", "OUT", 1e7, 1e7),
+				newFakePool("deep", "IN", "OUT", 1e12, 1e12),
+			},
+			amountIn:    1_000_007,
+			wantPercent: map[string]int{"deep": 100},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &SimpleRouter{}
+			for _, pool := range tt.pools {
+				r.Pools = append(r.Pools, pool)
+			}
+			amountIn := math.NewInt(tt.amountIn)
+			split, err := r.GetBestSplit(context.Background(), nil, "IN", amountIn, DefaultSplitStepPercent)
+			if err != nil {
+				t.Fatalf("GetBestSplit: %v", err)
+			}
+			if split.OutputMint != "OUT" {
+				t.Errorf("OutputMint = %s, want OUT", split.OutputMint)
+			}
+
+			totalIn, totalOut := math.ZeroInt(), math.ZeroInt()
+			gotPercent := map[string]int{}
+			for _, alloc := range split.Allocations {
+				gotPercent[alloc.Pool.GetID()] = alloc.Percent
+				totalIn = totalIn.Add(alloc.AmountIn)
+				totalOut = totalOut.Add(alloc.AmountOut)
+				// The amounts, including any rounding dust, match a quote of what the allocation spends
+				quote, err := alloc.Pool.Snapshot().Quote(context.Background(), "IN", alloc.AmountIn)
+				if err != nil {
+					t.Fatalf("Quote(%s): %v", alloc.Pool.GetID(), err)
+				}
+				if !alloc.AmountOut.Equal(quote.AmountOut) || !alloc.Quote.AmountOut.Equal(quote.AmountOut) {
+					t.Errorf("allocation to %s of %v gets %v quoted %v, want %v",
+						alloc.Pool.GetID(), alloc.AmountIn, alloc.AmountOut, alloc.Quote.AmountOut, quote.AmountOut)
+				}
+			}
+			if len(gotPercent) != len(tt.wantPercent) {
+				t.Errorf("allocations = %v, want %v", gotPercent, tt.wantPercent)
+			}
+			for id, want := range tt.wantPercent {
+				if gotPercent[id] != want {
+					t.Errorf("allocations = %v, want %v", gotPercent, tt.wantPercent)
+					break
+				}
+			}
+			if !totalIn.Equal(amountIn) {
+				t.Errorf("allocations spend %v, want %v", totalIn, amountIn)
+			}
+			if !split.AmountOut.Equal(totalOut) {
+				t.Errorf("AmountOut = %v, want the allocations' total %v", split.AmountOut, totalOut)
+			}
+		})
+	}
+}
+
+func TestGetBestSplitRejectsStep(t *testing.T) {
+	r := &SimpleRouter{Pools: []pkg.Pool{newFakePool("only", "IN", "OUT", 1e9, 1e9)}}
+	for _, step := range []int{0, 30, 101} {
+		if _, err := r.GetBestSplit(context.Background(), nil, "IN", math.NewInt(1000), step); err == nil {
+			t.Errorf("GetBestSplit accepted a step of %d%%", step)
+		}
+	}
+}
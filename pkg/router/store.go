@@ -0,0 +1,261 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
+)
+
+// poolStoreVersion is bumped whenever the file layout changes; files of other versions are ignored
+const poolStoreVersion = 2
+
+type poolStoreFile struct {
+	Version int               `json:"version"`
+	SavedAt time.Time         `json:"saved_at"`
+	Records []*pkg.PoolRecord `json:"records"`
+}
+
+// PoolStore persists the records of indexed pools in a file, so a restart rebuilds the
+// pool index from disk instead of rescanning every protocol
+type PoolStore struct {
+	Path string
+
+	mu      sync.Mutex
+	records map[pkg.ProtocolName][]*pkg.PoolRecord
+}
+
+// NewPoolStore creates a store backed by the file at path
+func NewPoolStore(path string) *PoolStore {
+	return &PoolStore{
+		Path:    path,
+		records: make(map[pkg.ProtocolName][]*pkg.PoolRecord),
+	}
+}
+
+// Load replaces the records in memory with the ones saved at Path.
+// A missing file or a file written by another version leaves the store empty.
+func (s *PoolStore) Load() error {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read pool store: %w", err)
+	}
+
+	var file poolStoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to decode pool store %s: %w", s.Path, err)
+	}
+	if file.Version != poolStoreVersion {
+		log.Printf("ignoring pool store %s with version %d, expected %d", s.Path, file.Version, poolStoreVersion)
+		return nil
+	}
+
+	records := make(map[pkg.ProtocolName][]*pkg.PoolRecord)
+	for _, record := range file.Records {
+		records[record.Protocol] = append(records[record.Protocol], record)
+	}
+	s.mu.Lock()
+	s.records = records
+	s.mu.Unlock()
+	return nil
+}
+
+// Save writes every record to Path, replacing the file atomically
+func (s *PoolStore) Save() error {
+	s.mu.Lock()
+	file := poolStoreFile{Version: poolStoreVersion, SavedAt: time.Now().UTC()}
+	for _, records := range s.records {
+		file.Records = append(file.Records, records...)
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to encode pool store: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create pool store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write pool store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write pool store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.Path); err != nil {
+		return fmt.Errorf("failed to replace pool store: %w", err)
+	}
+	return nil
+}
+
+// Records returns the stored records of a protocol
+func (s *PoolStore) Records(protocol pkg.ProtocolName) []*pkg.PoolRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[protocol]
+}
+
+func (s *PoolStore) setRecords(protocol pkg.ProtocolName, records []*pkg.PoolRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[protocol] = records
+}
+
+// WarmStart loads the store and builds a pool index from it.
+// Protocols with stored records are restored without network calls. Persistent protocols
+// without records are scanned once and their records saved; protocols that do not implement
+// pkg.PersistentProtocol are scanned with FetchAllPools on every start.
+// Scan failures are reported like BuildPoolIndex.
+func (s *PoolStore) WarmStart(ctx context.Context, protocols []pkg.Protocol, timeout time.Duration, minSuccessful int) (*PoolIndex, error) {
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	scanned := false
+	pools, err := collectPools(ctx, protocols, minSuccessful, func(ctx context.Context, proto pkg.Protocol) ([]pkg.Pool, error) {
+		ctx, cancel := protocolContext(ctx, timeout)
+		defer cancel()
+
+		persistent, ok := proto.(pkg.PersistentProtocol)
+		if !ok {
+			log.Printf("😈Indexing all pools from protocol: %v", proto.ProtocolName())
+			return proto.FetchAllPools(ctx)
+		}
+		if records := s.Records(proto.ProtocolName()); len(records) > 0 {
+			return restoreRecords(persistent, records, nil), nil
+		}
+
+		log.Printf("😈Indexing all pools from protocol: %v", proto.ProtocolName())
+		records, err := persistent.FetchAllPoolRecords(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.setRecords(proto.ProtocolName(), records)
+		mu.Lock()
+		scanned = true
+		mu.Unlock()
+		return restoreRecords(persistent, records, nil), nil
+	})
+
+	index := NewPoolIndex()
+	for _, pool := range pools {
+		index.Add(pool)
+	}
+	if scanned {
+		if saveErr := s.Save(); saveErr != nil {
+			return index, errors.Join(err, saveErr)
+		}
+	}
+	return index, err
+}
+
+// Reconcile brings the store up to date without downloading the pools it already holds.
+// For every persistent protocol it lists the current pool IDs, fetches the records of new pools
+// and drops pools that no longer exist; pools already in index are carried over unchanged.
+// The static metadata of kept pools is not refreshed: a CLMM or CPMM fee rate changed on chain,
+// or AMM market accounts, keep their stored values until the store is rebuilt by a WarmStart
+// from an empty file.
+// The store is saved and the updated index returned. Failures are reported like BuildPoolIndex;
+// a protocol that fails keeps its stored records and its pools from index.
+func (s *PoolStore) Reconcile(ctx context.Context, index *PoolIndex, protocols []pkg.Protocol, timeout time.Duration, minSuccessful int) (*PoolIndex, error) {
+	pools, err := collectPools(ctx, protocols, minSuccessful, func(ctx context.Context, proto pkg.Protocol) ([]pkg.Pool, error) {
+		ctx, cancel := protocolContext(ctx, timeout)
+		defer cancel()
+
+		persistent, ok := proto.(pkg.PersistentProtocol)
+		if !ok {
+			return proto.FetchAllPools(ctx)
+		}
+
+		ids, err := persistent.FetchAllPoolIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		live := make(map[solana.PublicKey]struct{}, len(ids))
+		for _, id := range ids {
+			live[id] = struct{}{}
+		}
+
+		known := make(map[solana.PublicKey]struct{})
+		records := make([]*pkg.PoolRecord, 0, len(ids))
+		for _, record := range s.Records(proto.ProtocolName()) {
+			known[record.ID] = struct{}{}
+			if _, ok := live[record.ID]; ok {
+				records = append(records, record)
+			}
+		}
+		var newIDs []solana.PublicKey
+		for _, id := range ids {
+			if _, ok := known[id]; !ok {
+				newIDs = append(newIDs, id)
+			}
+		}
+
+		newRecords, err := persistent.FetchPoolRecords(ctx, newIDs)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("reconciled %v pools: %d kept, %d added, %d removed",
+			proto.ProtocolName(), len(records), len(newRecords), len(known)-len(records))
+		records = append(records, newRecords...)
+		s.setRecords(proto.ProtocolName(), records)
+		return restoreRecords(persistent, records, index), nil
+	})
+
+	reconciled := NewPoolIndex()
+	for _, pool := range pools {
+		reconciled.Add(pool)
+	}
+	// Keep routing through the pools of protocols that could not be reconciled
+	var queryErr *QueryError
+	if index != nil && errors.As(err, &queryErr) {
+		failed := make(map[pkg.ProtocolName]struct{}, len(queryErr.Failed))
+		for _, failure := range queryErr.Failed {
+			failed[failure.Protocol] = struct{}{}
+		}
+		for _, pool := range index.byID {
+			if _, ok := failed[pool.ProtocolName()]; ok {
+				reconciled.Add(pool)
+			}
+		}
+	}
+	if saveErr := s.Save(); saveErr != nil {
+		return reconciled, errors.Join(err, saveErr)
+	}
+	return reconciled, err
+}
+
+// restoreRecords turns records into pools, reusing the pools already in index.
+// Records that cannot be restored are skipped.
+func restoreRecords(proto pkg.PersistentProtocol, records []*pkg.PoolRecord, index *PoolIndex) []pkg.Pool {
+	pools := make([]pkg.Pool, 0, len(records))
+	for _, record := range records {
+		if index != nil {
+			if pool, ok := index.Pool(record.ID.String()); ok {
+				pools = append(pools, pool)
+				continue
+			}
+		}
+		pool, err := proto.RestorePool(record)
+		if err != nil {
+			continue
+		}
+		pools = append(pools, pool)
+	}
+	return pools
+}
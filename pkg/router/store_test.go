@@ -0,0 +1,261 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg"
+)
+
+// fakePersistentProtocol serves live pools whose records hold their mints as data
+type fakePersistentProtocol struct {
+	*fakeProtocol
+
+	mu sync.Mutex
+	// live maps the ID of every pool on chain to its mints
+	live map[solana.PublicKey][2]string
+	// scans counts the calls of FetchAllPoolRecords
+	scans int
+	// fetched lists the IDs passed to FetchPoolRecords
+	fetched []solana.PublicKey
+}
+
+func newFakePersistentProtocol(name string) *fakePersistentProtocol {
+	return &fakePersistentProtocol{
+		fakeProtocol: newFakeProtocol(name),
+		live:         make(map[solana.PublicKey][2]string),
+	}
+}
+
+// addPool creates a pool of the two mints on chain and returns its ID
+func (p *fakePersistentProtocol) addPool(baseMint, quoteMint string) solana.PublicKey {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := solana.NewWallet().PublicKey()
+	p.live[id] = [2]string{baseMint, quoteMint}
+	return id
+}
+
+func (p *fakePersistentProtocol) closePool(id solana.PublicKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.live, id)
+}
+
+func (p *fakePersistentProtocol) FetchAllPoolIDs(ctx context.Context) ([]solana.PublicKey, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make([]solana.PublicKey, 0, len(p.live))
+	for id := range p.live {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (p *fakePersistentProtocol) FetchAllPoolRecords(ctx context.Context) ([]*pkg.PoolRecord, error) {
+	ids, err := p.FetchAllPoolIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.scans++
+	return p.recordsLocked(ids), nil
+}
+
+func (p *fakePersistentProtocol) FetchPoolRecords(ctx context.Context, poolIDs []solana.PublicKey) ([]*pkg.PoolRecord, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fetched = append(p.fetched, poolIDs...)
+	return p.recordsLocked(poolIDs), nil
+}
+
+func (p *fakePersistentProtocol) recordsLocked(ids []solana.PublicKey) []*pkg.PoolRecord {
+	records := make([]*pkg.PoolRecord, 0, len(ids))
+	for _, id := range ids {
+		mints, ok := p.live[id]
+		if !ok {
+			continue
+		}
+		records = append(records, &pkg.PoolRecord{
+			ID:       id,
+			Protocol: p.name,
+			Data:     []byte(mints[0] + "/" + mints[1]),
+		})
+	}
+	return records
+}
+
+func (p *fakePersistentProtocol) RestorePool(record *pkg.PoolRecord) (pkg.Pool, error) {
+	baseMint, quoteMint, ok := strings.Cut(string(record.Data), "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid record of %s", record.ID)
+	}
+	pool := newFakePool(record.ID.String(), baseMint, quoteMint, 1e9, 1e9)
+	pool.protocol = p.name
+	return pool, nil
+}
+
+// indexIDs returns the sorted IDs of the pools in index
+func indexIDs(index *PoolIndex) []string {
+	pools := make([]pkg.Pool, 0, index.Len())
+	for _, pool := range index.byID {
+		pools = append(pools, pool)
+	}
+	return poolIDs(pools)
+}
+
+// sortedIDs returns the sorted strings of ids
+func sortedIDs(ids ...solana.PublicKey) []string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	slices.Sort(strs)
+	return strs
+}
+
+func TestPoolStoreWarmStartRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "pools.json")
+	persistent := newFakePersistentProtocol("persistent")
+	poolA := persistent.addPool("IN", "OUT")
+	poolB := persistent.addPool("IN", "A")
+	scanned := newFakeProtocol("scanned", newFakePool("scanned-pool", "IN", "OUT", 1e9, 1e9))
+	protocols := []pkg.Protocol{persistent, scanned}
+
+	index, err := NewPoolStore(path).WarmStart(ctx, protocols, 0, 1)
+	if err != nil {
+		t.Fatalf("first WarmStart: %v", err)
+	}
+	want := append(sortedIDs(poolA, poolB), "scanned-pool")
+	slices.Sort(want)
+	if got := indexIDs(index); !slices.Equal(got, want) {
+		t.Errorf("first WarmStart indexed %v, want %v", got, want)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("WarmStart did not save the store: %v", err)
+	}
+
+	// A restart restores the persistent pools from the file without scanning again
+	store := NewPoolStore(path)
+	index, err = store.WarmStart(ctx, protocols, 0, 1)
+	if err != nil {
+		t.Fatalf("second WarmStart: %v", err)
+	}
+	if got := indexIDs(index); !slices.Equal(got, want) {
+		t.Errorf("second WarmStart indexed %v, want %v", got, want)
+	}
+	if persistent.scans != 1 {
+		t.Errorf("persistent protocol scanned %d times, want once", persistent.scans)
+	}
+	var restored []solana.PublicKey
+	for _, record := range store.Records("persistent") {
+		restored = append(restored, record.ID)
+	}
+	if got := sortedIDs(restored...); !slices.Equal(got, sortedIDs(poolA, poolB)) {
+		t.Errorf("loaded records of %v, want %v", got, sortedIDs(poolA, poolB))
+	}
+	if pool, ok := index.Pool(poolB.String()); !ok || pool.(*fakePool).quoteMint != "A" {
+		t.Errorf("pool %s not restored from its record", poolB)
+	}
+}
+
+func TestPoolStoreIgnoresOtherVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pools.json")
+	data, err := json.Marshal(poolStoreFile{
+		Version: poolStoreVersion + 1,
+		Records: []*pkg.PoolRecord{{ID: solana.NewWallet().PublicKey(), Protocol: "persistent", Data: []byte("IN/OUT")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewPoolStore(path)
+	if err := store.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if records := store.Records("persistent"); len(records) != 0 {
+		t.Errorf("Load kept %d records of another version", len(records))
+	}
+
+	persistent := newFakePersistentProtocol("persistent")
+	pool := persistent.addPool("IN", "OUT")
+	index, err := store.WarmStart(context.Background(), []pkg.Protocol{persistent}, 0, 1)
+	if err != nil {
+		t.Fatalf("WarmStart: %v", err)
+	}
+	if got := indexIDs(index); persistent.scans != 1 || !slices.Equal(got, sortedIDs(pool)) {
+		t.Errorf("WarmStart indexed %v after %d scans, want %v after rescanning", got, persistent.scans, sortedIDs(pool))
+	}
+}
+
+func TestPoolStoreReconcile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "pools.json")
+	persistent := newFakePersistentProtocol("persistent")
+	kept := persistent.addPool("IN", "OUT")
+	closed := persistent.addPool("IN", "A")
+	failing := newFakePersistentProtocol("failing")
+	failingPool := failing.addPool("A", "OUT")
+
+	store := NewPoolStore(path)
+	index, err := store.WarmStart(ctx, []pkg.Protocol{persistent, failing}, 0, 1)
+	if err != nil {
+		t.Fatalf("WarmStart: %v", err)
+	}
+	keptPool, _ := index.Pool(kept.String())
+
+	persistent.closePool(closed)
+	added := persistent.addPool("A", "B")
+	failing.err = errFakeRPC
+	reconciled, err := store.Reconcile(ctx, index, []pkg.Protocol{persistent, failing}, 0, 1)
+	if !errors.Is(err, errFakeRPC) {
+		t.Errorf("Reconcile error = %v, want %v", err, errFakeRPC)
+	}
+
+	// The failing protocol keeps its pools from the index
+	want := sortedIDs(kept, added, failingPool)
+	if got := indexIDs(reconciled); !slices.Equal(got, want) {
+		t.Errorf("Reconcile indexed %v, want %v", got, want)
+	}
+	if got := sortedIDs(persistent.fetched...); !slices.Equal(got, sortedIDs(added)) {
+		t.Errorf("Reconcile fetched records of %v, want only the new pool %s", got, added)
+	}
+	if pool, _ := reconciled.Pool(kept.String()); pool != keptPool {
+		t.Errorf("Reconcile replaced the kept pool instead of carrying it over")
+	}
+
+	reloaded := NewPoolStore(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var saved []solana.PublicKey
+	for _, record := range reloaded.Records("persistent") {
+		saved = append(saved, record.ID)
+	}
+	if got := sortedIDs(saved...); !slices.Equal(got, sortedIDs(kept, added)) {
+		t.Errorf("saved records of %v, want %v", got, sortedIDs(kept, added))
+	}
+	if records := reloaded.Records("failing"); len(records) != 1 || records[0].ID != failingPool {
+		t.Errorf("failing protocol lost its stored records: %v", records)
+	}
+}
@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// fakeAccount is an account served by fakeRPC
+type fakeAccount struct {
+	owner solana.PublicKey
+	data  []byte
+}
+
+// fakeRPC is a JSON-RPC server serving getAccountInfo and getMultipleAccounts from a fixed set
+// of accounts. It records the keys of every getMultipleAccounts request.
+type fakeRPC struct {
+	mu       sync.Mutex
+	accounts map[solana.PublicKey]fakeAccount
+	calls    map[string]int
+	batches  [][]solana.PublicKey
+}
+
+func newFakeRPC(t *testing.T) (*fakeRPC, *sol.Client) {
+	t.Helper()
+	fake := &fakeRPC{
+		accounts: make(map[solana.PublicKey]fakeAccount),
+		calls:    make(map[string]int),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client, err := sol.NewClient(context.Background(), server.URL, "", 1000)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return fake, client
+}
+
+func (f *fakeRPC) setAccount(key, owner solana.PublicKey, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accounts[key] = fakeAccount{owner: owner, data: data}
+}
+
+func (f *fakeRPC) callCount(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// multipleAccountsBatches returns the keys of every getMultipleAccounts request
+func (f *fakeRPC) multipleAccountsBatches() [][]solana.PublicKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]solana.PublicKey(nil), f.batches...)
+}
+
+func (f *fakeRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.calls[req.Method]++
+	var result interface{}
+	switch req.Method {
+	case "getAccountInfo":
+		var key solana.PublicKey
+		json.Unmarshal(req.Params[0], &key)
+		result = map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": f.accountJSON(key)}
+	case "getMultipleAccounts":
+		var keys []solana.PublicKey
+		json.Unmarshal(req.Params[0], &keys)
+		f.batches = append(f.batches, keys)
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			values[i] = f.accountJSON(key)
+		}
+		result = map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": values}
+	default:
+		f.mu.Unlock()
+		writeRPC(w, req.ID, nil, map[string]interface{}{"code": -32601, "message": "method not found: " + req.Method})
+		return
+	}
+	f.mu.Unlock()
+	writeRPC(w, req.ID, result, nil)
+}
+
+// accountJSON returns the account in the base64 encoding of the RPC, nil when it does not exist
+func (f *fakeRPC) accountJSON(key solana.PublicKey) interface{} {
+	account, ok := f.accounts[key]
+	if !ok {
+		return nil
+	}
+	return map[string]interface{}{
+		"data":       []string{base64.StdEncoding.EncodeToString(account.data), "base64"},
+		"executable": false,
+		"lamports":   1,
+		"owner":      account.owner,
+		"rentEpoch":  0,
+	}
+}
+
+func writeRPC(w http.ResponseWriter, id json.RawMessage, result interface{}, rpcErr interface{}) {
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
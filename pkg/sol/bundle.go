@@ -0,0 +1,173 @@
+package sol
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// BundleSimulation is the result of simulating a Jito bundle
+type BundleSimulation struct {
+	Succeeded bool
+	// Failure is the error of a failed bundle as the RPC returned it
+	Failure interface{}
+	// FailedTransaction is the signature of the transaction that failed the bundle, if reported
+	FailedTransaction string
+	// Transactions are the results of the transactions that executed, in bundle order
+	Transactions []BundleTransactionSimulation
+}
+
+// BundleTransactionSimulation is the simulated execution of one transaction of a bundle
+type BundleTransactionSimulation struct {
+	// Err is the error of the transaction, nil when it succeeded
+	Err           *TransactionError
+	Logs          []string
+	UnitsConsumed uint64
+}
+
+// jito returns the Jito client or ErrJitoNotConfigured
+func (c *Client) jito() (*JitoClient, error) {
+	if c.jitoClient == nil {
+		return nil, ErrJitoNotConfigured
+	}
+	return c.jitoClient, nil
+}
+
+// SignBundle builds and signs the transactions of a Jito bundle, one for each instruction list,
+// each paid by the first signer. A tip of tipAmount lamports to the next Jito tip account is added as
+// the last instruction of the last transaction when tipInLastTx is set, or as a transaction of
+// its own after the others. A zero tipAmount tips what the client's TipStrategy sizes, or adds no
+// tip without one. The client's FeeStrategy does not apply:
+// bundles pay the tip instead, and later transactions cannot be simulated on their own.
+func (c *Client) SignBundle(
+	ctx context.Context,
+	signers []solana.PrivateKey,
+	tipAmount uint64,
+	tipInLastTx bool,
+	txs ...[]solana.Instruction,
+) ([]*solana.Transaction, error) {
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("at least one signer is required")
+	}
+	if len(txs) == 0 {
+		return nil, fmt.Errorf("a bundle needs at least one transaction")
+	}
+	tipAmount, err := c.tip(ctx, tipAmount)
+	if err != nil {
+		return nil, err
+	}
+	if tipAmount > 0 {
+		jito, err := c.jito()
+		if err != nil {
+			return nil, err
+		}
+		tip, err := jito.newTipInstruction(signers[0].PublicKey(), tipAmount)
+		if err != nil {
+			return nil, err
+		}
+		txs = append([][]solana.Instruction(nil), txs...)
+		if tipInLastTx {
+			last := len(txs) - 1
+			txs[last] = append(append([]solana.Instruction(nil), txs[last]...), tip)
+		} else {
+			txs = append(txs, []solana.Instruction{tip})
+		}
+	}
+	if len(txs) > MaxBundleTransactions {
+		return nil, fmt.Errorf("a bundle holds at most %d transactions, not %d", MaxBundleTransactions, len(txs))
+	}
+
+	signed := make([]*solana.Transaction, len(txs))
+	for i, instrs := range txs {
+		tx, err := c.signTransaction(ctx, signers, nil, nil, instrs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign bundle transaction %d: %w", i, err)
+		}
+		signed[i] = tx
+	}
+	return signed, nil
+}
+
+// SimulateBundle simulates signed transactions as a bundle, in order and each against the state
+// the previous ones left. The RPC endpoint must be a Jito RPC node serving simulateBundle.
+func (c *Client) SimulateBundle(ctx context.Context, txs ...*solana.Transaction) (*BundleSimulation, error) {
+	encoded, err := encodeBundle(txs)
+	if err != nil {
+		return nil, err
+	}
+	accountsConfigs := make([]interface{}, len(txs))
+	params := []interface{}{
+		map[string]interface{}{"encodedTransactions": encoded},
+		map[string]interface{}{
+			"transactionEncoding":          "base64",
+			"preExecutionAccountsConfigs":  accountsConfigs,
+			"postExecutionAccountsConfigs": accountsConfigs,
+			"skipSigVerify":                true,
+		},
+	}
+
+	var out struct {
+		Value struct {
+			Summary            json.RawMessage `json:"summary"`
+			TransactionResults []struct {
+				Err           interface{} `json:"err"`
+				Logs          []string    `json:"logs"`
+				UnitsConsumed *uint64     `json:"unitsConsumed"`
+			} `json:"transactionResults"`
+		} `json:"value"`
+	}
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.rpcClient.RPCCallForInto(ctx, &out, "simulateBundle", params); err != nil {
+		return nil, fmt.Errorf("failed to simulate bundle: %w", err)
+	}
+
+	simulation := &BundleSimulation{}
+	var summary string
+	if err := json.Unmarshal(out.Value.Summary, &summary); err == nil {
+		simulation.Succeeded = summary == "succeeded"
+	} else {
+		var failed struct {
+			Failed struct {
+				Error       interface{} `json:"error"`
+				TxSignature string      `json:"txSignature"`
+			} `json:"failed"`
+		}
+		if err := json.Unmarshal(out.Value.Summary, &failed); err != nil {
+			return nil, fmt.Errorf("failed to decode bundle simulation summary %s: %w", out.Value.Summary, err)
+		}
+		simulation.Failure = failed.Failed.Error
+		simulation.FailedTransaction = failed.Failed.TxSignature
+	}
+	for _, result := range out.Value.TransactionResults {
+		txSimulation := BundleTransactionSimulation{Err: ParseTransactionError(result.Err), Logs: result.Logs}
+		if result.UnitsConsumed != nil {
+			txSimulation.UnitsConsumed = *result.UnitsConsumed
+		}
+		simulation.Transactions = append(simulation.Transactions, txSimulation)
+	}
+	return simulation, nil
+}
+
+// SendBundle sends signed transactions as a Jito bundle and returns the bundle id right away;
+// WatchBundle reports what becomes of it
+func (c *Client) SendBundle(ctx context.Context, txs ...*solana.Transaction) (string, error) {
+	jito, err := c.jito()
+	if err != nil {
+		return "", err
+	}
+	return jito.SendBundle(ctx, txs...)
+}
+
+// WatchBundle reports the status changes of a sent bundle on the returned channel, see
+// JitoClient.WatchBundle
+func (c *Client) WatchBundle(ctx context.Context, bundleId string) (<-chan BundleResult, error) {
+	jito, err := c.jito()
+	if err != nil {
+		return nil, err
+	}
+	return jito.WatchBundle(ctx, bundleId), nil
+}
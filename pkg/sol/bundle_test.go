@@ -0,0 +1,223 @@
+package sol
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// tipDestination returns the account the last instruction of tx, a tip, transfers to
+func tipDestination(t *testing.T, tx *solana.Transaction) solana.PublicKey {
+	t.Helper()
+	instrs := tx.Message.Instructions
+	accounts := instrs[len(instrs)-1].Accounts
+	if len(accounts) != 2 {
+		t.Fatalf("last instruction has %d accounts, want the 2 of a transfer", len(accounts))
+	}
+	return tx.Message.AccountKeys[accounts[1]]
+}
+
+func TestSignBundleTipPlacement(t *testing.T) {
+	jito := newFakeJSONRPC(t)
+	keys, result := tipAccounts(2)
+	jito.answer("getTipAccounts", result)
+	rpc := newFakeJSONRPC(t)
+	rpc.answer("getLatestBlockhash", latestBlockhashResult(solana.Hash{1}.String()))
+	client := newTestClient(t, rpc, jito)
+	payer := solana.NewWallet().PrivateKey
+	signers := []solana.PrivateKey{payer}
+
+	txs := [][]solana.Instruction{
+		{testTransfer(payer.PublicKey())},
+		{testTransfer(payer.PublicKey()), testTransfer(payer.PublicKey())},
+	}
+	for _, tt := range []struct {
+		tipInLastTx bool
+		// instructions of each signed transaction
+		want []int
+	}{
+		{true, []int{1, 3}},
+		{false, []int{1, 2, 1}},
+	} {
+		signed, err := client.SignBundle(context.Background(), signers, 7000, tt.tipInLastTx, txs...)
+		if err != nil {
+			t.Fatalf("SignBundle with tip in last tx %v: %v", tt.tipInLastTx, err)
+		}
+		var got []int
+		for _, tx := range signed {
+			got = append(got, len(tx.Message.Instructions))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Fatalf("SignBundle with tip in last tx %v signed transactions of %v instructions, want %v", tt.tipInLastTx, got, tt.want)
+		}
+		last := signed[len(signed)-1]
+		if tip := tipLamports(t, last); tip != 7000 {
+			t.Errorf("SignBundle with tip in last tx %v tipped %d, want 7000", tt.tipInLastTx, tip)
+		}
+		if to := tipDestination(t, last); !slices.Contains(keys, to) {
+			t.Errorf("SignBundle with tip in last tx %v tipped %s, not a tip account", tt.tipInLastTx, to)
+		}
+		if tipLamports(t, signed[0]) != 1 {
+			t.Errorf("SignBundle with tip in last tx %v tipped in the first transaction", tt.tipInLastTx)
+		}
+	}
+	// The instruction lists of the caller are left as they were
+	if len(txs) != 2 || len(txs[1]) != 2 {
+		t.Errorf("SignBundle changed the instruction lists it was given")
+	}
+}
+
+func TestSignBundleTransactionLimit(t *testing.T) {
+	jito := newFakeJSONRPC(t)
+	_, result := tipAccounts(1)
+	jito.answer("getTipAccounts", result)
+	rpc := newFakeJSONRPC(t)
+	rpc.answer("getLatestBlockhash", latestBlockhashResult(solana.Hash{1}.String()))
+	client := newTestClient(t, rpc, jito)
+	payer := solana.NewWallet().PrivateKey
+	signers := []solana.PrivateKey{payer}
+
+	bundle := func(count int) [][]solana.Instruction {
+		txs := make([][]solana.Instruction, count)
+		for i := range txs {
+			txs[i] = []solana.Instruction{testTransfer(payer.PublicKey())}
+		}
+		return txs
+	}
+	for _, tt := range []struct {
+		name        string
+		txs         int
+		tipAmount   uint64
+		tipInLastTx bool
+		want        int
+	}{
+		{"full without a tip", MaxBundleTransactions, 0, false, MaxBundleTransactions},
+		{"full with the tip in the last transaction", MaxBundleTransactions, 7000, true, MaxBundleTransactions},
+		{"tip transaction fills the bundle", MaxBundleTransactions - 1, 7000, false, MaxBundleTransactions},
+		{"tip transaction overflows the bundle", MaxBundleTransactions, 7000, false, 0},
+		{"too many transactions", MaxBundleTransactions + 1, 0, false, 0},
+	} {
+		before := rpc.callCount("getLatestBlockhash")
+		signed, err := client.SignBundle(context.Background(), signers, tt.tipAmount, tt.tipInLastTx, bundle(tt.txs)...)
+		if tt.want == 0 {
+			if err == nil {
+				t.Errorf("%s: SignBundle signed %d transactions", tt.name, len(signed))
+			}
+			// Nothing is signed for a bundle that cannot be sent
+			if n := rpc.callCount("getLatestBlockhash") - before; n != 0 {
+				t.Errorf("%s: fetched %d blockhashes", tt.name, n)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: SignBundle: %v", tt.name, err)
+		} else if len(signed) != tt.want {
+			t.Errorf("%s: SignBundle signed %d transactions, want %d", tt.name, len(signed), tt.want)
+		}
+	}
+}
+
+func TestSimulateBundle(t *testing.T) {
+	units := uint64(1500)
+	for _, tt := range []struct {
+		name    string
+		summary interface{}
+		results []interface{}
+		want    *BundleSimulation
+	}{
+		{
+			name:    "succeeded",
+			summary: "succeeded",
+			results: []interface{}{
+				map[string]interface{}{"err": nil, "logs": []string{"Program log: swap"}, "unitsConsumed": units},
+				map[string]interface{}{"err": nil, "logs": []string{}, "unitsConsumed": 150},
+			},
+			want: &BundleSimulation{
+				Succeeded: true,
+				Transactions: []BundleTransactionSimulation{
+					{Logs: []string{"Program log: swap"}, UnitsConsumed: units},
+					{Logs: []string{}, UnitsConsumed: 150},
+				},
+			},
+		},
+		{
+			name: "failed",
+			summary: map[string]interface{}{"failed": map[string]interface{}{
+				"error":       map[string]interface{}{"TransactionFailure": []interface{}{[]interface{}{1, 2}, "custom program error: 0x1771"}},
+				"txSignature": "5xSig",
+			}},
+			results: []interface{}{
+				map[string]interface{}{"err": map[string]interface{}{"InstructionError": []interface{}{1, map[string]interface{}{"Custom": 6001}}}, "logs": nil},
+			},
+			want: &BundleSimulation{
+				Failure: map[string]interface{}{"TransactionFailure": []interface{}{
+					[]interface{}{float64(1), float64(2)}, "custom program error: 0x1771",
+				}},
+				FailedTransaction: "5xSig",
+				Transactions: []BundleTransactionSimulation{
+					{Err: &TransactionError{Kind: "Custom", InstructionIndex: 1, Code: 6001}},
+				},
+			},
+		},
+	} {
+		rpc := newFakeJSONRPC(t)
+		rpc.answer("getLatestBlockhash", latestBlockhashResult(solana.Hash{1}.String()))
+		rpc.answer("simulateBundle", map[string]interface{}{
+			"context": map[string]interface{}{"slot": 1},
+			"value":   map[string]interface{}{"summary": tt.summary, "transactionResults": tt.results},
+		})
+		client := newFeeClient(t, rpc)
+		payer := solana.NewWallet().PrivateKey
+		txs, err := client.SignBundle(context.Background(), []solana.PrivateKey{payer}, 0, false,
+			[]solana.Instruction{testTransfer(payer.PublicKey())}, []solana.Instruction{testTransfer(payer.PublicKey())})
+		if err != nil {
+			t.Fatalf("%s: SignBundle: %v", tt.name, err)
+		}
+
+		simulation, err := client.SimulateBundle(context.Background(), txs...)
+		if err != nil {
+			t.Fatalf("%s: SimulateBundle: %v", tt.name, err)
+		}
+		for _, tx := range simulation.Transactions {
+			if tx.Err != nil {
+				// Raw is compared through the other fields
+				tx.Err.Raw = nil
+			}
+		}
+		if !reflect.DeepEqual(simulation, tt.want) {
+			t.Errorf("%s: SimulateBundle = %+v, want %+v", tt.name, simulation, tt.want)
+		}
+
+		var params []map[string]interface{}
+		if err := json.Unmarshal(rpc.lastParams("simulateBundle"), &params); err != nil || len(params) != 2 {
+			t.Fatalf("%s: simulateBundle params %s: %v", tt.name, rpc.lastParams("simulateBundle"), err)
+		}
+		if encoded, _ := params[0]["encodedTransactions"].([]interface{}); len(encoded) != len(txs) {
+			t.Errorf("%s: simulated %d transactions, want %d", tt.name, len(encoded), len(txs))
+		}
+	}
+}
+
+func TestSimulateBundleRejectsUnknownSummary(t *testing.T) {
+	rpc := newFakeJSONRPC(t)
+	rpc.answer("simulateBundle", map[string]interface{}{
+		"context": map[string]interface{}{"slot": 1},
+		"value":   map[string]interface{}{"summary": 42, "transactionResults": []interface{}{}},
+	})
+	client := newFeeClient(t, rpc)
+	payer := solana.NewWallet().PrivateKey
+	tx, err := client.SignTransaction(context.Background(), []solana.PrivateKey{payer}, testTransfer(payer.PublicKey()))
+	if err != nil {
+		t.Fatalf("SignTransaction: %v", err)
+	}
+	if _, err := client.SimulateBundle(context.Background(), tx); err == nil {
+		t.Errorf("SimulateBundle accepted a summary of 42")
+	}
+	if n := rpc.callCount("simulateBundle"); n != 1 {
+		t.Errorf("simulateBundle called %d times, want 1", n)
+	}
+}
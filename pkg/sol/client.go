@@ -2,7 +2,11 @@ package sol
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
+	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
 )
 
@@ -11,20 +15,48 @@ type Client struct {
 	rpcClient   *rpc.Client
 	jitoClient  *JitoClient
 	rateLimiter *RateLimiter
+
+	// FeeStrategy sets the compute budget of the transactions the client signs; nil adds none
+	FeeStrategy *FeeStrategy
+	// TipStrategy sizes the Jito tip of SendTxWithJito and SignBundle when they are given a zero
+	// tip; nil leaves the tip as given
+	TipStrategy *TipStrategy
+
+	// ConfirmCommitment is the commitment SendAndConfirmTx waits for
+	ConfirmCommitment rpc.CommitmentType
+	// ConfirmPollInterval is how often SendAndConfirmTx polls the signature status; zero polls
+	// every DefaultConfirmPollInterval
+	ConfirmPollInterval time.Duration
+	// RebroadcastInterval is how often SendAndConfirmTx sends a transaction again; zero sends it
+	// every DefaultRebroadcastInterval
+	RebroadcastInterval time.Duration
+
+	// blockhashes holds the last valid block height of the blockhashes SignTransaction fetched
+	blockhashes   map[solana.Hash]uint64
+	blockhashesMu sync.Mutex
+
+	// mintPrograms caches the token program owning each mint looked up with GetMintTokenPrograms
+	mintPrograms sync.Map
 }
 
-// NewClient creates a new Solana client with custom rate limiting
+// NewClient creates a new Solana client with custom rate limiting. With a jitoEndpoint it also
+// creates the Jito client, failing when the block engine's tip accounts cannot be fetched.
 func NewClient(ctx context.Context, endpoint, jitoEndpoint string, reqLimitPerSecond int) (*Client, error) {
 	c := &Client{
 		rpcClient:   rpc.New(endpoint),
 		rateLimiter: NewRateLimiter(reqLimitPerSecond),
+
+		ConfirmCommitment:   rpc.CommitmentConfirmed,
+		ConfirmPollInterval: DefaultConfirmPollInterval,
+		RebroadcastInterval: DefaultRebroadcastInterval,
 	}
 
 	if jitoEndpoint != "" {
 		jitoClient, err := NewJitoClient(ctx, jitoEndpoint)
-		if err == nil {
-			c.jitoClient = jitoClient
+		if err != nil {
+			return nil, fmt.Errorf("failed to create jito client: %w", err)
 		}
+		c.jitoClient = jitoClient
 	}
 	return c, nil
 }
@@ -0,0 +1,195 @@
+package sol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// ComputeUnitPriceMode selects how a FeeStrategy prices compute units
+type ComputeUnitPriceMode int
+
+const (
+	// ComputeUnitPriceNone adds no SetComputeUnitPrice instruction
+	ComputeUnitPriceNone ComputeUnitPriceMode = iota
+	// ComputeUnitPriceFixed pays ComputeUnitPrice
+	ComputeUnitPriceFixed
+	// ComputeUnitPriceRecent pays PriorityFeePercentile of the fees recently paid to write the
+	// writable accounts of the transaction, at least ComputeUnitPrice and at most MaxComputeUnitPrice
+	ComputeUnitPriceRecent
+)
+
+const (
+	// MaxComputeUnitLimit is the largest compute unit limit a transaction can request
+	MaxComputeUnitLimit = computebudget.MAX_COMPUTE_UNIT_LIMIT
+
+	// DefaultComputeUnitMarginBps is the margin added to the units a simulation consumed
+	DefaultComputeUnitMarginBps = 1000
+	// DefaultPriorityFeePercentile is the percentile of recent prioritization fees paid by default
+	DefaultPriorityFeePercentile = 75
+
+	// maxPrioritizationFeeAccounts is the number of accounts getRecentPrioritizationFees accepts
+	maxPrioritizationFeeAccounts = 128
+)
+
+// ErrSimulationFailed is returned when a transaction fails in simulation
+var ErrSimulationFailed = errors.New("transaction simulation failed")
+
+// FeeStrategy sets the compute budget of the transactions a Client signs. Its ComputeBudget
+// instructions are prepended to the instructions of the transaction, unless they already
+// carry some.
+type FeeStrategy struct {
+	// ComputeUnitLimit is the compute unit limit to request; zero keeps the runtime default
+	ComputeUnitLimit uint32
+	// SimulateComputeUnits requests the units a simulation of the transaction consumes plus
+	// ComputeUnitMarginBps instead of ComputeUnitLimit
+	SimulateComputeUnits bool
+	ComputeUnitMarginBps uint32
+
+	PriceMode ComputeUnitPriceMode
+	// ComputeUnitPrice is the price of a compute unit in micro-lamports, or the minimum price
+	// of ComputeUnitPriceRecent
+	ComputeUnitPrice uint64
+	// PriorityFeePercentile is the percentile, 0 to 100, of the recent prioritization fees
+	// ComputeUnitPriceRecent pays
+	PriorityFeePercentile int
+	// MaxComputeUnitPrice caps the price of ComputeUnitPriceRecent; zero leaves it uncapped
+	MaxComputeUnitPrice uint64
+}
+
+// NewFeeStrategy creates a fee strategy that requests the simulated compute units plus a margin
+// and pays the default percentile of recent prioritization fees
+func NewFeeStrategy() *FeeStrategy {
+	return &FeeStrategy{
+		SimulateComputeUnits:  true,
+		ComputeUnitMarginBps:  DefaultComputeUnitMarginBps,
+		PriceMode:             ComputeUnitPriceRecent,
+		PriorityFeePercentile: DefaultPriorityFeePercentile,
+	}
+}
+
+// GetComputeUnitPrice returns the compute unit price the strategy pays for instrs
+func (c *Client) GetComputeUnitPrice(ctx context.Context, strategy *FeeStrategy, instrs ...solana.Instruction) (uint64, error) {
+	switch strategy.PriceMode {
+	case ComputeUnitPriceNone:
+		return 0, nil
+	case ComputeUnitPriceFixed:
+		return strategy.ComputeUnitPrice, nil
+	case ComputeUnitPriceRecent:
+		if strategy.PriorityFeePercentile < 0 || strategy.PriorityFeePercentile > 100 {
+			return 0, fmt.Errorf("priority fee percentile %d out of range", strategy.PriorityFeePercentile)
+		}
+		fees, err := c.GetRecentPrioritizationFees(ctx, writableAccounts(instrs))
+		if err != nil {
+			return 0, fmt.Errorf("failed to get recent prioritization fees: %w", err)
+		}
+		price := max(prioritizationFeePercentile(fees, strategy.PriorityFeePercentile), strategy.ComputeUnitPrice)
+		if strategy.MaxComputeUnitPrice > 0 {
+			price = min(price, strategy.MaxComputeUnitPrice)
+		}
+		return price, nil
+	default:
+		return 0, fmt.Errorf("unknown compute unit price mode %d", strategy.PriceMode)
+	}
+}
+
+// SimulateComputeUnits simulates a signed transaction against the latest blockhash and returns
+// the compute units it consumed. A transaction that fails returns ErrSimulationFailed.
+func (c *Client) SimulateComputeUnits(ctx context.Context, tx *solana.Transaction) (uint64, error) {
+	res, err := c.SimulateTransactionWithOpts(ctx, tx, &rpc.SimulateTransactionOpts{
+		Commitment:             rpc.CommitmentProcessed,
+		ReplaceRecentBlockhash: true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to simulate transaction: %w", err)
+	}
+	if res.Value.Err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrSimulationFailed, res.Value.Err)
+	}
+	if res.Value.UnitsConsumed == nil {
+		return 0, fmt.Errorf("simulation did not report the units consumed")
+	}
+	return *res.Value.UnitsConsumed, nil
+}
+
+// computeBudgetInstructions returns the ComputeBudget instructions the strategy prepends to instrs.
+// sign builds and signs a transaction of the given instructions for the simulation.
+func (c *Client) computeBudgetInstructions(
+	ctx context.Context,
+	strategy *FeeStrategy,
+	instrs []solana.Instruction,
+	sign func([]solana.Instruction) (*solana.Transaction, error),
+) ([]solana.Instruction, error) {
+	var budget []solana.Instruction
+	if strategy.PriceMode != ComputeUnitPriceNone {
+		price, err := c.GetComputeUnitPrice(ctx, strategy, instrs...)
+		if err != nil {
+			return nil, err
+		}
+		budget = append(budget, computebudget.NewSetComputeUnitPriceInstruction(price).Build())
+	}
+
+	limit := strategy.ComputeUnitLimit
+	if strategy.SimulateComputeUnits {
+		// Simulate with the largest limit so the limit does not fail the simulation
+		simulated := append([]solana.Instruction{computebudget.NewSetComputeUnitLimitInstruction(MaxComputeUnitLimit).Build()}, budget...)
+		tx, err := sign(append(simulated, instrs...))
+		if err != nil {
+			return nil, err
+		}
+		units, err := c.SimulateComputeUnits(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		limit = uint32(min(units*(10000+uint64(strategy.ComputeUnitMarginBps))/10000, MaxComputeUnitLimit))
+	}
+	if limit > 0 {
+		budget = append([]solana.Instruction{computebudget.NewSetComputeUnitLimitInstruction(limit).Build()}, budget...)
+	}
+	return budget, nil
+}
+
+// hasComputeBudgetInstruction reports whether instrs already set their compute budget
+func hasComputeBudgetInstruction(instrs []solana.Instruction) bool {
+	for _, instr := range instrs {
+		if instr.ProgramID().Equals(solana.ComputeBudget) {
+			return true
+		}
+	}
+	return false
+}
+
+// writableAccounts returns the writable accounts of instrs other than signers, the accounts whose
+// write locks the transaction competes for
+func writableAccounts(instrs []solana.Instruction) solana.PublicKeySlice {
+	var accounts solana.PublicKeySlice
+	for _, instr := range instrs {
+		for _, account := range instr.Accounts() {
+			if account.IsWritable && !account.IsSigner {
+				accounts.UniqueAppend(account.PublicKey)
+			}
+		}
+	}
+	if len(accounts) > maxPrioritizationFeeAccounts {
+		accounts = accounts[:maxPrioritizationFeeAccounts]
+	}
+	return accounts
+}
+
+// prioritizationFeePercentile returns the percentile of the fees, zero when there are none
+func prioritizationFeePercentile(fees []rpc.PriorizationFeeResult, percentile int) uint64 {
+	if len(fees) == 0 {
+		return 0
+	}
+	sorted := make([]uint64, len(fees))
+	for i, fee := range fees {
+		sorted[i] = fee.PrioritizationFee
+	}
+	slices.Sort(sorted)
+	return sorted[(len(sorted)-1)*percentile/100]
+}
@@ -0,0 +1,268 @@
+package sol
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Instructions of the compute budget program
+const (
+	computeBudgetSetLimit = 2
+	computeBudgetSetPrice = 3
+)
+
+// computeBudget is the compute budget a transaction sets, zero for what it leaves unset
+type computeBudget struct {
+	limit        uint32
+	price        uint64
+	instructions int
+}
+
+func budgetOf(t *testing.T, tx *solana.Transaction) computeBudget {
+	t.Helper()
+	var budget computeBudget
+	for _, instr := range tx.Message.Instructions {
+		if program, _ := tx.Message.Program(instr.ProgramIDIndex); program != solana.ComputeBudget {
+			continue
+		}
+		budget.instructions++
+		switch instr.Data[0] {
+		case computeBudgetSetLimit:
+			budget.limit = binary.LittleEndian.Uint32(instr.Data[1:5])
+		case computeBudgetSetPrice:
+			budget.price = binary.LittleEndian.Uint64(instr.Data[1:9])
+		}
+	}
+	return budget
+}
+
+// simulationResult is a simulateTransaction result of a transaction that consumed units
+func simulationResult(units uint64) interface{} {
+	return map[string]interface{}{
+		"context": map[string]interface{}{"slot": 1},
+		"value":   map[string]interface{}{"err": nil, "logs": []string{}, "unitsConsumed": units},
+	}
+}
+
+// prioritizationFeesResult is a getRecentPrioritizationFees result of fees
+func prioritizationFeesResult(fees ...uint64) interface{} {
+	result := make([]interface{}, len(fees))
+	for i, fee := range fees {
+		result[i] = map[string]interface{}{"slot": i + 1, "prioritizationFee": fee}
+	}
+	return result
+}
+
+// newFeeClient returns a client signing through rpc
+func newFeeClient(t *testing.T, rpc *fakeJSONRPC) *Client {
+	t.Helper()
+	rpc.answer("getLatestBlockhash", latestBlockhashResult(solana.HashFromBytes(make([]byte, 32)).String()))
+	client, err := NewClient(context.Background(), rpc.URL, "", 1000)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+// simulatedTransaction returns the transaction of the last simulateTransaction call
+func simulatedTransaction(t *testing.T, rpc *fakeJSONRPC) *solana.Transaction {
+	t.Helper()
+	var args []json.RawMessage
+	var encoded string
+	if err := json.Unmarshal(rpc.lastParams("simulateTransaction"), &args); err != nil || len(args) == 0 {
+		t.Fatalf("simulateTransaction params: %v", err)
+	}
+	if err := json.Unmarshal(args[0], &encoded); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := solana.TransactionFromBase64(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestComputeUnitLimit(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		strategy FeeStrategy
+		units    uint64
+		want     uint32
+	}{
+		{"simulated with margin", FeeStrategy{SimulateComputeUnits: true, ComputeUnitMarginBps: 1000}, 100_000, 110_000},
+		{"simulated without margin", FeeStrategy{SimulateComputeUnits: true}, 100_000, 100_000},
+		{"capped", FeeStrategy{SimulateComputeUnits: true, ComputeUnitMarginBps: 1000}, 1_300_000, MaxComputeUnitLimit},
+		{"fixed", FeeStrategy{ComputeUnitLimit: 250_000}, 0, 250_000},
+		{"runtime default", FeeStrategy{}, 0, 0},
+	} {
+		rpc := newFakeJSONRPC(t)
+		rpc.answer("simulateTransaction", simulationResult(tt.units))
+		client := newFeeClient(t, rpc)
+		client.FeeStrategy = &tt.strategy
+		payer := solana.NewWallet().PrivateKey
+
+		tx, err := client.SignTransaction(context.Background(), []solana.PrivateKey{payer}, testTransfer(payer.PublicKey()))
+		if err != nil {
+			t.Fatalf("%s: SignTransaction: %v", tt.name, err)
+		}
+		if got := budgetOf(t, tx).limit; got != tt.want {
+			t.Errorf("%s: compute unit limit = %d, want %d", tt.name, got, tt.want)
+		}
+		if !tt.strategy.SimulateComputeUnits {
+			if n := rpc.callCount("simulateTransaction"); n != 0 {
+				t.Errorf("%s: simulated %d times", tt.name, n)
+			}
+			continue
+		}
+		// The simulation runs with the largest limit
+		if got := budgetOf(t, simulatedTransaction(t, rpc)).limit; got != MaxComputeUnitLimit {
+			t.Errorf("%s: simulated with a limit of %d, want %d", tt.name, got, MaxComputeUnitLimit)
+		}
+	}
+}
+
+func TestGetComputeUnitPrice(t *testing.T) {
+	fees := []uint64{50, 10, 40, 20, 30}
+	for _, tt := range []struct {
+		name     string
+		strategy FeeStrategy
+		fees     []uint64
+		want     uint64
+		wantErr  bool
+	}{
+		{"none", FeeStrategy{PriceMode: ComputeUnitPriceNone, ComputeUnitPrice: 7}, fees, 0, false},
+		{"fixed", FeeStrategy{PriceMode: ComputeUnitPriceFixed, ComputeUnitPrice: 7}, fees, 7, false},
+		{"recent", FeeStrategy{PriceMode: ComputeUnitPriceRecent, PriorityFeePercentile: 50}, fees, 30, false},
+		{"recent below the floor", FeeStrategy{PriceMode: ComputeUnitPriceRecent, PriorityFeePercentile: 50, ComputeUnitPrice: 35}, fees, 35, false},
+		{"recent above the cap", FeeStrategy{PriceMode: ComputeUnitPriceRecent, PriorityFeePercentile: 100, MaxComputeUnitPrice: 45}, fees, 45, false},
+		{"no recent fees", FeeStrategy{PriceMode: ComputeUnitPriceRecent, PriorityFeePercentile: 50, ComputeUnitPrice: 5}, nil, 5, false},
+		{"percentile out of range", FeeStrategy{PriceMode: ComputeUnitPriceRecent, PriorityFeePercentile: 101}, fees, 0, true},
+		{"unknown mode", FeeStrategy{PriceMode: ComputeUnitPriceRecent + 1}, fees, 0, true},
+	} {
+		rpc := newFakeJSONRPC(t)
+		rpc.answer("getRecentPrioritizationFees", prioritizationFeesResult(tt.fees...))
+		client := newFeeClient(t, rpc)
+		payer := solana.NewWallet().PublicKey()
+
+		price, err := client.GetComputeUnitPrice(context.Background(), &tt.strategy, testTransfer(payer))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: GetComputeUnitPrice error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if price != tt.want {
+			t.Errorf("%s: GetComputeUnitPrice = %d, want %d", tt.name, price, tt.want)
+		}
+	}
+}
+
+func TestPrioritizationFeePercentile(t *testing.T) {
+	odd := prioritizationFees(50, 10, 40, 20, 30)
+	even := prioritizationFees(4, 1, 3, 2)
+	for _, tt := range []struct {
+		fees       []rpc.PriorizationFeeResult
+		percentile int
+		want       uint64
+	}{
+		{odd, 0, 10},
+		{odd, 50, 30},
+		{odd, 100, 50},
+		// The percentile rounds down to the fee below it
+		{even, 50, 2},
+		{even, 100, 4},
+		{nil, 50, 0},
+	} {
+		if got := prioritizationFeePercentile(tt.fees, tt.percentile); got != tt.want {
+			t.Errorf("percentile %d of %d fees = %d, want %d", tt.percentile, len(tt.fees), got, tt.want)
+		}
+	}
+}
+
+func prioritizationFees(fees ...uint64) []rpc.PriorizationFeeResult {
+	result := make([]rpc.PriorizationFeeResult, len(fees))
+	for i, fee := range fees {
+		result[i] = rpc.PriorizationFeeResult{Slot: uint64(i + 1), PrioritizationFee: fee}
+	}
+	return result
+}
+
+func TestWritableAccounts(t *testing.T) {
+	signer := solana.NewWallet().PublicKey()
+	writable := newKeys(maxPrioritizationFeeAccounts + 2)
+	metas := solana.AccountMetaSlice{
+		solana.NewAccountMeta(signer, true, true),
+		solana.NewAccountMeta(solana.NewWallet().PublicKey(), false, false),
+	}
+	for _, key := range writable {
+		metas = append(metas, solana.NewAccountMeta(key, true, false))
+	}
+	instrs := []solana.Instruction{
+		solana.NewInstruction(solana.SystemProgramID, metas, nil),
+		// Repeated accounts are listed once
+		solana.NewInstruction(solana.SystemProgramID, metas[:4], nil),
+	}
+
+	got := writableAccounts(instrs)
+	if want := writable[:maxPrioritizationFeeAccounts]; !slices.Equal(got, want) {
+		t.Errorf("writableAccounts returned %d accounts, want the first %d writable ones", len(got), len(want))
+	}
+
+	rpc := newFakeJSONRPC(t)
+	rpc.answer("getRecentPrioritizationFees", prioritizationFeesResult(1))
+	client := newFeeClient(t, rpc)
+	strategy := &FeeStrategy{PriceMode: ComputeUnitPriceRecent, PriorityFeePercentile: 50}
+	if _, err := client.GetComputeUnitPrice(context.Background(), strategy, instrs...); err != nil {
+		t.Fatalf("GetComputeUnitPrice: %v", err)
+	}
+	var params []solana.PublicKeySlice
+	if err := json.Unmarshal(rpc.lastParams("getRecentPrioritizationFees"), &params); err != nil || len(params) != 1 {
+		t.Fatalf("getRecentPrioritizationFees params: %v", err)
+	}
+	if len(params[0]) != maxPrioritizationFeeAccounts {
+		t.Errorf("getRecentPrioritizationFees got %d accounts, want %d", len(params[0]), maxPrioritizationFeeAccounts)
+	}
+}
+
+func TestSignTransactionKeepsComputeBudget(t *testing.T) {
+	rpc := newFakeJSONRPC(t)
+	rpc.answer("simulateTransaction", simulationResult(100_000))
+	rpc.answer("getRecentPrioritizationFees", prioritizationFeesResult(1000))
+	client := newFeeClient(t, rpc)
+	client.FeeStrategy = NewFeeStrategy()
+	payer := solana.NewWallet().PrivateKey
+
+	instrs := []solana.Instruction{
+		computebudget.NewSetComputeUnitLimitInstruction(50_000).Build(),
+		testTransfer(payer.PublicKey()),
+	}
+	tx, err := client.SignTransaction(context.Background(), []solana.PrivateKey{payer}, instrs...)
+	if err != nil {
+		t.Fatalf("SignTransaction: %v", err)
+	}
+	if got, want := budgetOf(t, tx), (computeBudget{limit: 50_000, instructions: 1}); got != want {
+		t.Errorf("compute budget = %+v, want %+v", got, want)
+	}
+	if len(tx.Message.Instructions) != len(instrs) {
+		t.Errorf("transaction has %d instructions, want %d", len(tx.Message.Instructions), len(instrs))
+	}
+	for _, method := range []string{"simulateTransaction", "getRecentPrioritizationFees"} {
+		if n := rpc.callCount(method); n != 0 {
+			t.Errorf("%s called %d times", method, n)
+		}
+	}
+
+	// Without ComputeBudget instructions the strategy prepends both
+	tx, err = client.SignTransaction(context.Background(), []solana.PrivateKey{payer}, testTransfer(payer.PublicKey()))
+	if err != nil {
+		t.Fatalf("SignTransaction: %v", err)
+	}
+	if got, want := budgetOf(t, tx), (computeBudget{limit: 110_000, price: 1000, instructions: 2}); got != want {
+		t.Errorf("compute budget = %+v, want %+v", got, want)
+	}
+}
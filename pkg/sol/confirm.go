@@ -0,0 +1,293 @@
+package sol
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	// DefaultConfirmPollInterval is how often SendAndConfirmTx polls the signature status
+	DefaultConfirmPollInterval = 500 * time.Millisecond
+	// DefaultRebroadcastInterval is how often SendAndConfirmTx sends a transaction again
+	DefaultRebroadcastInterval = 2 * time.Second
+
+	// blockhashValidBlocks is the number of blocks a blockhash stays valid for
+	blockhashValidBlocks = 150
+)
+
+// TxStatus is the status of a sent transaction
+type TxStatus int
+
+const (
+	// TxPending is a transaction not confirmed, failed or expired yet
+	TxPending TxStatus = iota
+	// TxConfirmed is a transaction that succeeded at the confirmation commitment
+	TxConfirmed
+	// TxFailed is a transaction that landed with an error
+	TxFailed
+	// TxExpired is a transaction whose blockhash expired before it landed; it never will
+	TxExpired
+)
+
+func (s TxStatus) String() string {
+	switch s {
+	case TxPending:
+		return "pending"
+	case TxConfirmed:
+		return "confirmed"
+	case TxFailed:
+		return "failed"
+	case TxExpired:
+		return "expired"
+	default:
+		return fmt.Sprintf("TxStatus(%d)", int(s))
+	}
+}
+
+// TxOutcome is the outcome of a transaction sent with SendAndConfirmTx
+type TxOutcome struct {
+	Signature solana.Signature
+	Status    TxStatus
+	// Slot is the slot the transaction landed in, zero unless it landed
+	Slot uint64
+	// Err is the on-chain error of a failed transaction
+	Err *TransactionError
+	// Sends counts the times the transaction was broadcast
+	Sends int
+	// RebroadcastErr is the error of the last rebroadcast that failed
+	RebroadcastErr error
+}
+
+// TransactionError is the decoded on-chain error of a failed transaction
+type TransactionError struct {
+	// Kind names the error, e.g. "InsufficientFundsForFee", or for an instruction error
+	// "Custom" or the name of the instruction error
+	Kind string
+	// InstructionIndex is the index of the failed instruction, -1 for errors of the transaction
+	InstructionIndex int
+	// Code is the program error code of a Custom instruction error
+	Code uint32
+	// Raw is the error as the RPC returned it
+	Raw interface{}
+}
+
+func (e *TransactionError) Error() string {
+	if e.InstructionIndex < 0 {
+		return fmt.Sprintf("transaction error: %s", e.Kind)
+	}
+	if e.Kind == "Custom" {
+		return fmt.Sprintf("instruction %d failed: custom program error 0x%x", e.InstructionIndex, e.Code)
+	}
+	return fmt.Sprintf("instruction %d failed: %s", e.InstructionIndex, e.Kind)
+}
+
+// ParseTransactionError decodes the err of a transaction status, e.g.
+// {"InstructionError":[2,{"Custom":6001}]}. It returns nil for a nil err.
+func ParseTransactionError(raw interface{}) *TransactionError {
+	if raw == nil {
+		return nil
+	}
+	txErr := &TransactionError{Kind: errorKind(raw), InstructionIndex: -1, Raw: raw}
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		return txErr
+	}
+	instructionError, ok := fields["InstructionError"].([]interface{})
+	if !ok || len(instructionError) != 2 {
+		return txErr
+	}
+	index, ok := jsonUint(instructionError[0])
+	if !ok {
+		return txErr
+	}
+	txErr.InstructionIndex = int(index)
+	txErr.Kind = errorKind(instructionError[1])
+	if detail, ok := instructionError[1].(map[string]interface{}); ok {
+		if code, ok := jsonUint(detail["Custom"]); ok {
+			txErr.Code = uint32(code)
+		}
+	}
+	return txErr
+}
+
+// errorKind names an error enum encoded as a string or as an object with one key
+func errorKind(raw interface{}) string {
+	switch v := raw.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		for kind := range v {
+			return kind
+		}
+	}
+	return fmt.Sprint(raw)
+}
+
+// jsonUint returns a JSON number decoded either as float64 or as json.Number
+func jsonUint(raw interface{}) (uint64, bool) {
+	switch v := raw.(type) {
+	case float64:
+		return uint64(v), v >= 0
+	case json.Number:
+		n, err := v.Int64()
+		return uint64(n), err == nil && n >= 0
+	}
+	return 0, false
+}
+
+// SendAndConfirmTx sends a signed transaction and waits until it is confirmed at the client's
+// ConfirmCommitment, fails or expires, sending it again every RebroadcastInterval until the
+// block height passes the last valid block height of its blockhash. A failed or expired
+// transaction is not an error: the outcome tells. On error, the outcome returned so far, if
+// any, still carries the signature of the pending transaction.
+func (c *Client) SendAndConfirmTx(ctx context.Context, tx *solana.Transaction) (*TxOutcome, error) {
+	sig, err := c.SendTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	outcome := &TxOutcome{Signature: sig, Sends: 1}
+	blockhash := tx.Message.RecentBlockhash
+
+	pollInterval, rebroadcastInterval := c.ConfirmPollInterval, c.RebroadcastInterval
+	if pollInterval <= 0 {
+		pollInterval = DefaultConfirmPollInterval
+	}
+	if rebroadcastInterval <= 0 {
+		rebroadcastInterval = DefaultRebroadcastInterval
+	}
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	lastSend := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return outcome, ctx.Err()
+		case <-poll.C:
+		}
+
+		status, err := c.getSignatureStatus(ctx, sig, false)
+		if err != nil {
+			return outcome, err
+		}
+		if status != nil {
+			// Landed: wait for the commitment without sending again
+			if c.settle(outcome, status) {
+				return outcome, nil
+			}
+			continue
+		}
+		if time.Since(lastSend) < rebroadcastInterval {
+			continue
+		}
+
+		expired, err := c.blockhashExpired(ctx, blockhash)
+		if err != nil {
+			return outcome, err
+		}
+		if expired {
+			// The transaction may have landed since the last poll
+			status, err := c.getSignatureStatus(ctx, sig, true)
+			if err != nil {
+				return outcome, err
+			}
+			if status == nil {
+				outcome.Status = TxExpired
+				return outcome, nil
+			}
+			if c.settle(outcome, status) {
+				return outcome, nil
+			}
+			continue
+		}
+
+		// The RPC node may drop a transaction it could not forward: send it again
+		if _, err := c.SendTx(ctx, tx); err != nil {
+			outcome.RebroadcastErr = err
+		} else {
+			outcome.Sends++
+		}
+		lastSend = time.Now()
+	}
+}
+
+// settle records a landed transaction in outcome and reports whether its outcome is final
+func (c *Client) settle(outcome *TxOutcome, status *rpc.SignatureStatusesResult) bool {
+	outcome.Slot = status.Slot
+	if status.Err != nil {
+		outcome.Status = TxFailed
+		outcome.Err = ParseTransactionError(status.Err)
+		return true
+	}
+	if commitmentRank(string(status.ConfirmationStatus)) >= commitmentRank(string(c.ConfirmCommitment)) {
+		outcome.Status = TxConfirmed
+		return true
+	}
+	return false
+}
+
+// commitmentRank orders commitment levels from processed to finalized
+func commitmentRank(commitment string) int {
+	switch commitment {
+	case string(rpc.CommitmentFinalized):
+		return 2
+	case string(rpc.CommitmentConfirmed):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// getSignatureStatus returns the status of a transaction, nil when the cluster has not seen it
+func (c *Client) getSignatureStatus(ctx context.Context, sig solana.Signature, searchHistory bool) (*rpc.SignatureStatusesResult, error) {
+	res, err := c.GetSignatureStatuses(ctx, searchHistory, sig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signature status: %w", err)
+	}
+	if len(res.Value) == 0 {
+		return nil, nil
+	}
+	return res.Value[0], nil
+}
+
+// blockhashExpired reports whether transactions with blockhash can no longer land. Blockhashes
+// fetched by SignTransaction are checked against their last valid block height, others with
+// isBlockhashValid.
+func (c *Client) blockhashExpired(ctx context.Context, blockhash solana.Hash) (bool, error) {
+	c.blockhashesMu.Lock()
+	lastValid, ok := c.blockhashes[blockhash]
+	c.blockhashesMu.Unlock()
+
+	if !ok {
+		res, err := c.IsBlockhashValid(ctx, blockhash, c.ConfirmCommitment)
+		if err != nil {
+			return false, fmt.Errorf("failed to check blockhash: %w", err)
+		}
+		return !res.Value, nil
+	}
+	height, err := c.GetBlockHeight(ctx, c.ConfirmCommitment)
+	if err != nil {
+		return false, fmt.Errorf("failed to get block height: %w", err)
+	}
+	return height > lastValid, nil
+}
+
+// rememberBlockhash records the last valid block height of a fetched blockhash, forgetting
+// blockhashes that expired before it was fetched
+func (c *Client) rememberBlockhash(blockhash solana.Hash, lastValidBlockHeight uint64) {
+	c.blockhashesMu.Lock()
+	defer c.blockhashesMu.Unlock()
+	if c.blockhashes == nil {
+		c.blockhashes = make(map[solana.Hash]uint64)
+	}
+	for hash, lastValid := range c.blockhashes {
+		if lastValid+blockhashValidBlocks < lastValidBlockHeight {
+			delete(c.blockhashes, hash)
+		}
+	}
+	c.blockhashes[blockhash] = lastValidBlockHeight
+}
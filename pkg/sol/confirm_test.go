@@ -0,0 +1,261 @@
+package sol
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// landedStatus is the signature status of a transaction that landed at commitment with txErr
+func landedStatus(commitment string, txErr interface{}) map[string]interface{} {
+	return map[string]interface{}{"slot": 9, "confirmations": nil, "err": txErr, "confirmationStatus": commitment}
+}
+
+// newConfirmClient returns a client polling rpc without delay, and a transaction it signed
+// against a blockhash valid until block height 100
+func newConfirmClient(t *testing.T, rpc *fakeJSONRPC) (*Client, *solana.Transaction) {
+	t.Helper()
+	client := newFeeClient(t, rpc)
+	client.ConfirmPollInterval = time.Millisecond
+	client.RebroadcastInterval = time.Nanosecond
+	payer := solana.NewWallet().PrivateKey
+	tx, err := client.SignTransaction(context.Background(), []solana.PrivateKey{payer}, testTransfer(payer.PublicKey()))
+	if err != nil {
+		t.Fatalf("SignTransaction: %v", err)
+	}
+	return client, tx
+}
+
+// blockHeights answers getBlockHeight with heights in turn, repeating the last one
+func blockHeights(heights ...uint64) func(json.RawMessage) interface{} {
+	return func(json.RawMessage) interface{} {
+		height := heights[0]
+		if len(heights) > 1 {
+			heights = heights[1:]
+		}
+		return height
+	}
+}
+
+func TestSendAndConfirmTx(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		statuses []map[string]interface{}
+		want     TxOutcome
+	}{
+		{
+			name:     "confirmed",
+			statuses: []map[string]interface{}{landedStatus("confirmed", nil)},
+			want:     TxOutcome{Status: TxConfirmed, Slot: 9, Sends: 1},
+		},
+		{
+			name:     "confirmed after processed",
+			statuses: []map[string]interface{}{nil, landedStatus("processed", nil), landedStatus("finalized", nil)},
+			want:     TxOutcome{Status: TxConfirmed, Slot: 9, Sends: 1},
+		},
+		{
+			name: "failed",
+			statuses: []map[string]interface{}{landedStatus("processed", map[string]interface{}{
+				"InstructionError": []interface{}{2, map[string]interface{}{"Custom": 6001}},
+			})},
+			want: TxOutcome{Status: TxFailed, Slot: 9, Sends: 1, Err: &TransactionError{Kind: "Custom", InstructionIndex: 2, Code: 6001}},
+		},
+	} {
+		rpc := newFakeJSONRPC(t)
+		var sent []*solana.Transaction
+		rpc.handle("sendTransaction", sentTransactionsHandler(&sent))
+		statuses := tt.statuses
+		rpc.handle("getSignatureStatuses", func(json.RawMessage) interface{} {
+			status := statuses[0]
+			if len(statuses) > 1 {
+				statuses = statuses[1:]
+			}
+			return signatureStatusResult(status)
+		})
+		// The blockhash stays valid
+		rpc.answer("getBlockHeight", 50)
+		client, tx := newConfirmClient(t, rpc)
+		client.RebroadcastInterval = time.Hour
+
+		outcome, err := client.SendAndConfirmTx(context.Background(), tx)
+		if err != nil {
+			t.Fatalf("%s: SendAndConfirmTx: %v", tt.name, err)
+		}
+		if outcome.Err != nil {
+			// Raw is compared through the other fields
+			outcome.Err.Raw = nil
+		}
+		tt.want.Signature = tx.Signatures[0]
+		if !reflect.DeepEqual(*outcome, tt.want) {
+			t.Errorf("%s: outcome = %+v, want %+v", tt.name, *outcome, tt.want)
+		}
+	}
+}
+
+func TestSendAndConfirmTxRebroadcastsUntilExpiry(t *testing.T) {
+	rpc := newFakeJSONRPC(t)
+	var sent []*solana.Transaction
+	rpc.handle("sendTransaction", sentTransactionsHandler(&sent))
+	rpc.answer("getSignatureStatuses", signatureStatusResult(nil))
+	// The blockhash is valid until block height 100
+	rpc.handle("getBlockHeight", blockHeights(98, 99, 100, 101))
+	client, tx := newConfirmClient(t, rpc)
+
+	outcome, err := client.SendAndConfirmTx(context.Background(), tx)
+	if err != nil {
+		t.Fatalf("SendAndConfirmTx: %v", err)
+	}
+	if outcome.Status != TxExpired || outcome.Sends != 4 || outcome.RebroadcastErr != nil {
+		t.Errorf("outcome = %+v, want expired after 4 sends", *outcome)
+	}
+	if len(sent) != 4 {
+		t.Errorf("sent %d times, want once and once more for each of 3 valid block heights", len(sent))
+	}
+	// The expired transaction is looked up in the history before giving up on it
+	var params []json.RawMessage
+	if err := json.Unmarshal(rpc.lastParams("getSignatureStatuses"), &params); err != nil || len(params) != 2 ||
+		!strings.Contains(string(params[1]), `"searchTransactionHistory":true`) {
+		t.Errorf("last getSignatureStatuses params = %s, want a history search", rpc.lastParams("getSignatureStatuses"))
+	}
+}
+
+func TestSendAndConfirmTxLandsAfterExpiry(t *testing.T) {
+	rpc := newFakeJSONRPC(t)
+	var sent []*solana.Transaction
+	rpc.handle("sendTransaction", sentTransactionsHandler(&sent))
+	// The history search finds the transaction the recent statuses missed
+	rpc.handle("getSignatureStatuses", func(params json.RawMessage) interface{} {
+		if strings.Contains(string(params), `"searchTransactionHistory":true`) {
+			return signatureStatusResult(landedStatus("finalized", nil))
+		}
+		return signatureStatusResult(nil)
+	})
+	rpc.answer("getBlockHeight", 101)
+	client, tx := newConfirmClient(t, rpc)
+
+	outcome, err := client.SendAndConfirmTx(context.Background(), tx)
+	if err != nil {
+		t.Fatalf("SendAndConfirmTx: %v", err)
+	}
+	if outcome.Status != TxConfirmed || outcome.Sends != 1 {
+		t.Errorf("outcome = %+v, want confirmed after 1 send", *outcome)
+	}
+}
+
+func TestSendAndConfirmTxRecordsRebroadcastError(t *testing.T) {
+	rpc := newFakeJSONRPC(t)
+	var sent []*solana.Transaction
+	sendTx := sentTransactionsHandler(&sent)
+	rpc.handle("sendTransaction", func(params json.RawMessage) interface{} {
+		if len(sent) > 0 {
+			return fakeRPCError("node is unhealthy")
+		}
+		return sendTx(params)
+	})
+	rpc.answer("getSignatureStatuses", signatureStatusResult(nil))
+	rpc.handle("getBlockHeight", blockHeights(100, 101))
+	client, tx := newConfirmClient(t, rpc)
+
+	outcome, err := client.SendAndConfirmTx(context.Background(), tx)
+	if err != nil {
+		t.Fatalf("SendAndConfirmTx: %v", err)
+	}
+	if outcome.Status != TxExpired || outcome.Sends != 1 {
+		t.Errorf("outcome = %+v, want expired after 1 send", *outcome)
+	}
+	if outcome.RebroadcastErr == nil || !strings.Contains(outcome.RebroadcastErr.Error(), "node is unhealthy") {
+		t.Errorf("RebroadcastErr = %v, want the error of the rebroadcast", outcome.RebroadcastErr)
+	}
+}
+
+func TestSendAndConfirmTxDefaultIntervals(t *testing.T) {
+	rpc := newFakeJSONRPC(t)
+	var sent []*solana.Transaction
+	rpc.handle("sendTransaction", sentTransactionsHandler(&sent))
+	rpc.answer("getSignatureStatuses", signatureStatusResult(landedStatus("confirmed", nil)))
+	client, tx := newConfirmClient(t, rpc)
+	client.ConfirmPollInterval, client.RebroadcastInterval = 0, 0
+
+	outcome, err := client.SendAndConfirmTx(context.Background(), tx)
+	if err != nil {
+		t.Fatalf("SendAndConfirmTx: %v", err)
+	}
+	if outcome.Status != TxConfirmed {
+		t.Errorf("outcome = %+v, want confirmed", *outcome)
+	}
+}
+
+func TestParseTransactionError(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		raw       string
+		want      *TransactionError
+		wantError string
+	}{
+		{"none", `null`, nil, ""},
+		{
+			"transaction error", `"AccountInUse"`,
+			&TransactionError{Kind: "AccountInUse", InstructionIndex: -1},
+			"transaction error: AccountInUse",
+		},
+		{
+			"transaction error with fields", `{"InsufficientFundsForRent":{"account_index":2}}`,
+			&TransactionError{Kind: "InsufficientFundsForRent", InstructionIndex: -1},
+			"transaction error: InsufficientFundsForRent",
+		},
+		{
+			"custom instruction error", `{"InstructionError":[2,{"Custom":6001}]}`,
+			&TransactionError{Kind: "Custom", InstructionIndex: 2, Code: 6001},
+			"instruction 2 failed: custom program error 0x1771",
+		},
+		{
+			"instruction error", `{"InstructionError":[0,"InvalidAccountData"]}`,
+			&TransactionError{Kind: "InvalidAccountData", InstructionIndex: 0},
+			"instruction 0 failed: InvalidAccountData",
+		},
+		{
+			"instruction error with fields", `{"InstructionError":[1,{"BorshIoError":"Unknown"}]}`,
+			&TransactionError{Kind: "BorshIoError", InstructionIndex: 1},
+			"instruction 1 failed: BorshIoError",
+		},
+		{
+			"malformed instruction error", `{"InstructionError":[1]}`,
+			&TransactionError{Kind: "InstructionError", InstructionIndex: -1},
+			"transaction error: InstructionError",
+		},
+	} {
+		for _, useNumber := range []bool{false, true} {
+			// The RPC client decodes numbers as float64 or json.Number depending on its decoder
+			decoder := json.NewDecoder(strings.NewReader(tt.raw))
+			if useNumber {
+				decoder.UseNumber()
+			}
+			var raw interface{}
+			if err := decoder.Decode(&raw); err != nil {
+				t.Fatal(err)
+			}
+			got := ParseTransactionError(raw)
+			if got == nil || tt.want == nil {
+				if got != tt.want {
+					t.Errorf("%s: ParseTransactionError = %+v, want %+v", tt.name, got, tt.want)
+				}
+				continue
+			}
+			if !reflect.DeepEqual(got.Raw, raw) {
+				t.Errorf("%s: Raw = %v, want %v", tt.name, got.Raw, raw)
+			}
+			got.Raw = nil
+			if *got != *tt.want {
+				t.Errorf("%s: ParseTransactionError = %+v, want %+v", tt.name, *got, *tt.want)
+			}
+			if got.Error() != tt.wantError {
+				t.Errorf("%s: Error() = %q, want %q", tt.name, got.Error(), tt.wantError)
+			}
+		}
+	}
+}
@@ -5,6 +5,8 @@ import "github.com/gagliardetto/solana-go"
 var (
 	WSOL      = solana.MustPublicKeyFromBase58("So11111111111111111111111111111111111111112")
 	NativeSOL = solana.MustPublicKeyFromBase58("11111111111111111111111111111111")
+	USDC      = solana.MustPublicKeyFromBase58("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v")
+	USDT      = solana.MustPublicKeyFromBase58("Es9vMFrzaCERmJrFjdNXxTpbSsEbYkXVHuJnVdR8VCDx")
 
 	TokenAccountSize = uint64(165)
 )
@@ -1,10 +1,17 @@
 package sol
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gagliardetto/solana-go"
@@ -12,31 +19,113 @@ import (
 	jitorpc "github.com/jito-labs/jito-go-rpc"
 )
 
+const (
+	// MaxBundleTransactions is the number of transactions a Jito bundle can hold
+	MaxBundleTransactions = 5
+
+	// DefaultBundleStatusPollInterval is how often WatchBundle polls the bundle status
+	DefaultBundleStatusPollInterval = 2 * time.Second
+	// DefaultBundleStatusTimeout is how long WatchBundle watches a bundle
+	DefaultBundleStatusTimeout = 2 * time.Minute
+)
+
+var (
+	// ErrJitoNotConfigured is returned by Jito calls of a client without a Jito endpoint
+	ErrJitoNotConfigured = errors.New("jito client not configured")
+	// ErrTipAccountUnavailable is returned when no Jito tip account is known to pay the tip to
+	ErrTipAccountUnavailable = errors.New("jito tip account unavailable")
+	// ErrBundleRejected is returned when the block engine does not accept a bundle
+	ErrBundleRejected = errors.New("jito bundle rejected")
+)
+
 type JitoClient struct {
-	rpcClient  *jitorpc.JitoJsonRpcClient
-	tipAccount solana.PublicKey
+	rpcClient *jitorpc.JitoJsonRpcClient
+
+	// tipAccounts caches the tip accounts of the block engine; bundles take turns paying them
+	tipAccounts   []solana.PublicKey
+	tipAccountsMu sync.RWMutex
+	nextTip       atomic.Uint64
+
+	// StatusPollInterval is how often WatchBundle polls the bundle status
+	StatusPollInterval time.Duration
+	// StatusTimeout is how long WatchBundle watches a bundle before giving up
+	StatusTimeout time.Duration
 }
 
 // Jito endpoint refer to: https://docs.jito.wtf/lowlatencytxnsend/
 func NewJitoClient(ctx context.Context, endpoint string) (*JitoClient, error) {
-	rpcClient := jitorpc.NewJitoJsonRpcClient(endpoint, "")
-	tipAccount, err := rpcClient.GetRandomTipAccount()
+	c := &JitoClient{
+		rpcClient:          jitorpc.NewJitoJsonRpcClient(endpoint, ""),
+		StatusPollInterval: DefaultBundleStatusPollInterval,
+		StatusTimeout:      DefaultBundleStatusTimeout,
+	}
+	if err := c.RefreshTipAccounts(ctx); err != nil {
+		return nil, err
+	}
+	// Start the rotation anywhere so clients started together do not pay the same accounts
+	c.nextTip.Store(rand.Uint64())
+	return c, nil
+}
+
+// RefreshTipAccounts fetches the tip accounts of the block engine with getTipAccounts and
+// replaces the cached ones
+func (c *JitoClient) RefreshTipAccounts(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	raw, err := c.rpcClient.GetTipAccounts()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get random tip account: %v", err)
+		return fmt.Errorf("%w: %w", ErrTipAccountUnavailable, err)
+	}
+	var addresses []string
+	if err := json.Unmarshal(raw, &addresses); err != nil {
+		return fmt.Errorf("%w: unexpected tip accounts %s: %w", ErrTipAccountUnavailable, raw, err)
+	}
+	if len(addresses) == 0 {
+		return fmt.Errorf("%w: no tip accounts returned", ErrTipAccountUnavailable)
+	}
+	tipAccounts := make([]solana.PublicKey, len(addresses))
+	for i, address := range addresses {
+		if tipAccounts[i], err = solana.PublicKeyFromBase58(address); err != nil {
+			return fmt.Errorf("%w: invalid tip account %q: %w", ErrTipAccountUnavailable, address, err)
+		}
+	}
+
+	c.tipAccountsMu.Lock()
+	c.tipAccounts = tipAccounts
+	c.tipAccountsMu.Unlock()
+	return nil
+}
+
+// TipAccounts returns the cached tip accounts
+func (c *JitoClient) TipAccounts() []solana.PublicKey {
+	c.tipAccountsMu.RLock()
+	defer c.tipAccountsMu.RUnlock()
+	return slices.Clone(c.tipAccounts)
+}
+
+// NextTipAccount returns the tip account the next bundle pays. Bundles take turns over the
+// tip accounts so that bundles in flight at once do not contend for the write lock of one.
+func (c *JitoClient) NextTipAccount() (solana.PublicKey, error) {
+	c.tipAccountsMu.RLock()
+	defer c.tipAccountsMu.RUnlock()
+	if len(c.tipAccounts) == 0 {
+		return solana.PublicKey{}, ErrTipAccountUnavailable
 	}
-	tipAccountPublicKey, err := solana.PublicKeyFromBase58(tipAccount.Address)
-	return &JitoClient{
-		rpcClient:  rpcClient,
-		tipAccount: tipAccountPublicKey,
-	}, nil
+	next := c.nextTip.Add(1)
+	return c.tipAccounts[next%uint64(len(c.tipAccounts))], nil
 }
 
-func createTipTransaction(privateKey solana.PrivateKey, amount uint64, recentBlockhash solana.Hash, tipAddress string) (*solana.Transaction, error) {
-	tipAccount, err := solana.PublicKeyFromBase58(tipAddress)
+// newTipInstruction transfers amount lamports from payer to the next tip account
+func (c *JitoClient) newTipInstruction(payer solana.PublicKey, amount uint64) (solana.Instruction, error) {
+	tipAccount, err := c.NextTipAccount()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse tip account: %v", err)
+		return nil, err
 	}
+	return system.NewTransferInstruction(amount, payer, tipAccount).Build(), nil
+}
 
+func createTipTransaction(privateKey solana.PrivateKey, amount uint64, recentBlockhash solana.Hash, tipAccount solana.PublicKey) (*solana.Transaction, error) {
 	tx, err := solana.NewTransaction(
 		[]solana.Instruction{
 			system.NewTransferInstruction(
@@ -49,7 +138,7 @@ func createTipTransaction(privateKey solana.PrivateKey, amount uint64, recentBlo
 		solana.TransactionPayer(privateKey.PublicKey()),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create tip transaction: %v", err)
+		return nil, fmt.Errorf("failed to create tip transaction: %w", err)
 	}
 
 	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
@@ -59,64 +148,230 @@ func createTipTransaction(privateKey solana.PrivateKey, amount uint64, recentBlo
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to sign tip transaction: %v", err)
+		return nil, fmt.Errorf("failed to sign tip transaction: %w", err)
 	}
 
 	return tx, nil
 }
 
-func encodeTransaction(tx *solana.Transaction) string {
+func encodeTransaction(tx *solana.Transaction) (string, error) {
 	serializedTx, err := tx.MarshalBinary()
 	if err != nil {
-		log.Fatalf("Failed to serialize transaction: %v", err)
+		return "", fmt.Errorf("failed to serialize transaction: %w", err)
 	}
-	return base64.StdEncoding.EncodeToString(serializedTx)
+	return base64.StdEncoding.EncodeToString(serializedTx), nil
 }
 
-func (c *JitoClient) CheckBundleStatus(bundleId string) {
-	maxAttempts := 5
-	pollInterval := 5 * time.Second
+// encodeBundle encodes the transactions of a bundle, checking their number
+func encodeBundle(txs []*solana.Transaction) ([]string, error) {
+	if len(txs) == 0 || len(txs) > MaxBundleTransactions {
+		return nil, fmt.Errorf("a bundle holds 1 to %d transactions, not %d", MaxBundleTransactions, len(txs))
+	}
+	encoded := make([]string, len(txs))
+	for i, tx := range txs {
+		var err error
+		if encoded[i], err = encodeTransaction(tx); err != nil {
+			return nil, err
+		}
+	}
+	return encoded, nil
+}
 
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		time.Sleep(pollInterval)
+// SendBundle sends signed transactions as a bundle that executes in order and all or nothing,
+// and returns the bundle id. One of the transactions must tip the tip account.
+func (c *JitoClient) SendBundle(ctx context.Context, txs ...*solana.Transaction) (string, error) {
+	encoded, err := encodeBundle(txs)
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	bundleIdRaw, err := c.rpcClient.SendBundle([][]string{encoded})
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrBundleRejected, err)
+	}
+	var bundleId string
+	if err := json.Unmarshal(bundleIdRaw, &bundleId); err != nil {
+		return "", fmt.Errorf("%w: unexpected bundle id %s: %w", ErrBundleRejected, bundleIdRaw, err)
+	}
+	return bundleId, nil
+}
 
-		statusResponse, err := c.rpcClient.GetBundleStatuses([]string{bundleId})
-		if err != nil {
-			log.Printf("Attempt %d: Failed to get bundle status: %v", attempt, err)
-			continue
-		}
+// BundleStatus is the status of a sent bundle
+type BundleStatus string
+
+const (
+	// BundlePending is a bundle the block engine has not landed, failed or dropped yet
+	BundlePending BundleStatus = "Pending"
+	// BundleLanded is a bundle that landed on chain
+	BundleLanded BundleStatus = "Landed"
+	// BundleFailed is a bundle whose transactions failed or that lost its auctions
+	BundleFailed BundleStatus = "Failed"
+	// BundleInvalid is a bundle the block engine does not know or dropped
+	BundleInvalid BundleStatus = "Invalid"
+)
 
-		if len(statusResponse.Value) == 0 {
-			log.Printf("Attempt %d: No bundle status available", attempt)
+// BundleResult is a status update of a sent bundle
+type BundleResult struct {
+	BundleID string
+	Status   BundleStatus
+	// Slot is the slot a landed bundle landed in
+	Slot uint64
+	// ConfirmationStatus is the commitment a landed bundle reached: processed, confirmed or finalized
+	ConfirmationStatus string
+	// Transactions are the signatures of the transactions of a landed bundle
+	Transactions []solana.Signature
+	// Err is the error a landed bundle reported, as the block engine returned it; nil when it succeeded
+	Err interface{}
+}
+
+// bundleStatuses is the result of getBundleStatuses. The err of a status is a Rust Result,
+// {"Ok":null} or {"Err":...}; only its Err arm is kept.
+type bundleStatuses struct {
+	Value []struct {
+		BundleID           string   `json:"bundle_id"`
+		Transactions       []string `json:"transactions"`
+		Slot               uint64   `json:"slot"`
+		ConfirmationStatus string   `json:"confirmation_status"`
+		Err                struct {
+			Err interface{} `json:"Err"`
+		} `json:"err"`
+	} `json:"value"`
+}
+
+// final reports whether the status of the bundle can no longer change
+func (r BundleResult) final() bool {
+	return r.Status == BundleFailed || r.Status == BundleInvalid ||
+		(r.Status == BundleLanded && r.ConfirmationStatus == "finalized")
+}
+
+// GetBundleStatus returns the current status of a bundle sent in the last five minutes
+func (c *JitoClient) GetBundleStatus(ctx context.Context, bundleId string) (BundleResult, error) {
+	result := BundleResult{BundleID: bundleId}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	inflightRaw, err := c.rpcClient.GetInflightBundleStatuses([][]string{{bundleId}})
+	if err != nil {
+		return result, fmt.Errorf("failed to get inflight bundle status: %w", err)
+	}
+	var inflight struct {
+		Value []*struct {
+			Status     BundleStatus `json:"status"`
+			LandedSlot *uint64      `json:"landed_slot"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(inflightRaw, &inflight); err != nil {
+		return result, fmt.Errorf("failed to decode inflight bundle status: %w", err)
+	}
+	if len(inflight.Value) == 0 || inflight.Value[0] == nil {
+		result.Status = BundleInvalid
+		return result, nil
+	}
+	result.Status = inflight.Value[0].Status
+	if result.Status != BundleLanded {
+		return result, nil
+	}
+	if inflight.Value[0].LandedSlot != nil {
+		result.Slot = *inflight.Value[0].LandedSlot
+	}
+
+	// Landed bundles report their transactions, commitment and error
+	var statuses bundleStatuses
+	if err := c.call(ctx, "/getBundleStatuses", "getBundleStatuses", [][]string{{bundleId}}, &statuses); err != nil {
+		return result, fmt.Errorf("failed to get bundle status: %w", err)
+	}
+	for _, status := range statuses.Value {
+		if status.BundleID != bundleId {
 			continue
 		}
-
-		bundleStatus := statusResponse.Value[0]
-		log.Printf("Attempt %d: Bundle status: %s", attempt, bundleStatus.ConfirmationStatus)
-
-		switch bundleStatus.ConfirmationStatus {
-		case "processed":
-			fmt.Println("Bundle has been processed by the cluster. Continuing to poll...")
-		case "confirmed":
-			fmt.Println("Bundle has been confirmed by the cluster. Continuing to poll...")
-		case "finalized":
-			fmt.Printf("Bundle has been finalized by the cluster in slot %d.\n", bundleStatus.Slot)
-			if bundleStatus.Err.Ok == nil {
-				fmt.Println("Bundle executed successfully.")
-				fmt.Println("Transaction URLs:")
-				for _, txID := range bundleStatus.Transactions {
-					solscanURL := fmt.Sprintf("https://solscan.io/tx/%s", txID)
-					fmt.Printf("- %s\n", solscanURL)
-				}
-			} else {
-				fmt.Printf("Bundle execution failed with error: %v\n", bundleStatus.Err.Ok)
+		result.Slot = status.Slot
+		result.ConfirmationStatus = status.ConfirmationStatus
+		result.Err = status.Err.Err
+		for _, txID := range status.Transactions {
+			sig, err := solana.SignatureFromBase58(txID)
+			if err != nil {
+				return result, fmt.Errorf("invalid bundle transaction signature %q: %w", txID, err)
 			}
-			return
-		default:
-			fmt.Printf("Unexpected status: %s. Please check the bundle manually.\n", bundleStatus.ConfirmationStatus)
-			return
+			result.Transactions = append(result.Transactions, sig)
 		}
 	}
+	return result, nil
+}
+
+// call posts a JSON-RPC request of method to path under the block engine URL and decodes its result into out
+func (c *JitoClient) call(ctx context.Context, path, method string, params, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.rpcClient.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.rpcClient.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-	log.Printf("Maximum polling attempts reached. Final status unknown.")
+	var rpcResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return fmt.Errorf("failed to decode %s response (%s): %w", method, resp.Status, err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("%s failed: %s (%d)", method, rpcResp.Error.Message, rpcResp.Error.Code)
+	}
+	if err := json.Unmarshal(rpcResp.Result, out); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+// WatchBundle polls the status of a bundle in the background and sends every change of it on
+// the returned channel. The channel is closed after a final status (failed, invalid or landed
+// and finalized), or once ctx is done or StatusTimeout has passed. Failed polls are retried.
+func (c *JitoClient) WatchBundle(ctx context.Context, bundleId string) <-chan BundleResult {
+	results := make(chan BundleResult, 1)
+	go func() {
+		defer close(results)
+		ctx, cancel := context.WithTimeout(ctx, c.StatusTimeout)
+		defer cancel()
+
+		ticker := time.NewTicker(c.StatusPollInterval)
+		defer ticker.Stop()
+		var last BundleResult
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			result, err := c.GetBundleStatus(ctx, bundleId)
+			if err != nil {
+				continue
+			}
+			if result.Status == last.Status && result.ConfirmationStatus == last.ConfirmationStatus {
+				continue
+			}
+			last = result
+			select {
+			case results <- result:
+			case <-ctx.Done():
+				return
+			}
+			if result.final() {
+				return
+			}
+		}
+	}()
+	return results
 }
@@ -0,0 +1,183 @@
+package sol
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// newTestClient creates a client of rpc using jito as its block engine, which must serve getTipAccounts
+func newTestClient(t *testing.T, rpc, jito *fakeJSONRPC) *Client {
+	t.Helper()
+	client, err := NewClient(context.Background(), rpc.URL, jito.URL, 1000)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+// tipAccounts returns count fresh tip accounts and their getTipAccounts result
+func tipAccounts(count int) ([]solana.PublicKey, []string) {
+	keys := make([]solana.PublicKey, count)
+	result := make([]string, count)
+	for i := range keys {
+		keys[i] = solana.NewWallet().PublicKey()
+		result[i] = keys[i].String()
+	}
+	return keys, result
+}
+
+func TestNewClientReturnsJitoError(t *testing.T) {
+	rpc := newFakeJSONRPC(t)
+	for _, tt := range []struct {
+		name  string
+		setup func(jito *fakeJSONRPC)
+	}{
+		{"request fails", func(jito *fakeJSONRPC) { jito.fail("getTipAccounts", "rate limited") }},
+		{"no tip accounts", func(jito *fakeJSONRPC) { jito.answer("getTipAccounts", []string{}) }},
+		{"invalid tip account", func(jito *fakeJSONRPC) { jito.answer("getTipAccounts", []string{"not a key"}) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			jito := newFakeJSONRPC(t)
+			tt.setup(jito)
+			client, err := NewClient(context.Background(), rpc.URL, jito.URL, 1000)
+			if !errors.Is(err, ErrTipAccountUnavailable) {
+				t.Fatalf("NewClient error = %v, want %v", err, ErrTipAccountUnavailable)
+			}
+			if client != nil {
+				t.Errorf("NewClient returned a client along with error %v", err)
+			}
+		})
+	}
+}
+
+func TestNextTipAccountRotates(t *testing.T) {
+	jito := newFakeJSONRPC(t)
+	keys, result := tipAccounts(3)
+	jito.answer("getTipAccounts", result)
+	client := newTestClient(t, newFakeJSONRPC(t), jito)
+
+	seen := make(map[solana.PublicKey]int)
+	for i := 0; i < 6; i++ {
+		account, err := client.jitoClient.NextTipAccount()
+		if err != nil {
+			t.Fatalf("NextTipAccount: %v", err)
+		}
+		seen[account]++
+	}
+	for _, key := range keys {
+		if seen[key] != 2 {
+			t.Errorf("tip account %s paid %d times in 6 bundles, want 2", key, seen[key])
+		}
+	}
+
+	// A failed refresh keeps the cached accounts
+	jito.fail("getTipAccounts", "unavailable")
+	if err := client.jitoClient.RefreshTipAccounts(context.Background()); !errors.Is(err, ErrTipAccountUnavailable) {
+		t.Errorf("RefreshTipAccounts error = %v, want %v", err, ErrTipAccountUnavailable)
+	}
+	if got := client.jitoClient.TipAccounts(); len(got) != len(keys) {
+		t.Errorf("%d tip accounts cached after a failed refresh, want %d", len(got), len(keys))
+	}
+}
+
+func TestSendBundleRejected(t *testing.T) {
+	jito := newFakeJSONRPC(t)
+	_, result := tipAccounts(1)
+	jito.answer("getTipAccounts", result)
+	rpc := newFakeJSONRPC(t)
+	rpc.answer("getLatestBlockhash", latestBlockhashResult(solana.Hash{1}.String()))
+	client := newTestClient(t, rpc, jito)
+	ctx := context.Background()
+
+	payer := solana.NewWallet().PrivateKey
+	txs, err := client.SignBundle(ctx, []solana.PrivateKey{payer}, MinJitoTip, true, []solana.Instruction{
+		testTransfer(payer.PublicKey()),
+	})
+	if err != nil {
+		t.Fatalf("SignBundle: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		answer func()
+	}{
+		{"rpc error", func() { jito.fail("sendBundle", "bundle contains an already processed transaction") }},
+		{"unexpected result", func() { jito.answer("sendBundle", map[string]interface{}{"id": 1}) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.answer()
+			if _, err := client.SendBundle(ctx, txs...); !errors.Is(err, ErrBundleRejected) {
+				t.Errorf("SendBundle error = %v, want %v", err, ErrBundleRejected)
+			}
+		})
+	}
+
+	jito.answer("sendBundle", "bundle-id")
+	if id, err := client.SendBundle(ctx, txs...); err != nil || id != "bundle-id" {
+		t.Errorf("SendBundle = %q, %v, want bundle-id", id, err)
+	}
+}
+
+func TestJitoCallsWithoutJitoClient(t *testing.T) {
+	client, err := NewClient(context.Background(), newFakeJSONRPC(t).URL, "", 1000)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	payer := solana.NewWallet().PrivateKey
+	if _, err := client.SendTxWithJito(context.Background(), MinJitoTip, []solana.PrivateKey{payer}, &solana.Transaction{}); !errors.Is(err, ErrJitoNotConfigured) {
+		t.Errorf("SendTxWithJito error = %v, want %v", err, ErrJitoNotConfigured)
+	}
+	if _, err := client.SendBundle(context.Background(), &solana.Transaction{}); !errors.Is(err, ErrJitoNotConfigured) {
+		t.Errorf("SendBundle error = %v, want %v", err, ErrJitoNotConfigured)
+	}
+}
+
+func TestGetBundleStatusKeepsBundleError(t *testing.T) {
+	jito := newFakeJSONRPC(t)
+	_, result := tipAccounts(1)
+	jito.answer("getTipAccounts", result)
+	client := newTestClient(t, newFakeJSONRPC(t), jito)
+	sig := solana.Signature{7}
+	jito.answer("getInflightBundleStatuses", map[string]interface{}{
+		"context": map[string]interface{}{"slot": 50},
+		"value":   []interface{}{map[string]interface{}{"bundle_id": "bundle-id", "status": "Landed", "landed_slot": 42}},
+	})
+
+	for _, tt := range []struct {
+		name    string
+		err     interface{}
+		wantErr bool
+	}{
+		{"succeeded", map[string]interface{}{"Ok": nil}, false},
+		{"failed", map[string]interface{}{"Err": map[string]interface{}{"InstructionError": []interface{}{0, "InvalidAccountData"}}}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			jito.answer("getBundleStatuses", map[string]interface{}{
+				"context": map[string]interface{}{"slot": 50},
+				"value": []interface{}{map[string]interface{}{
+					"bundle_id":           "bundle-id",
+					"transactions":        []string{sig.String()},
+					"slot":                43,
+					"confirmation_status": "confirmed",
+					"err":                 tt.err,
+				}},
+			})
+			status, err := client.jitoClient.GetBundleStatus(context.Background(), "bundle-id")
+			if err != nil {
+				t.Fatalf("GetBundleStatus: %v", err)
+			}
+			if status.Status != BundleLanded || status.Slot != 43 || status.ConfirmationStatus != "confirmed" {
+				t.Errorf("GetBundleStatus = %+v, want landed and confirmed at slot 43", status)
+			}
+			if len(status.Transactions) != 1 || status.Transactions[0] != sig {
+				t.Errorf("Transactions = %v, want [%s]", status.Transactions, sig)
+			}
+			if (status.Err != nil) != tt.wantErr {
+				t.Errorf("Err = %v, want an error: %v", status.Err, tt.wantErr)
+			}
+		})
+	}
+}
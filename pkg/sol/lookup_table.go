@@ -0,0 +1,238 @@
+package sol
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// AddressLookupTableProgramID is the address lookup table program
+var AddressLookupTableProgramID = solana.MustPublicKeyFromBase58("AddressLookupTab1e1111111111111111111111111")
+
+const (
+	// Instructions of the address lookup table program
+	lookupTableInstructionCreate     = 0
+	lookupTableInstructionExtend     = 2
+	lookupTableInstructionDeactivate = 3
+	lookupTableInstructionClose      = 4
+
+	// MaxLookupTableAddresses is the number of addresses a lookup table can hold
+	MaxLookupTableAddresses = addresslookuptable.LOOKUP_TABLE_MAX_ADDRESSES
+	// extendLookupTableChunk is how many addresses one extend transaction adds, keeping it under MaxTransactionSize
+	extendLookupTableChunk = 20
+)
+
+// GetAddressLookupTables loads the addresses of lookup tables, keyed by table, in the form
+// SignTransactionWithLookupTables takes. Deactivated tables are rejected.
+func (c *Client) GetAddressLookupTables(ctx context.Context, tables ...solana.PublicKey) (map[solana.PublicKey]solana.PublicKeySlice, error) {
+	accounts, err := c.GetMultipleAccountsChunked(ctx, tables)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lookup tables: %w", err)
+	}
+	result := make(map[solana.PublicKey]solana.PublicKeySlice, len(tables))
+	for _, table := range tables {
+		account, ok := accounts[table]
+		if !ok {
+			return nil, fmt.Errorf("lookup table %s not found", table)
+		}
+		if account.Owner != AddressLookupTableProgramID {
+			return nil, fmt.Errorf("account %s is not a lookup table", table)
+		}
+		state, err := addresslookuptable.DecodeAddressLookupTableState(account.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode lookup table %s: %w", table, err)
+		}
+		if state.DeactivationSlot != math.MaxUint64 {
+			return nil, fmt.Errorf("lookup table %s is deactivated", table)
+		}
+		result[table] = state.Addresses
+	}
+	return result, nil
+}
+
+// FindLookupTableAddress derives the address of the lookup table authority creates at recentSlot
+func FindLookupTableAddress(authority solana.PublicKey, recentSlot uint64) (solana.PublicKey, uint8, error) {
+	slot := make([]byte, 8)
+	binary.LittleEndian.PutUint64(slot, recentSlot)
+	return solana.FindProgramAddress([][]byte{authority[:], slot}, AddressLookupTableProgramID)
+}
+
+// NewCreateLookupTableInstruction creates a lookup table owned by authority and paid by payer.
+// recentSlot must be a recent slot, e.g. from GetSlot; it returns the address of the new table.
+func NewCreateLookupTableInstruction(authority, payer solana.PublicKey, recentSlot uint64) (solana.Instruction, solana.PublicKey, error) {
+	table, bump, err := FindLookupTableAddress(authority, recentSlot)
+	if err != nil {
+		return nil, solana.PublicKey{}, fmt.Errorf("failed to find lookup table address: %w", err)
+	}
+	data := make([]byte, 4+8+1)
+	binary.LittleEndian.PutUint32(data[0:4], lookupTableInstructionCreate)
+	binary.LittleEndian.PutUint64(data[4:12], recentSlot)
+	data[12] = bump
+	accounts := solana.AccountMetaSlice{
+		solana.NewAccountMeta(table, true, false),
+		solana.NewAccountMeta(authority, false, true),
+		solana.NewAccountMeta(payer, true, true),
+		solana.NewAccountMeta(solana.SystemProgramID, false, false),
+	}
+	return solana.NewInstruction(AddressLookupTableProgramID, accounts, data), table, nil
+}
+
+// NewExtendLookupTableInstruction appends addresses to a lookup table, paying rent from payer
+func NewExtendLookupTableInstruction(table, authority, payer solana.PublicKey, addresses []solana.PublicKey) solana.Instruction {
+	data := make([]byte, 4+8, 4+8+32*len(addresses))
+	binary.LittleEndian.PutUint32(data[0:4], lookupTableInstructionExtend)
+	binary.LittleEndian.PutUint64(data[4:12], uint64(len(addresses)))
+	for _, address := range addresses {
+		data = append(data, address[:]...)
+	}
+	accounts := solana.AccountMetaSlice{
+		solana.NewAccountMeta(table, true, false),
+		solana.NewAccountMeta(authority, false, true),
+		solana.NewAccountMeta(payer, true, true),
+		solana.NewAccountMeta(solana.SystemProgramID, false, false),
+	}
+	return solana.NewInstruction(AddressLookupTableProgramID, accounts, data)
+}
+
+// NewDeactivateLookupTableInstruction deactivates a lookup table so it can be closed once it cools down
+func NewDeactivateLookupTableInstruction(table, authority solana.PublicKey) solana.Instruction {
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, lookupTableInstructionDeactivate)
+	accounts := solana.AccountMetaSlice{
+		solana.NewAccountMeta(table, true, false),
+		solana.NewAccountMeta(authority, false, true),
+	}
+	return solana.NewInstruction(AddressLookupTableProgramID, accounts, data)
+}
+
+// NewCloseLookupTableInstruction closes a deactivated lookup table and sends its rent to recipient
+func NewCloseLookupTableInstruction(table, authority, recipient solana.PublicKey) solana.Instruction {
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, lookupTableInstructionClose)
+	accounts := solana.AccountMetaSlice{
+		solana.NewAccountMeta(table, true, false),
+		solana.NewAccountMeta(authority, false, true),
+		solana.NewAccountMeta(recipient, true, false),
+	}
+	return solana.NewInstruction(AddressLookupTableProgramID, accounts, data)
+}
+
+// LookupTableCandidates returns the accounts of instrs a lookup table can hold, in order of
+// first use: signers and invoked programs stay in the message, so they are left out.
+// Building the swap instructions of a route once gives the pool accounts worth keeping in a table.
+func LookupTableCandidates(instrs ...solana.Instruction) solana.PublicKeySlice {
+	programs := make(map[solana.PublicKey]struct{}, len(instrs))
+	signers := make(map[solana.PublicKey]struct{})
+	for _, instr := range instrs {
+		programs[instr.ProgramID()] = struct{}{}
+		for _, account := range instr.Accounts() {
+			if account.IsSigner {
+				signers[account.PublicKey] = struct{}{}
+			}
+		}
+	}
+	var candidates solana.PublicKeySlice
+	for _, instr := range instrs {
+		for _, account := range instr.Accounts() {
+			_, isProgram := programs[account.PublicKey]
+			_, isSigner := signers[account.PublicKey]
+			if !isProgram && !isSigner {
+				candidates.UniqueAppend(account.PublicKey)
+			}
+		}
+	}
+	return candidates
+}
+
+// CreateLookupTable creates a lookup table owned and paid by authority holding up to 20 addresses,
+// in one transaction, and waits for it with SendAndConfirmTx. Add more with ExtendLookupTable.
+// A table can be used from the slot after it was last extended. The outcome is returned along
+// with the error of a transaction that failed or expired.
+func (c *Client) CreateLookupTable(ctx context.Context, authority solana.PrivateKey, addresses []solana.PublicKey) (solana.PublicKey, *TxOutcome, error) {
+	if len(addresses) > extendLookupTableChunk {
+		return solana.PublicKey{}, nil, fmt.Errorf("%d addresses do not fit in the create transaction, at most %d do",
+			len(addresses), extendLookupTableChunk)
+	}
+	slot, err := c.GetSlot(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return solana.PublicKey{}, nil, fmt.Errorf("failed to get slot: %w", err)
+	}
+	createInst, table, err := NewCreateLookupTableInstruction(authority.PublicKey(), authority.PublicKey(), slot)
+	if err != nil {
+		return solana.PublicKey{}, nil, err
+	}
+	instrs := []solana.Instruction{createInst}
+	if len(addresses) > 0 {
+		instrs = append(instrs, NewExtendLookupTableInstruction(table, authority.PublicKey(), authority.PublicKey(), addresses))
+	}
+	tx, err := c.SignTransaction(ctx, []solana.PrivateKey{authority}, instrs...)
+	if err != nil {
+		return solana.PublicKey{}, nil, err
+	}
+	outcome, err := c.SendAndConfirmTx(ctx, tx)
+	if err != nil {
+		return solana.PublicKey{}, outcome, fmt.Errorf("failed to create lookup table: %w", err)
+	}
+	if err := lookupTableTxError(outcome); err != nil {
+		return solana.PublicKey{}, outcome, fmt.Errorf("failed to create lookup table %s: %w", table, err)
+	}
+	return table, outcome, nil
+}
+
+// ExtendLookupTable adds the addresses a lookup table does not hold yet, in transactions of
+// up to 20 addresses sent one after the other with SendAndConfirmTx. It returns the outcome of
+// each transaction sent and stops at the first one that is not confirmed; running it again
+// only sends what is still missing.
+func (c *Client) ExtendLookupTable(ctx context.Context, authority solana.PrivateKey, table solana.PublicKey, addresses []solana.PublicKey) ([]*TxOutcome, error) {
+	tables, err := c.GetAddressLookupTables(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	var missing solana.PublicKeySlice
+	for _, address := range addresses {
+		if !tables[table].Contains(address) {
+			missing.UniqueAppend(address)
+		}
+	}
+	if len(tables[table])+len(missing) > MaxLookupTableAddresses {
+		return nil, fmt.Errorf("lookup table %s would hold %d addresses, more than %d",
+			table, len(tables[table])+len(missing), MaxLookupTableAddresses)
+	}
+	var outcomes []*TxOutcome
+	for start := 0; start < len(missing); start += extendLookupTableChunk {
+		chunk := missing[start:min(start+extendLookupTableChunk, len(missing))]
+		extendInst := NewExtendLookupTableInstruction(table, authority.PublicKey(), authority.PublicKey(), chunk)
+		tx, err := c.SignTransaction(ctx, []solana.PrivateKey{authority}, extendInst)
+		if err != nil {
+			return outcomes, err
+		}
+		outcome, err := c.SendAndConfirmTx(ctx, tx)
+		if outcome != nil {
+			outcomes = append(outcomes, outcome)
+		}
+		if err != nil {
+			return outcomes, fmt.Errorf("failed to extend lookup table %s: %w", table, err)
+		}
+		if err := lookupTableTxError(outcome); err != nil {
+			return outcomes, fmt.Errorf("failed to extend lookup table %s: %w", table, err)
+		}
+	}
+	return outcomes, nil
+}
+
+// lookupTableTxError returns why a lookup table transaction was not confirmed, nil if it was
+func lookupTableTxError(outcome *TxOutcome) error {
+	switch outcome.Status {
+	case TxConfirmed:
+		return nil
+	case TxFailed:
+		return fmt.Errorf("transaction %s failed: %w", outcome.Signature, outcome.Err)
+	default:
+		return fmt.Errorf("transaction %s %s", outcome.Signature, outcome.Status)
+	}
+}
@@ -0,0 +1,191 @@
+package sol
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+)
+
+// lookupTableData returns the account data of an active lookup table holding addresses
+func lookupTableData(authority solana.PublicKey, addresses ...solana.PublicKey) []byte {
+	data := make([]byte, addresslookuptable.LOOKUP_TABLE_META_SIZE, addresslookuptable.LOOKUP_TABLE_META_SIZE+32*len(addresses))
+	binary.LittleEndian.PutUint32(data[0:4], 1)
+	binary.LittleEndian.PutUint64(data[4:12], math.MaxUint64)
+	data[21] = 1
+	copy(data[22:54], authority[:])
+	for _, address := range addresses {
+		data = append(data, address[:]...)
+	}
+	return data
+}
+
+// newKeys returns count fresh public keys
+func newKeys(count int) []solana.PublicKey {
+	keys := make([]solana.PublicKey, count)
+	for i := range keys {
+		keys[i] = solana.NewWallet().PublicKey()
+	}
+	return keys
+}
+
+// extendedAddresses returns the addresses an extend lookup table instruction adds
+func extendedAddresses(t *testing.T, tx *solana.Transaction) []solana.PublicKey {
+	t.Helper()
+	var addresses []solana.PublicKey
+	for _, instr := range tx.Message.Instructions {
+		if program, _ := tx.Message.Program(instr.ProgramIDIndex); program != AddressLookupTableProgramID {
+			continue
+		}
+		if binary.LittleEndian.Uint32(instr.Data[0:4]) != lookupTableInstructionExtend {
+			continue
+		}
+		count := binary.LittleEndian.Uint64(instr.Data[4:12])
+		for i := range count {
+			addresses = append(addresses, solana.PublicKeyFromBytes(instr.Data[12+32*i:12+32*(i+1)]))
+		}
+	}
+	return addresses
+}
+
+func TestLookupTableCandidates(t *testing.T) {
+	signer, program, other := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	a, b, c := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	instrs := []solana.Instruction{
+		solana.NewInstruction(program, solana.AccountMetaSlice{
+			solana.NewAccountMeta(signer, true, true),
+			solana.NewAccountMeta(b, true, false),
+			solana.NewAccountMeta(a, false, false),
+			// Invoked by the next instruction
+			solana.NewAccountMeta(other, false, false),
+		}, nil),
+		solana.NewInstruction(other, solana.AccountMetaSlice{
+			solana.NewAccountMeta(a, true, false),
+			// A signer of another instruction
+			solana.NewAccountMeta(signer, false, false),
+			solana.NewAccountMeta(program, false, false),
+			solana.NewAccountMeta(c, false, false),
+		}, nil),
+	}
+	if got, want := LookupTableCandidates(instrs...), (solana.PublicKeySlice{b, a, c}); !slices.Equal(got, want) {
+		t.Errorf("LookupTableCandidates = %v, want %v", got, want)
+	}
+}
+
+// newLookupTableClient returns a client confirming transactions right away through rpc
+func newLookupTableClient(t *testing.T, rpc *fakeJSONRPC) *Client {
+	t.Helper()
+	rpc.answer("getLatestBlockhash", latestBlockhashResult(solana.HashFromBytes(make([]byte, 32)).String()))
+	rpc.answer("getSignatureStatuses", signatureStatusResult(map[string]interface{}{
+		"slot": 7, "confirmations": nil, "err": nil, "confirmationStatus": "confirmed",
+	}))
+	client, err := NewClient(context.Background(), rpc.URL, "", 1000)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.ConfirmPollInterval = time.Millisecond
+	return client
+}
+
+func TestCreateLookupTable(t *testing.T) {
+	rpc := newFakeJSONRPC(t)
+	rpc.answer("getSlot", 42)
+	var sent []*solana.Transaction
+	rpc.handle("sendTransaction", sentTransactionsHandler(&sent))
+	client := newLookupTableClient(t, rpc)
+	authority := solana.NewWallet().PrivateKey
+
+	if _, _, err := client.CreateLookupTable(context.Background(), authority, newKeys(extendLookupTableChunk+1)); err == nil {
+		t.Errorf("CreateLookupTable accepted more addresses than one transaction fits")
+	}
+
+	addresses := newKeys(extendLookupTableChunk)
+	table, outcome, err := client.CreateLookupTable(context.Background(), authority, addresses)
+	if err != nil {
+		t.Fatalf("CreateLookupTable: %v", err)
+	}
+	if want, _, _ := FindLookupTableAddress(authority.PublicKey(), 42); table != want {
+		t.Errorf("table = %s, want %s", table, want)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("sent %d transactions, want 1", len(sent))
+	}
+	if outcome.Status != TxConfirmed || outcome.Signature != sent[0].Signatures[0] || outcome.Slot != 7 {
+		t.Errorf("outcome = %+v, want the confirmed create transaction", outcome)
+	}
+	if got := extendedAddresses(t, sent[0]); !slices.Equal(got, addresses) {
+		t.Errorf("create transaction adds %d addresses, want %d", len(got), len(addresses))
+	}
+
+	rpc.answer("getSignatureStatuses", signatureStatusResult(map[string]interface{}{
+		"slot": 7, "confirmations": nil, "err": "InsufficientFundsForRent", "confirmationStatus": "confirmed",
+	}))
+	_, outcome, err = client.CreateLookupTable(context.Background(), authority, addresses)
+	if err == nil || outcome == nil || outcome.Status != TxFailed {
+		t.Errorf("CreateLookupTable of a failed transaction = %+v, %v, want the failed outcome and an error", outcome, err)
+	}
+}
+
+func TestExtendLookupTable(t *testing.T) {
+	rpc := newFakeJSONRPC(t)
+	var sent []*solana.Transaction
+	rpc.handle("sendTransaction", sentTransactionsHandler(&sent))
+	client := newLookupTableClient(t, rpc)
+	authority := solana.NewWallet().PrivateKey
+	table := solana.NewWallet().PublicKey()
+
+	held := newKeys(3)
+	rpc.handle("getMultipleAccounts", ownedAccountsHandler(AddressLookupTableProgramID, map[solana.PublicKey][]byte{
+		table: lookupTableData(authority.PublicKey(), held...),
+	}))
+	missing := newKeys(2*extendLookupTableChunk + 5)
+	// The held addresses and repeated ones are not sent again
+	addresses := append(append(slices.Clone(held), missing...), missing[0], held[1])
+
+	outcomes, err := client.ExtendLookupTable(context.Background(), authority, table, addresses)
+	if err != nil {
+		t.Fatalf("ExtendLookupTable: %v", err)
+	}
+	if len(sent) != 3 || len(outcomes) != 3 {
+		t.Fatalf("sent %d transactions with %d outcomes, want 3", len(sent), len(outcomes))
+	}
+	var extended []solana.PublicKey
+	for i, tx := range sent {
+		chunk := extendedAddresses(t, tx)
+		if want := min(extendLookupTableChunk, len(missing)-i*extendLookupTableChunk); len(chunk) != want {
+			t.Errorf("transaction %d adds %d addresses, want %d", i, len(chunk), want)
+		}
+		extended = append(extended, chunk...)
+		if outcomes[i].Status != TxConfirmed || outcomes[i].Signature != tx.Signatures[0] {
+			t.Errorf("outcome %d = %+v, want transaction %d confirmed", i, outcomes[i], i)
+		}
+	}
+	if !slices.Equal(extended, missing) {
+		t.Errorf("extended the table with %v, want %v", extended, missing)
+	}
+
+	// A transaction that does not confirm stops the extension
+	sent = nil
+	rpc.answer("getSignatureStatuses", signatureStatusResult(map[string]interface{}{
+		"slot": 7, "confirmations": nil, "err": map[string]interface{}{"InstructionError": []interface{}{0, "InvalidInstructionData"}}, "confirmationStatus": "confirmed",
+	}))
+	outcomes, err = client.ExtendLookupTable(context.Background(), authority, table, addresses)
+	if err == nil {
+		t.Errorf("ExtendLookupTable of a failed transaction returned no error")
+	}
+	if len(sent) != 1 || len(outcomes) != 1 || outcomes[0].Status != TxFailed {
+		t.Errorf("sent %d transactions with outcomes %+v, want the one failed transaction", len(sent), outcomes)
+	}
+
+	rpc.handle("getMultipleAccounts", ownedAccountsHandler(AddressLookupTableProgramID, map[solana.PublicKey][]byte{
+		table: lookupTableData(authority.PublicKey(), newKeys(MaxLookupTableAddresses-1)...),
+	}))
+	if _, err := client.ExtendLookupTable(context.Background(), authority, table, newKeys(2)); err == nil {
+		t.Errorf("ExtendLookupTable overfilled the table")
+	}
+}
@@ -0,0 +1,212 @@
+package sol
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	// MintAccountDataSize is the size of a mint without extensions
+	MintAccountDataSize = 82
+	// tokenAccountDataSize is the size of a token account; Token-2022 pads mints with extensions to it
+	tokenAccountDataSize = 165
+	// accountTypeMint marks a Token-2022 account with extensions as a mint
+	accountTypeMint = 1
+
+	// extensionTypeTransferFeeConfig is the Token-2022 extension type of TransferFeeConfig
+	extensionTypeTransferFeeConfig = 1
+	// transferFeeConfigSize is the size of the TransferFeeConfig extension data
+	transferFeeConfigSize = 108
+	// extensionTypeTransferHook is the Token-2022 extension type of TransferHook
+	extensionTypeTransferHook = 14
+	// transferHookSize is the size of the TransferHook extension data
+	transferHookSize = 64
+
+	// MaxFeeBasisPoints is the transfer fee rate that charges the whole amount, up to the maximum fee
+	MaxFeeBasisPoints uint64 = 10000
+)
+
+// Mint is the metadata of an SPL Token or Token-2022 mint that affects swaps
+type Mint struct {
+	Address  solana.PublicKey
+	Program  solana.PublicKey
+	Supply   uint64
+	Decimals uint8
+	// TransferFeeConfig is set for Token-2022 mints with the TransferFeeConfig extension
+	TransferFeeConfig *TransferFeeConfig
+	// TransferHook is set for Token-2022 mints with the TransferHook extension
+	TransferHook *TransferHook
+}
+
+// TransferHook is the Token-2022 TransferHook extension. Every transfer of the mint invokes
+// ProgramID unless it is the zero key.
+type TransferHook struct {
+	Authority solana.PublicKey
+	ProgramID solana.PublicKey
+}
+
+// TransferFee is a transfer fee schedule that applies from Epoch on
+type TransferFee struct {
+	Epoch                  uint64
+	MaximumFee             uint64
+	TransferFeeBasisPoints uint16
+}
+
+// TransferFeeConfig is the Token-2022 TransferFeeConfig extension. A new fee set by the authority
+// becomes NewerTransferFee and takes effect at its epoch; until then OlderTransferFee applies.
+type TransferFeeConfig struct {
+	TransferFeeConfigAuthority solana.PublicKey
+	WithdrawWithheldAuthority  solana.PublicKey
+	WithheldAmount             uint64
+	OlderTransferFee           TransferFee
+	NewerTransferFee           TransferFee
+}
+
+// ParseMint parses the data of a mint account, including its Token-2022 extensions.
+// The account data does not tell the owning program, so Program is left for the caller to set.
+func ParseMint(address solana.PublicKey, data []byte) (*Mint, error) {
+	if len(data) < MintAccountDataSize {
+		return nil, fmt.Errorf("mint %s data too short: %d bytes", address, len(data))
+	}
+	mint := &Mint{
+		Address:  address,
+		Supply:   binary.LittleEndian.Uint64(data[36:44]),
+		Decimals: data[44],
+	}
+	if len(data) == MintAccountDataSize {
+		return mint, nil
+	}
+
+	// Extensions follow the base mint padded to the size of a token account and the account type
+	if len(data) <= tokenAccountDataSize || data[tokenAccountDataSize] != accountTypeMint {
+		return nil, fmt.Errorf("mint %s has invalid extension data", address)
+	}
+	for offset := tokenAccountDataSize + 1; offset+4 <= len(data); {
+		extensionType := binary.LittleEndian.Uint16(data[offset : offset+2])
+		length := int(binary.LittleEndian.Uint16(data[offset+2 : offset+4]))
+		offset += 4
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("mint %s extension %d overflows the account", address, extensionType)
+		}
+		value := data[offset : offset+length]
+		offset += length
+
+		switch extensionType {
+		case 0:
+			// Uninitialized: no more extensions
+			return mint, nil
+		case extensionTypeTransferFeeConfig:
+			if length != transferFeeConfigSize {
+				return nil, fmt.Errorf("mint %s transfer fee config has %d bytes", address, length)
+			}
+			mint.TransferFeeConfig = parseTransferFeeConfig(value)
+		case extensionTypeTransferHook:
+			if length != transferHookSize {
+				return nil, fmt.Errorf("mint %s transfer hook has %d bytes", address, length)
+			}
+			mint.TransferHook = &TransferHook{
+				Authority: solana.PublicKeyFromBytes(value[0:32]),
+				ProgramID: solana.PublicKeyFromBytes(value[32:64]),
+			}
+		}
+	}
+	return mint, nil
+}
+
+func parseTransferFeeConfig(data []byte) *TransferFeeConfig {
+	return &TransferFeeConfig{
+		TransferFeeConfigAuthority: solana.PublicKeyFromBytes(data[0:32]),
+		WithdrawWithheldAuthority:  solana.PublicKeyFromBytes(data[32:64]),
+		WithheldAmount:             binary.LittleEndian.Uint64(data[64:72]),
+		OlderTransferFee:           parseTransferFee(data[72:90]),
+		NewerTransferFee:           parseTransferFee(data[90:108]),
+	}
+}
+
+func parseTransferFee(data []byte) TransferFee {
+	return TransferFee{
+		Epoch:                  binary.LittleEndian.Uint64(data[0:8]),
+		MaximumFee:             binary.LittleEndian.Uint64(data[8:16]),
+		TransferFeeBasisPoints: binary.LittleEndian.Uint16(data[16:18]),
+	}
+}
+
+// EpochFee returns the transfer fee schedule in effect at epoch
+func (c *TransferFeeConfig) EpochFee(epoch uint64) TransferFee {
+	if epoch >= c.NewerTransferFee.Epoch {
+		return c.NewerTransferFee
+	}
+	return c.OlderTransferFee
+}
+
+// Fee returns the fee withheld from a transfer of amount, rounded up and capped at MaximumFee
+func (f TransferFee) Fee(amount uint64) uint64 {
+	bps := min(uint64(f.TransferFeeBasisPoints), MaxFeeBasisPoints)
+	if bps == 0 || amount == 0 {
+		return 0
+	}
+	// amount * bps / 10000 rounded up, in 128 bits
+	hi, lo := bits.Mul64(amount, bps)
+	fee, rem := bits.Div64(hi, lo, MaxFeeBasisPoints)
+	if rem != 0 {
+		fee++
+	}
+	return min(fee, f.MaximumFee)
+}
+
+// PreFeeAmount returns the amount to transfer so that postFeeAmount arrives after the fee
+func (f TransferFee) PreFeeAmount(postFeeAmount uint64) (uint64, error) {
+	bps := min(uint64(f.TransferFeeBasisPoints), MaxFeeBasisPoints)
+	if bps == 0 || postFeeAmount == 0 {
+		return postFeeAmount, nil
+	}
+	if bps < MaxFeeBasisPoints {
+		// postFeeAmount * 10000 / (10000 - bps) rounded up, in 128 bits
+		denominator := MaxFeeBasisPoints - bps
+		hi, lo := bits.Mul64(postFeeAmount, MaxFeeBasisPoints)
+		lo, carry := bits.Add64(lo, denominator-1, 0)
+		hi += carry
+		if hi < denominator {
+			preFeeAmount, _ := bits.Div64(hi, lo, denominator)
+			if preFeeAmount-postFeeAmount < f.MaximumFee {
+				return preFeeAmount, nil
+			}
+		}
+	}
+	// The fee is capped at MaximumFee
+	preFeeAmount, carry := bits.Add64(postFeeAmount, f.MaximumFee, 0)
+	if carry != 0 {
+		return 0, fmt.Errorf("pre-fee amount of %d overflows", postFeeAmount)
+	}
+	return preFeeAmount, nil
+}
+
+// GetMints fetches the metadata of the given mints, keyed by mint
+func (c *Client) GetMints(ctx context.Context, mints ...solana.PublicKey) (map[solana.PublicKey]*Mint, error) {
+	accounts, err := c.GetMultipleAccountsChunked(ctx, mints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mint accounts: %w", err)
+	}
+	result := make(map[solana.PublicKey]*Mint, len(mints))
+	for _, address := range mints {
+		account, ok := accounts[address]
+		if !ok {
+			return nil, fmt.Errorf("mint %s not found", address)
+		}
+		if !IsTokenProgram(account.Owner) {
+			return nil, fmt.Errorf("mint %s is owned by %s: %w", address, account.Owner, ErrUnsupportedTokenProgram)
+		}
+		mint, err := ParseMint(address, account.Data)
+		if err != nil {
+			return nil, err
+		}
+		mint.Program = account.Owner
+		c.mintPrograms.Store(address, account.Owner)
+		result[address] = mint
+	}
+	return result, nil
+}
@@ -0,0 +1,198 @@
+package sol
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// extension is a Token-2022 extension in TLV form
+type extension struct {
+	extensionType uint16
+	value         []byte
+}
+
+// token2022MintData returns the data of a Token-2022 mint with supply, decimals and extensions
+func token2022MintData(supply uint64, decimals uint8, extensions ...extension) []byte {
+	data := make([]byte, tokenAccountDataSize+1)
+	binary.LittleEndian.PutUint64(data[36:], supply)
+	data[44] = decimals
+	data[45] = 1 // is_initialized
+	data[tokenAccountDataSize] = accountTypeMint
+	for _, ext := range extensions {
+		data = binary.LittleEndian.AppendUint16(data, ext.extensionType)
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(ext.value)))
+		data = append(data, ext.value...)
+	}
+	return data
+}
+
+// transferFeeConfigExtension returns the TransferFeeConfig extension of config
+func transferFeeConfigExtension(config TransferFeeConfig) extension {
+	value := make([]byte, 0, transferFeeConfigSize)
+	value = append(value, config.TransferFeeConfigAuthority[:]...)
+	value = append(value, config.WithdrawWithheldAuthority[:]...)
+	value = binary.LittleEndian.AppendUint64(value, config.WithheldAmount)
+	for _, fee := range []TransferFee{config.OlderTransferFee, config.NewerTransferFee} {
+		value = binary.LittleEndian.AppendUint64(value, fee.Epoch)
+		value = binary.LittleEndian.AppendUint64(value, fee.MaximumFee)
+		value = binary.LittleEndian.AppendUint16(value, fee.TransferFeeBasisPoints)
+	}
+	return extension{extensionTypeTransferFeeConfig, value}
+}
+
+func TestParseMint(t *testing.T) {
+	address := solana.NewWallet().PublicKey()
+	config := TransferFeeConfig{
+		TransferFeeConfigAuthority: solana.NewWallet().PublicKey(),
+		WithdrawWithheldAuthority:  solana.NewWallet().PublicKey(),
+		WithheldAmount:             77,
+		OlderTransferFee:           TransferFee{Epoch: 500, MaximumFee: 1_000_000, TransferFeeBasisPoints: 100},
+		NewerTransferFee:           TransferFee{Epoch: 510, MaximumFee: 2_000_000, TransferFeeBasisPoints: 250},
+	}
+	hook := TransferHook{Authority: solana.NewWallet().PublicKey(), ProgramID: solana.NewWallet().PublicKey()}
+	hookExtension := extension{extensionTypeTransferHook, append(hook.Authority.Bytes(), hook.ProgramID.Bytes()...)}
+	// Metadata pointer, an extension the parser skips
+	otherExtension := extension{18, make([]byte, 64)}
+
+	plain := make([]byte, MintAccountDataSize)
+	binary.LittleEndian.PutUint64(plain[36:], 5000)
+	plain[44] = 6
+
+	mint, err := ParseMint(address, plain)
+	if err != nil {
+		t.Fatalf("ParseMint(plain): %v", err)
+	}
+	if mint.Address != address || mint.Supply != 5000 || mint.Decimals != 6 || mint.TransferFeeConfig != nil || mint.TransferHook != nil {
+		t.Errorf("ParseMint(plain) = %+v", mint)
+	}
+
+	data := token2022MintData(1_000_000, 9, otherExtension, transferFeeConfigExtension(config), hookExtension)
+	mint, err = ParseMint(address, data)
+	if err != nil {
+		t.Fatalf("ParseMint(token-2022): %v", err)
+	}
+	if mint.Supply != 1_000_000 || mint.Decimals != 9 {
+		t.Errorf("ParseMint(token-2022) supply %d decimals %d, want 1000000 and 9", mint.Supply, mint.Decimals)
+	}
+	if mint.TransferFeeConfig == nil || *mint.TransferFeeConfig != config {
+		t.Errorf("TransferFeeConfig = %+v, want %+v", mint.TransferFeeConfig, config)
+	}
+	if mint.TransferHook == nil || *mint.TransferHook != hook {
+		t.Errorf("TransferHook = %+v, want %+v", mint.TransferHook, hook)
+	}
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"short", make([]byte, MintAccountDataSize-1)},
+		{"no account type", make([]byte, tokenAccountDataSize)},
+		{"token account type", append(make([]byte, tokenAccountDataSize), 2)},
+		{"short fee config", token2022MintData(0, 0, extension{extensionTypeTransferFeeConfig, make([]byte, transferFeeConfigSize-1)})},
+		{"overflowing extension", token2022MintData(0, 0, transferFeeConfigExtension(config))[:tokenAccountDataSize+1+4+10]},
+	} {
+		if _, err := ParseMint(address, tt.data); err == nil {
+			t.Errorf("ParseMint(%s) accepted invalid data", tt.name)
+		}
+	}
+}
+
+func TestTransferFeeConfigEpochFee(t *testing.T) {
+	config := TransferFeeConfig{
+		OlderTransferFee: TransferFee{Epoch: 0, MaximumFee: 1000, TransferFeeBasisPoints: 100},
+		NewerTransferFee: TransferFee{Epoch: 10, MaximumFee: 5000, TransferFeeBasisPoints: 200},
+	}
+	for _, tt := range []struct {
+		epoch uint64
+		want  TransferFee
+	}{
+		{0, config.OlderTransferFee},
+		{9, config.OlderTransferFee},
+		{10, config.NewerTransferFee},
+		{11, config.NewerTransferFee},
+	} {
+		if got := config.EpochFee(tt.epoch); got != tt.want {
+			t.Errorf("EpochFee(%d) = %+v, want %+v", tt.epoch, got, tt.want)
+		}
+	}
+}
+
+func TestTransferFee(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		fee    TransferFee
+		amount uint64
+		want   uint64
+	}{
+		{"no fee", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 0}, 1_000_000, 0},
+		{"zero amount", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 100}, 0, 0},
+		{"exact", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 100}, 10_000, 100},
+		{"rounded up", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 100}, 10_001, 101},
+		{"smallest amount", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 1}, 1, 1},
+		{"capped", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 100}, 1_000_000, 1000},
+		{"whole amount", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 10000}, 600, 600},
+		{"whole amount capped", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 10000}, 5000, 1000},
+		{"rate above the maximum", TransferFee{MaximumFee: math.MaxUint64, TransferFeeBasisPoints: 20000}, 600, 600},
+		{"no 64-bit overflow", TransferFee{MaximumFee: math.MaxUint64, TransferFeeBasisPoints: 5000}, math.MaxUint64, math.MaxUint64/2 + 1},
+	} {
+		if got := tt.fee.Fee(tt.amount); got != tt.want {
+			t.Errorf("%s: Fee(%d) = %d, want %d", tt.name, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestTransferFeePreFeeAmount(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		fee  TransferFee
+		post uint64
+		want uint64
+	}{
+		{"no fee", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 0}, 10_000, 10_000},
+		{"zero amount", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 100}, 0, 0},
+		{"exact", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 100}, 9900, 10_000},
+		{"rounded up", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 100}, 9901, 10_002},
+		{"capped", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 100}, 1_000_000, 1_001_000},
+		{"whole amount", TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 10000}, 600, 1600},
+	} {
+		got, err := tt.fee.PreFeeAmount(tt.post)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: PreFeeAmount(%d) = %d, %v, want %d", tt.name, tt.post, got, err, tt.want)
+		}
+	}
+
+	overflow := TransferFee{MaximumFee: math.MaxUint64, TransferFeeBasisPoints: 10000}
+	if _, err := overflow.PreFeeAmount(1); err == nil {
+		t.Errorf("PreFeeAmount accepted a pre-fee amount above u64")
+	}
+}
+
+func TestTransferFeePreFeeAmountRoundTrip(t *testing.T) {
+	fees := []TransferFee{
+		{MaximumFee: 1000, TransferFeeBasisPoints: 1},
+		{MaximumFee: 1000, TransferFeeBasisPoints: 100},
+		{MaximumFee: 50, TransferFeeBasisPoints: 250},
+		{MaximumFee: math.MaxUint64, TransferFeeBasisPoints: 333},
+		{MaximumFee: math.MaxUint64, TransferFeeBasisPoints: 9999},
+		{MaximumFee: 1000, TransferFeeBasisPoints: 10000},
+	}
+	for _, fee := range fees {
+		for _, post := range []uint64{1, 2, 99, 1000, 4999, 5000, 123_456_789, 1 << 40} {
+			pre, err := fee.PreFeeAmount(post)
+			if err != nil {
+				t.Errorf("%+v: PreFeeAmount(%d): %v", fee, post, err)
+				continue
+			}
+			if got := pre - fee.Fee(pre); got != post {
+				t.Errorf("%+v: transferring PreFeeAmount(%d) = %d delivers %d", fee, post, pre, got)
+			}
+			// No smaller transfer delivers post
+			if less := pre - 1; less-fee.Fee(less) >= post {
+				t.Errorf("%+v: transferring %d, below PreFeeAmount(%d) = %d, delivers %d", fee, less, post, pre, less-fee.Fee(less))
+			}
+		}
+	}
+}
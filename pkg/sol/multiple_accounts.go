@@ -0,0 +1,59 @@
+package sol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// MaxMultipleAccounts is the maximum number of keys one getMultipleAccounts request accepts
+const MaxMultipleAccounts = 100
+
+// AccountData is the data and owner of an account and the slot it was read at
+type AccountData struct {
+	Data  []byte
+	Owner solana.PublicKey
+	Slot  uint64
+}
+
+// GetMultipleAccountsChunked fetches any number of accounts with concurrent getMultipleAccounts
+// requests of at most MaxMultipleAccounts keys each, all going through the rate limiter.
+// Accounts that do not exist are left out of the result.
+func (c *Client) GetMultipleAccountsChunked(ctx context.Context, accounts []solana.PublicKey) (map[solana.PublicKey]AccountData, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	result := make(map[solana.PublicKey]AccountData, len(accounts))
+	for start := 0; start < len(accounts); start += MaxMultipleAccounts {
+		chunk := accounts[start:min(start+MaxMultipleAccounts, len(accounts))]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.GetMultipleAccountsWithOpts(ctx, chunk)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to get multiple accounts: %w", err))
+				return
+			}
+			for i, account := range resp.Value {
+				if account == nil {
+					continue
+				}
+				result[chunk[i]] = AccountData{
+					Data:  account.Data.GetBinary(),
+					Owner: account.Owner,
+					Slot:  resp.Context.Slot,
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return result, nil
+}
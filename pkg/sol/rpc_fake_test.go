@@ -0,0 +1,181 @@
+package sol
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// fakeRPCError is a handler result answered as an RPC error with its message
+type fakeRPCError string
+
+// fakeJSONRPC is a JSON-RPC server answering each method with a fixed result or error. It serves
+// both the Solana RPC and the Jito block engine, which posts to paths under its base URL.
+type fakeJSONRPC struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	results map[string]interface{}
+	errors  map[string]string
+	// handlers compute the result of a method from its params
+	handlers map[string]func(params json.RawMessage) interface{}
+	// params holds the params of the last call of each method
+	params map[string]json.RawMessage
+	// calls counts the calls of each method
+	calls map[string]int
+}
+
+func newFakeJSONRPC(t *testing.T) *fakeJSONRPC {
+	t.Helper()
+	f := &fakeJSONRPC{
+		results:  make(map[string]interface{}),
+		errors:   make(map[string]string),
+		handlers: make(map[string]func(json.RawMessage) interface{}),
+		params:   make(map[string]json.RawMessage),
+		calls:    make(map[string]int),
+	}
+	f.Server = httptest.NewServer(f)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// answer makes method return result
+func (f *fakeJSONRPC) answer(method string, result interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.errors, method)
+	f.results[method] = result
+}
+
+// handle makes method return the result handler computes from its params
+func (f *fakeJSONRPC) handle(method string, handler func(params json.RawMessage) interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[method] = handler
+}
+
+// fail makes method return an RPC error with message
+func (f *fakeJSONRPC) fail(method, message string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors[method] = message
+}
+
+func (f *fakeJSONRPC) lastParams(method string) json.RawMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.params[method]
+}
+
+func (f *fakeJSONRPC) callCount(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+func (f *fakeJSONRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.calls[req.Method]++
+	f.params[req.Method] = req.Params
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if message, ok := f.errors[req.Method]; ok {
+		resp["error"] = map[string]interface{}{"code": -32000, "message": message}
+	} else if handler, ok := f.handlers[req.Method]; ok {
+		result := handler(req.Params)
+		if message, ok := result.(fakeRPCError); ok {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": string(message)}
+		} else {
+			resp["result"] = result
+		}
+	} else if result, ok := f.results[req.Method]; ok {
+		resp["result"] = result
+	} else {
+		resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found: " + req.Method}
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// latestBlockhashResult is a getLatestBlockhash result for blockhash
+func latestBlockhashResult(blockhash string) interface{} {
+	return map[string]interface{}{
+		"context": map[string]interface{}{"slot": 1},
+		"value":   map[string]interface{}{"blockhash": blockhash, "lastValidBlockHeight": 100},
+	}
+}
+
+// multipleAccountsHandler serves getMultipleAccounts from accounts, keyed by address
+func multipleAccountsHandler(accounts map[solana.PublicKey][]byte) func(json.RawMessage) interface{} {
+	return ownedAccountsHandler(solana.SystemProgramID, accounts)
+}
+
+// ownedAccountsHandler serves getMultipleAccounts from accounts owned by owner, keyed by address
+func ownedAccountsHandler(owner solana.PublicKey, accounts map[solana.PublicKey][]byte) func(json.RawMessage) interface{} {
+	return func(params json.RawMessage) interface{} {
+		var args []json.RawMessage
+		var keys []solana.PublicKey
+		if json.Unmarshal(params, &args) != nil || len(args) == 0 || json.Unmarshal(args[0], &keys) != nil {
+			return nil
+		}
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			data, ok := accounts[key]
+			if !ok {
+				continue
+			}
+			values[i] = map[string]interface{}{
+				"data":       []string{base64.StdEncoding.EncodeToString(data), "base64"},
+				"executable": false,
+				"lamports":   1,
+				"owner":      owner,
+				"rentEpoch":  0,
+			}
+		}
+		return map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": values}
+	}
+}
+
+// sentTransactionsHandler serves sendTransaction, appending each transaction to sent and
+// returning its signature
+func sentTransactionsHandler(sent *[]*solana.Transaction) func(json.RawMessage) interface{} {
+	return func(params json.RawMessage) interface{} {
+		var args []json.RawMessage
+		var encoded string
+		if json.Unmarshal(params, &args) != nil || len(args) == 0 || json.Unmarshal(args[0], &encoded) != nil {
+			return nil
+		}
+		tx, err := solana.TransactionFromBase64(encoded)
+		if err != nil {
+			return nil
+		}
+		*sent = append(*sent, tx)
+		return tx.Signatures[0].String()
+	}
+}
+
+// signatureStatusResult is a getSignatureStatuses result for one transaction with status, nil
+// when the cluster has not seen it
+func signatureStatusResult(status map[string]interface{}) interface{} {
+	var value interface{}
+	if status != nil {
+		value = status
+	}
+	return map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": []interface{}{value}}
+}
@@ -71,6 +71,38 @@ func (c *Client) GetLatestBlockhash(ctx context.Context, commitment rpc.Commitme
 	return c.rpcClient.GetLatestBlockhash(ctx, commitment)
 }
 
+// GetSlot wraps the RPC call with rate limiting
+func (c *Client) GetSlot(ctx context.Context, commitment rpc.CommitmentType) (uint64, error) {
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return 0, err
+	}
+	return c.rpcClient.GetSlot(ctx, commitment)
+}
+
+// GetBlockHeight wraps the RPC call with rate limiting
+func (c *Client) GetBlockHeight(ctx context.Context, commitment rpc.CommitmentType) (uint64, error) {
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return 0, err
+	}
+	return c.rpcClient.GetBlockHeight(ctx, commitment)
+}
+
+// IsBlockhashValid wraps the RPC call with rate limiting
+func (c *Client) IsBlockhashValid(ctx context.Context, blockhash solana.Hash, commitment rpc.CommitmentType) (*rpc.IsValidBlockhashResult, error) {
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.rpcClient.IsBlockhashValid(ctx, blockhash, commitment)
+}
+
+// GetSignatureStatuses wraps the RPC call with rate limiting
+func (c *Client) GetSignatureStatuses(ctx context.Context, searchHistory bool, sigs ...solana.Signature) (*rpc.GetSignatureStatusesResult, error) {
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.rpcClient.GetSignatureStatuses(ctx, searchHistory, sigs...)
+}
+
 // SimulateTransaction wraps the RPC call with rate limiting
 func (c *Client) SimulateTransaction(ctx context.Context, tx *solana.Transaction) (*rpc.SimulateTransactionResponse, error) {
 	if err := c.rateLimiter.Wait(ctx); err != nil {
@@ -79,6 +111,22 @@ func (c *Client) SimulateTransaction(ctx context.Context, tx *solana.Transaction
 	return c.rpcClient.SimulateTransaction(ctx, tx)
 }
 
+// SimulateTransactionWithOpts wraps the RPC call with rate limiting
+func (c *Client) SimulateTransactionWithOpts(ctx context.Context, tx *solana.Transaction, opts *rpc.SimulateTransactionOpts) (*rpc.SimulateTransactionResponse, error) {
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.rpcClient.SimulateTransactionWithOpts(ctx, tx, opts)
+}
+
+// GetRecentPrioritizationFees wraps the RPC call with rate limiting
+func (c *Client) GetRecentPrioritizationFees(ctx context.Context, accounts solana.PublicKeySlice) ([]rpc.PriorizationFeeResult, error) {
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.rpcClient.GetRecentPrioritizationFees(ctx, accounts)
+}
+
 // SendTransactionWithOpts wraps the RPC call with rate limiting
 func (c *Client) SendTransactionWithOpts(ctx context.Context, tx *solana.Transaction, opts rpc.TransactionOpts) (solana.Signature, error) {
 	if err := c.rateLimiter.Wait(ctx); err != nil {
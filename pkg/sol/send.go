@@ -2,9 +2,7 @@ package sol
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"log"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
@@ -25,34 +23,34 @@ func (c *Client) SendTx(ctx context.Context, tx *solana.Transaction) (solana.Sig
 	return sig, nil
 }
 
+// SendTxWithJito sends mainTx in a Jito bundle followed by a transfer of jitoTipAmount lamports
+// from the first signer to the next tip account, and returns the bundle id right away; WatchBundle
+// reports what becomes of it. A zero jitoTipAmount tips what the client's TipStrategy sizes.
 func (c *Client) SendTxWithJito(ctx context.Context, jitoTipAmount uint64, signers []solana.PrivateKey, mainTx *solana.Transaction) (string, error) {
-
-	res, err := c.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+	if len(signers) == 0 {
+		return "", fmt.Errorf("at least one signer is required")
+	}
+	jito, err := c.jito()
 	if err != nil {
-		log.Fatalf("Failed to get blockhash: %v", err)
+		return "", err
 	}
-
-	tipTx, err := createTipTransaction(signers[0], jitoTipAmount, res.Value.Blockhash, c.jitoClient.tipAccount.String())
+	jitoTipAmount, err = c.tip(ctx, jitoTipAmount)
 	if err != nil {
-		log.Fatalf("Failed to create tip transaction: %v", err)
+		return "", err
 	}
 
-	bundleRequest := [][]string{{
-		encodeTransaction(mainTx),
-		encodeTransaction(tipTx),
-	}}
+	res, err := c.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrBlockhashUnavailable, err)
+	}
 
-	bundleIdRaw, err := c.jitoClient.rpcClient.SendBundle(bundleRequest)
+	tipAccount, err := jito.NextTipAccount()
 	if err != nil {
-		log.Fatalf("Failed to send bundle: %v", err)
+		return "", err
 	}
-	var bundleId string
-	if err := json.Unmarshal(bundleIdRaw, &bundleId); err != nil {
-		log.Fatalf("Failed to unmarshal bundle ID: %v", err)
+	tipTx, err := createTipTransaction(signers[0], jitoTipAmount, res.Value.Blockhash, tipAccount)
+	if err != nil {
+		return "", err
 	}
-
-	fmt.Printf("Bundle sent successfully. Bundle ID: %s\n", bundleId)
-	c.jitoClient.CheckBundleStatus(bundleId)
-
-	return bundleId, nil
+	return jito.SendBundle(ctx, mainTx, tipTx)
 }
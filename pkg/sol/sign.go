@@ -2,47 +2,104 @@ package sol
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
 )
 
+// MaxTransactionSize is the largest serialized transaction the network accepts
+const MaxTransactionSize = 1232
+
+var (
+	// ErrTransactionTooLarge is returned when a signed transaction exceeds MaxTransactionSize
+	ErrTransactionTooLarge = errors.New("transaction too large")
+	// ErrBlockhashUnavailable is returned when no recent blockhash could be fetched to sign with
+	ErrBlockhashUnavailable = errors.New("recent blockhash unavailable")
+)
+
+// SignTransaction builds a legacy transaction of instrs paid by the first signer and signs it.
+// The client's FeeStrategy, if any, prepends the ComputeBudget instructions.
 func (c *Client) SignTransaction(ctx context.Context, signers []solana.PrivateKey, instrs ...solana.Instruction) (*solana.Transaction, error) {
+	return c.signTransaction(ctx, signers, nil, c.FeeStrategy, instrs)
+}
 
+// SignTransactionWithLookupTables builds a v0 transaction of instrs that references accounts held
+// in the lookup tables by index instead of by key, and signs it. tables maps each table to its
+// addresses, as GetAddressLookupTables returns them. Signers and invoked programs are never looked up.
+func (c *Client) SignTransactionWithLookupTables(
+	ctx context.Context,
+	signers []solana.PrivateKey,
+	tables map[solana.PublicKey]solana.PublicKeySlice,
+	instrs ...solana.Instruction,
+) (*solana.Transaction, error) {
+	return c.signTransaction(ctx, signers, tables, c.FeeStrategy, instrs)
+}
+
+func (c *Client) signTransaction(
+	ctx context.Context,
+	signers []solana.PrivateKey,
+	tables map[solana.PublicKey]solana.PublicKeySlice,
+	strategy *FeeStrategy,
+	instrs []solana.Instruction,
+) (*solana.Transaction, error) {
 	if len(signers) == 0 {
 		return nil, fmt.Errorf("at least one signer is required")
 	}
 
 	res, err := c.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
 	if err != nil {
-		log.Fatalf("Failed to get blockhash: %v", err)
+		return nil, fmt.Errorf("%w: %w", ErrBlockhashUnavailable, err)
 	}
+	c.rememberBlockhash(res.Value.Blockhash, res.Value.LastValidBlockHeight)
+
+	sign := func(instrs []solana.Instruction) (*solana.Transaction, error) {
+		// Create new transaction with all instructions
+		opts := []solana.TransactionOption{solana.TransactionPayer(signers[0].PublicKey())}
+		if len(tables) > 0 {
+			opts = append(opts, solana.TransactionAddressTables(tables))
+		}
+		tx, err := solana.NewTransaction(instrs, res.Value.Blockhash, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create transaction: %w", err)
+		}
 
-	// Create new transaction with all instructions
-	tx, err := solana.NewTransaction(
-		instrs,
-		res.Value.Blockhash,
-		solana.TransactionPayer(signers[0].PublicKey()),
-	)
+		// Sign the transaction with all provided signers
+		_, err = tx.Sign(
+			func(key solana.PublicKey) *solana.PrivateKey {
+				for _, payer := range signers {
+					if payer.PublicKey().Equals(key) {
+						return &payer
+					}
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign transaction: %w", err)
+		}
+		return tx, nil
+	}
+
+	if strategy != nil && !hasComputeBudgetInstruction(instrs) {
+		budget, err := c.computeBudgetInstructions(ctx, strategy, instrs, sign)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set compute budget: %w", err)
+		}
+		instrs = append(budget, instrs...)
+	}
+	tx, err := sign(instrs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create transaction: %w", err)
+		return nil, err
 	}
 
-	// Sign the transaction with all provided signers
-	_, err = tx.Sign(
-		func(key solana.PublicKey) *solana.PrivateKey {
-			for _, payer := range signers {
-				if payer.PublicKey().Equals(key) {
-					return &payer
-				}
-			}
-			return nil
-		},
-	)
+	raw, err := tx.MarshalBinary()
 	if err != nil {
-		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+		return nil, fmt.Errorf("failed to serialize transaction: %w", err)
+	}
+	if len(raw) > MaxTransactionSize {
+		return nil, fmt.Errorf("%w: %d bytes, at most %d fit", ErrTransactionTooLarge, len(raw), MaxTransactionSize)
 	}
 	return tx, nil
 }
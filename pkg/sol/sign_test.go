@@ -0,0 +1,40 @@
+package sol
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+)
+
+// testTransfer returns a transfer of one lamport from payer
+func testTransfer(payer solana.PublicKey) solana.Instruction {
+	return system.NewTransferInstruction(1, payer, solana.NewWallet().PublicKey()).Build()
+}
+
+func TestSigningWithoutBlockhash(t *testing.T) {
+	jito := newFakeJSONRPC(t)
+	_, result := tipAccounts(1)
+	jito.answer("getTipAccounts", result)
+	rpc := newFakeJSONRPC(t)
+	rpc.fail("getLatestBlockhash", "node is behind")
+	client := newTestClient(t, rpc, jito)
+	ctx := context.Background()
+
+	payer := solana.NewWallet().PrivateKey
+	signers := []solana.PrivateKey{payer}
+	if _, err := client.SignTransaction(ctx, signers, testTransfer(payer.PublicKey())); !errors.Is(err, ErrBlockhashUnavailable) {
+		t.Errorf("SignTransaction error = %v, want %v", err, ErrBlockhashUnavailable)
+	}
+	if _, err := client.SignBundle(ctx, signers, MinJitoTip, false, []solana.Instruction{testTransfer(payer.PublicKey())}); !errors.Is(err, ErrBlockhashUnavailable) {
+		t.Errorf("SignBundle error = %v, want %v", err, ErrBlockhashUnavailable)
+	}
+	if _, err := client.SendTxWithJito(ctx, MinJitoTip, signers, &solana.Transaction{}); !errors.Is(err, ErrBlockhashUnavailable) {
+		t.Errorf("SendTxWithJito error = %v, want %v", err, ErrBlockhashUnavailable)
+	}
+	if n := jito.callCount("sendBundle"); n != 0 {
+		t.Errorf("sendBundle called %d times without a blockhash", n)
+	}
+}
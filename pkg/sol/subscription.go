@@ -0,0 +1,346 @@
+package sol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+)
+
+const (
+	// DefaultReconnectDelay is the first wait before reconnecting a dropped WebSocket
+	DefaultReconnectDelay = 500 * time.Millisecond
+	// DefaultMaxReconnectDelay caps the exponential reconnect backoff
+	DefaultMaxReconnectDelay = 30 * time.Second
+)
+
+// ErrSubscriptionManagerNotStarted is returned when subscribing before Start
+var ErrSubscriptionManagerNotStarted = errors.New("subscription manager not started")
+
+// AccountUpdate is a change to a subscribed account
+type AccountUpdate struct {
+	Account  solana.PublicKey
+	Owner    solana.PublicKey
+	Lamports uint64
+	Data     []byte
+	// Slot is the slot at which the account had this state
+	Slot uint64
+}
+
+// AccountHandler receives the updates of a subscription.
+// Handlers run on the subscription's receive goroutine and should return quickly.
+type AccountHandler func(update AccountUpdate)
+
+type subscriptionKind int
+
+const (
+	accountSubscription subscriptionKind = iota
+	programSubscription
+)
+
+// managedSubscription is a subscription that survives reconnects
+type managedSubscription struct {
+	kind    subscriptionKind
+	key     solana.PublicKey
+	filters []rpc.RPCFilter
+	handler AccountHandler
+
+	// unsubscribe stops the subscription on the current connection
+	unsubscribe func()
+	done        chan struct{}
+}
+
+// SubscriptionManager multiplexes account and program subscriptions over one WebSocket
+// connection. When the connection drops it reconnects with exponential backoff and
+// resubscribes everything that is still registered.
+type SubscriptionManager struct {
+	Endpoint   string
+	Commitment rpc.CommitmentType
+
+	ReconnectDelay    time.Duration
+	MaxReconnectDelay time.Duration
+
+	mu         sync.Mutex
+	ctx        context.Context
+	cancel     context.CancelFunc
+	conn       *ws.Client
+	generation uint64
+	nextID     uint64
+	subs       map[uint64]*managedSubscription
+	reconnect  chan uint64
+}
+
+// NewSubscriptionManager creates a manager for the WebSocket RPC endpoint
+func NewSubscriptionManager(endpoint string) *SubscriptionManager {
+	return &SubscriptionManager{
+		Endpoint:          endpoint,
+		Commitment:        rpc.CommitmentConfirmed,
+		ReconnectDelay:    DefaultReconnectDelay,
+		MaxReconnectDelay: DefaultMaxReconnectDelay,
+		subs:              make(map[uint64]*managedSubscription),
+		reconnect:         make(chan uint64, 1),
+	}
+}
+
+// Start connects to Endpoint and keeps the connection alive until ctx is done or Close is called
+func (m *SubscriptionManager) Start(ctx context.Context) error {
+	conn, err := ws.Connect(ctx, m.Endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", m.Endpoint, err)
+	}
+
+	m.mu.Lock()
+	if m.ctx != nil {
+		m.mu.Unlock()
+		conn.Close()
+		return errors.New("subscription manager already started")
+	}
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	m.conn = conn
+	m.generation++
+	m.mu.Unlock()
+
+	go m.supervise()
+	return nil
+}
+
+// Close unsubscribes everything and closes the connection
+func (m *SubscriptionManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+	for id, sub := range m.subs {
+		m.stopLocked(sub)
+		delete(m.subs, id)
+	}
+	if m.conn != nil {
+		m.conn.Close()
+		m.conn = nil
+	}
+}
+
+// SubscribeAccount delivers every change of account to handler and returns the subscription ID
+func (m *SubscriptionManager) SubscribeAccount(account solana.PublicKey, handler AccountHandler) (uint64, error) {
+	return m.subscribe(&managedSubscription{
+		kind:    accountSubscription,
+		key:     account,
+		handler: handler,
+	})
+}
+
+// SubscribeProgram delivers every change of an account owned by programID and matching
+// filters to handler and returns the subscription ID
+func (m *SubscriptionManager) SubscribeProgram(programID solana.PublicKey, filters []rpc.RPCFilter, handler AccountHandler) (uint64, error) {
+	return m.subscribe(&managedSubscription{
+		kind:    programSubscription,
+		key:     programID,
+		filters: filters,
+		handler: handler,
+	})
+}
+
+// Unsubscribe stops the subscription with the given ID
+func (m *SubscriptionManager) Unsubscribe(id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sub, ok := m.subs[id]
+	if !ok {
+		return
+	}
+	m.stopLocked(sub)
+	delete(m.subs, id)
+}
+
+func (m *SubscriptionManager) subscribe(sub *managedSubscription) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ctx == nil {
+		return 0, ErrSubscriptionManagerNotStarted
+	}
+
+	sub.done = make(chan struct{})
+	// While reconnecting the subscription is only registered; it starts with the new connection
+	if m.conn != nil {
+		if err := m.startLocked(sub); err != nil {
+			return 0, err
+		}
+	}
+	m.nextID++
+	m.subs[m.nextID] = sub
+	return m.nextID, nil
+}
+
+// startLocked subscribes sub on the current connection and starts its receive goroutine
+func (m *SubscriptionManager) startLocked(sub *managedSubscription) error {
+	generation := m.generation
+	switch sub.kind {
+	case accountSubscription:
+		wsSub, err := m.conn.AccountSubscribe(sub.key, m.Commitment)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to account %s: %w", sub.key, err)
+		}
+		sub.unsubscribe = wsSub.Unsubscribe
+		go m.receive(sub, generation, func(ctx context.Context) (AccountUpdate, error) {
+			result, err := wsSub.Recv(ctx)
+			if err != nil {
+				return AccountUpdate{}, err
+			}
+			return AccountUpdate{
+				Account:  sub.key,
+				Owner:    result.Value.Owner,
+				Lamports: result.Value.Lamports,
+				Data:     result.Value.Data.GetBinary(),
+				Slot:     result.Context.Slot,
+			}, nil
+		})
+	case programSubscription:
+		wsSub, err := m.conn.ProgramSubscribeWithOpts(sub.key, m.Commitment, "", sub.filters)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to program %s: %w", sub.key, err)
+		}
+		sub.unsubscribe = wsSub.Unsubscribe
+		go m.receive(sub, generation, func(ctx context.Context) (AccountUpdate, error) {
+			result, err := wsSub.Recv(ctx)
+			if err != nil {
+				return AccountUpdate{}, err
+			}
+			update := AccountUpdate{
+				Account: result.Value.Pubkey,
+				Slot:    result.Context.Slot,
+			}
+			if account := result.Value.Account; account != nil {
+				update.Owner = account.Owner
+				update.Lamports = account.Lamports
+				update.Data = account.Data.GetBinary()
+			}
+			return update, nil
+		})
+	default:
+		return fmt.Errorf("unknown subscription kind %d", sub.kind)
+	}
+	return nil
+}
+
+// stopLocked unsubscribes sub from the current connection for good
+func (m *SubscriptionManager) stopLocked(sub *managedSubscription) {
+	close(sub.done)
+	if sub.unsubscribe != nil && m.conn != nil {
+		sub.unsubscribe()
+	}
+	sub.unsubscribe = nil
+}
+
+// receive delivers updates to the handler until the subscription is stopped.
+// A receive error on a live subscription means the connection dropped.
+func (m *SubscriptionManager) receive(sub *managedSubscription, generation uint64, recv func(ctx context.Context) (AccountUpdate, error)) {
+	for {
+		update, err := recv(m.ctx)
+		if err != nil {
+			select {
+			case <-sub.done:
+			case <-m.ctx.Done():
+			default:
+				log.Printf("subscription to %s failed: %v", sub.key, err)
+				m.requestReconnect(generation)
+			}
+			return
+		}
+		select {
+		case <-sub.done:
+			return
+		default:
+		}
+		sub.handler(update)
+	}
+}
+
+// requestReconnect asks the supervisor to replace the connection of generation,
+// unless it was already replaced
+func (m *SubscriptionManager) requestReconnect(generation uint64) {
+	m.mu.Lock()
+	stale := generation != m.generation
+	m.mu.Unlock()
+	if stale {
+		return
+	}
+	select {
+	case m.reconnect <- generation:
+	default:
+	}
+}
+
+func (m *SubscriptionManager) supervise() {
+	for {
+		select {
+		case <-m.ctx.Done():
+			m.Close()
+			return
+		case generation := <-m.reconnect:
+			m.mu.Lock()
+			stale := generation != m.generation
+			m.mu.Unlock()
+			if !stale {
+				m.reconnectAndResubscribe()
+			}
+		}
+	}
+}
+
+// reconnectAndResubscribe replaces the connection, retrying with exponential backoff,
+// and subscribes every registered subscription on the new connection
+func (m *SubscriptionManager) reconnectAndResubscribe() {
+	m.mu.Lock()
+	if m.conn != nil {
+		m.conn.Close()
+		m.conn = nil
+	}
+	m.mu.Unlock()
+
+	delay := m.ReconnectDelay
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, m.MaxReconnectDelay)
+
+		log.Printf("reconnecting to %s", m.Endpoint)
+		conn, err := ws.Connect(m.ctx, m.Endpoint)
+		if err != nil {
+			log.Printf("failed to reconnect to %s: %v", m.Endpoint, err)
+			continue
+		}
+
+		m.mu.Lock()
+		if m.ctx.Err() != nil {
+			m.mu.Unlock()
+			conn.Close()
+			return
+		}
+		m.conn = conn
+		m.generation++
+		var resubscribeErr error
+		for _, sub := range m.subs {
+			if resubscribeErr = m.startLocked(sub); resubscribeErr != nil {
+				break
+			}
+		}
+		if resubscribeErr == nil {
+			m.mu.Unlock()
+			return
+		}
+		log.Printf("failed to resubscribe: %v", resubscribeErr)
+		m.conn.Close()
+		m.conn = nil
+		m.mu.Unlock()
+	}
+}
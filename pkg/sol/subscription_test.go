@@ -0,0 +1,195 @@
+package sol
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gorilla/websocket"
+)
+
+// fakeWSConn is a connection accepted by fakeWSServer
+type fakeWSConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+func (c *fakeWSConn) write(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+// fakeSubscription is an accountSubscribe request received by fakeWSServer
+type fakeSubscription struct {
+	conn    *fakeWSConn
+	id      uint64
+	account solana.PublicKey
+}
+
+// notify sends an account notification at slot with data
+func (s fakeSubscription) notify(slot uint64, data []byte) error {
+	return s.conn.write(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "accountNotification",
+		"params": map[string]interface{}{
+			"subscription": s.id,
+			"result": map[string]interface{}{
+				"context": map[string]interface{}{"slot": slot},
+				"value": map[string]interface{}{
+					"data":       []string{base64.StdEncoding.EncodeToString(data), "base64"},
+					"executable": false,
+					"lamports":   1,
+					"owner":      solana.SystemProgramID,
+					"rentEpoch":  0,
+				},
+			},
+		},
+	})
+}
+
+// fakeWSServer is a WebSocket RPC server that answers accountSubscribe and reports every
+// subscription on subscriptions
+type fakeWSServer struct {
+	*httptest.Server
+	subscriptions chan fakeSubscription
+
+	mu     sync.Mutex
+	nextID uint64
+}
+
+func newFakeWSServer(t *testing.T) *fakeWSServer {
+	t.Helper()
+	s := &fakeWSServer{subscriptions: make(chan fakeSubscription, 16)}
+	upgrader := websocket.Upgrader{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		fake := &fakeWSConn{conn: conn}
+		for {
+			var req struct {
+				ID     uint64            `json:"id"`
+				Method string            `json:"method"`
+				Params []json.RawMessage `json:"params"`
+			}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.nextID++
+			subID := s.nextID
+			s.mu.Unlock()
+			fake.write(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": subID})
+			if req.Method != "accountSubscribe" {
+				continue
+			}
+			var account solana.PublicKey
+			json.Unmarshal(req.Params[0], &account)
+			s.subscriptions <- fakeSubscription{conn: fake, id: subID, account: account}
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeWSServer) url() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// nextSubscription waits for the next accountSubscribe request
+func (s *fakeWSServer) nextSubscription(t *testing.T) fakeSubscription {
+	t.Helper()
+	select {
+	case sub := <-s.subscriptions:
+		return sub
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a subscription")
+		return fakeSubscription{}
+	}
+}
+
+func TestSubscriptionManagerResubscribesAfterDrop(t *testing.T) {
+	server := newFakeWSServer(t)
+	manager := NewSubscriptionManager(server.url())
+	manager.ReconnectDelay = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer manager.Close()
+
+	account := solana.NewWallet().PublicKey()
+	updates := make(chan AccountUpdate, 16)
+	if _, err := manager.SubscribeAccount(account, func(update AccountUpdate) {
+		updates <- update
+	}); err != nil {
+		t.Fatalf("SubscribeAccount: %v", err)
+	}
+	nextUpdate := func() AccountUpdate {
+		t.Helper()
+		select {
+		case update := <-updates:
+			return update
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an update")
+			return AccountUpdate{}
+		}
+	}
+
+	first := server.nextSubscription(t)
+	if first.account != account {
+		t.Fatalf("subscribed to %s, want %s", first.account, account)
+	}
+	if err := first.notify(10, []byte{1}); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if update := nextUpdate(); update.Slot != 10 || update.Account != account || string(update.Data) != "\x01" {
+		t.Fatalf("update = %+v, want slot 10 of %s", update, account)
+	}
+
+	// Drop the connection: the manager reconnects and subscribes again
+	first.conn.conn.Close()
+	second := server.nextSubscription(t)
+	if second.account != account {
+		t.Fatalf("resubscribed to %s, want %s", second.account, account)
+	}
+	if second.conn == first.conn {
+		t.Fatal("resubscribed on the dropped connection")
+	}
+	if err := second.notify(11, []byte{2}); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if update := nextUpdate(); update.Slot != 11 || string(update.Data) != "\x02" {
+		t.Fatalf("update after reconnect = %+v, want slot 11", update)
+	}
+
+	// Only one receive goroutine, of the current connection, delivers updates
+	if err := second.notify(12, []byte{3}); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if update := nextUpdate(); update.Slot != 12 {
+		t.Fatalf("update = %+v, want slot 12", update)
+	}
+	select {
+	case update := <-updates:
+		t.Fatalf("unexpected update %+v", update)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	manager.mu.Lock()
+	generation := manager.generation
+	manager.mu.Unlock()
+	if generation != 2 {
+		t.Errorf("generation = %d after one reconnect, want 2", generation)
+	}
+}
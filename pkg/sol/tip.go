@@ -0,0 +1,199 @@
+package sol
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"net/http"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	// JitoTipFloorURL serves the percentiles of the tips of recently landed bundles
+	JitoTipFloorURL = "https://bundles.jito.wtf/api/v1/bundles/tip_floor"
+
+	// MinJitoTip is the smallest tip the block engine accepts, in lamports
+	MinJitoTip = 1000
+	// DefaultTipPercentile is the percentile of landed tips a TipStrategy pays by default
+	DefaultTipPercentile = 50
+)
+
+// TipMode selects how a TipStrategy sizes tips
+type TipMode int
+
+const (
+	// TipFixed tips MinTip
+	TipFixed TipMode = iota
+	// TipProfitShare tips ProfitShareBps of the expected profit
+	TipProfitShare
+	// TipFloorPercentile tips Percentile of the tips of recently landed bundles, as Source reports them
+	TipFloorPercentile
+)
+
+// TipFloor holds percentiles of the tips of recently landed bundles, in lamports
+type TipFloor struct {
+	P25 uint64
+	P50 uint64
+	P75 uint64
+	P95 uint64
+	P99 uint64
+	// EMA50 is the exponential moving average of the median
+	EMA50 uint64
+}
+
+// Percentile returns the tip at percentile, one of 25, 50, 75, 95 and 99
+func (f *TipFloor) Percentile(percentile int) (uint64, error) {
+	switch percentile {
+	case 25:
+		return f.P25, nil
+	case 50:
+		return f.P50, nil
+	case 75:
+		return f.P75, nil
+	case 95:
+		return f.P95, nil
+	case 99:
+		return f.P99, nil
+	default:
+		return 0, fmt.Errorf("tip floor has no %dth percentile", percentile)
+	}
+}
+
+// TipFloorSource reports the tips of recently landed bundles
+type TipFloorSource interface {
+	TipFloor(ctx context.Context) (*TipFloor, error)
+}
+
+// HTTPTipFloorSource reads the tip floor from a URL serving it in the format of JitoTipFloorURL
+type HTTPTipFloorSource struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewJitoTipFloorSource creates a tip floor source reading JitoTipFloorURL
+func NewJitoTipFloorSource() *HTTPTipFloorSource {
+	return &HTTPTipFloorSource{
+		URL:    JitoTipFloorURL,
+		Client: http.DefaultClient,
+	}
+}
+
+// TipFloor fetches the latest tip floor
+func (s *HTTPTipFloorSource) TipFloor(ctx context.Context) (*TipFloor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tip floor request: %w", err)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tip floor: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get tip floor: %s", resp.Status)
+	}
+
+	// Tips are reported in SOL
+	var floors []struct {
+		P25   float64 `json:"landed_tips_25th_percentile"`
+		P50   float64 `json:"landed_tips_50th_percentile"`
+		P75   float64 `json:"landed_tips_75th_percentile"`
+		P95   float64 `json:"landed_tips_95th_percentile"`
+		P99   float64 `json:"landed_tips_99th_percentile"`
+		EMA50 float64 `json:"ema_landed_tips_50th_percentile"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&floors); err != nil {
+		return nil, fmt.Errorf("failed to decode tip floor: %w", err)
+	}
+	if len(floors) == 0 {
+		return nil, fmt.Errorf("tip floor is empty")
+	}
+	floor := floors[0]
+	return &TipFloor{
+		P25:   solToLamports(floor.P25),
+		P50:   solToLamports(floor.P50),
+		P75:   solToLamports(floor.P75),
+		P95:   solToLamports(floor.P95),
+		P99:   solToLamports(floor.P99),
+		EMA50: solToLamports(floor.EMA50),
+	}, nil
+}
+
+func solToLamports(sol float64) uint64 {
+	return uint64(math.Round(sol * float64(solana.LAMPORTS_PER_SOL)))
+}
+
+// TipStrategy sizes the tips of Jito bundles
+type TipStrategy struct {
+	Mode TipMode
+	// MinTip is the tip of TipFixed and the smallest tip of the other modes, in lamports
+	MinTip uint64
+	// MaxTip caps the tip; zero leaves it uncapped
+	MaxTip uint64
+	// ProfitShareBps is the share of the expected profit TipProfitShare tips
+	ProfitShareBps uint64
+	// Percentile is the percentile of landed tips TipFloorPercentile tips
+	Percentile int
+	// Source reports the landed tips for TipFloorPercentile
+	Source TipFloorSource
+}
+
+// NewTipStrategy creates a tip strategy paying the median tip of recently landed bundles
+func NewTipStrategy() *TipStrategy {
+	return &TipStrategy{
+		Mode:       TipFloorPercentile,
+		MinTip:     MinJitoTip,
+		Percentile: DefaultTipPercentile,
+		Source:     NewJitoTipFloorSource(),
+	}
+}
+
+// tip returns amount, or when it is zero the tip of the client's TipStrategy. The strategy does
+// not know the expected profit, so TipProfitShare tips its MinTip; callers that know it pass the
+// strategy's Tip instead.
+func (c *Client) tip(ctx context.Context, amount uint64) (uint64, error) {
+	if amount > 0 || c.TipStrategy == nil {
+		return amount, nil
+	}
+	tip, err := c.TipStrategy.Tip(ctx, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to size jito tip: %w", err)
+	}
+	return tip, nil
+}
+
+// Tip returns the tip in lamports for a bundle expected to earn expectedProfit lamports;
+// only TipProfitShare uses the expected profit
+func (s *TipStrategy) Tip(ctx context.Context, expectedProfit uint64) (uint64, error) {
+	var tip uint64
+	switch s.Mode {
+	case TipFixed:
+	case TipProfitShare:
+		hi, lo := bits.Mul64(expectedProfit, s.ProfitShareBps)
+		if hi >= 10000 {
+			return 0, fmt.Errorf("tip of %d bps of %d overflows", s.ProfitShareBps, expectedProfit)
+		}
+		tip, _ = bits.Div64(hi, lo, 10000)
+	case TipFloorPercentile:
+		if s.Source == nil {
+			return 0, fmt.Errorf("tip strategy has no tip floor source")
+		}
+		floor, err := s.Source.TipFloor(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if tip, err = floor.Percentile(s.Percentile); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("unknown tip mode %d", s.Mode)
+	}
+	tip = max(tip, s.MinTip)
+	if s.MaxTip > 0 {
+		tip = min(tip, s.MaxTip)
+	}
+	return tip, nil
+}
@@ -0,0 +1,177 @@
+package sol
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// tipFloorServer serves body as the tip floor, or fails when body is empty
+func tipFloorServer(t *testing.T, body string) *HTTPTipFloorSource {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body == "" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return &HTTPTipFloorSource{URL: server.URL, Client: server.Client()}
+}
+
+// testTipFloor is a tip floor in SOL in the format of JitoTipFloorURL
+const testTipFloor = `[{
+	"time": "2024-09-01T12:58:00Z",
+	"landed_tips_25th_percentile": 0.000005,
+	"landed_tips_50th_percentile": 0.00001,
+	"landed_tips_75th_percentile": 0.00005,
+	"landed_tips_95th_percentile": 0.0001,
+	"landed_tips_99th_percentile": 0.001,
+	"ema_landed_tips_50th_percentile": 0.000012
+}]`
+
+// tipLamports returns the lamports transferred by the last instruction of tx, a tip
+func tipLamports(t *testing.T, tx *solana.Transaction) uint64 {
+	t.Helper()
+	instrs := tx.Message.Instructions
+	if len(instrs) == 0 {
+		t.Fatalf("transaction has no instructions")
+	}
+	data := instrs[len(instrs)-1].Data
+	if len(data) != 12 || binary.LittleEndian.Uint32(data) != 2 {
+		t.Fatalf("last instruction %x is not a transfer", data)
+	}
+	return binary.LittleEndian.Uint64(data[4:])
+}
+
+func TestHTTPTipFloorSource(t *testing.T) {
+	floor, err := tipFloorServer(t, testTipFloor).TipFloor(context.Background())
+	if err != nil {
+		t.Fatalf("TipFloor: %v", err)
+	}
+	want := TipFloor{P25: 5000, P50: 10_000, P75: 50_000, P95: 100_000, P99: 1_000_000, EMA50: 12_000}
+	if *floor != want {
+		t.Errorf("TipFloor = %+v, want %+v", *floor, want)
+	}
+
+	for _, body := range []string{"", "[]"} {
+		if _, err := tipFloorServer(t, body).TipFloor(context.Background()); err == nil {
+			t.Errorf("TipFloor of %q succeeded", body)
+		}
+	}
+}
+
+func TestTipStrategy(t *testing.T) {
+	source := tipFloorServer(t, testTipFloor)
+	for _, tt := range []struct {
+		name     string
+		strategy TipStrategy
+		profit   uint64
+		want     uint64
+	}{
+		{"fixed", TipStrategy{Mode: TipFixed, MinTip: 5000}, 1_000_000, 5000},
+		{"profit share", TipStrategy{Mode: TipProfitShare, MinTip: MinJitoTip, ProfitShareBps: 100}, 2_000_000, 20_000},
+		{"profit share below min", TipStrategy{Mode: TipProfitShare, MinTip: MinJitoTip, ProfitShareBps: 100}, 50_000, MinJitoTip},
+		{"median", TipStrategy{Mode: TipFloorPercentile, MinTip: MinJitoTip, Percentile: 50, Source: source}, 0, 10_000},
+		{"percentile below min", TipStrategy{Mode: TipFloorPercentile, MinTip: 8000, Percentile: 25, Source: source}, 0, 8000},
+		{"percentile above max", TipStrategy{Mode: TipFloorPercentile, MaxTip: 500_000, Percentile: 99, Source: source}, 0, 500_000},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tip, err := tt.strategy.Tip(context.Background(), tt.profit)
+			if err != nil {
+				t.Fatalf("Tip: %v", err)
+			}
+			if tip != tt.want {
+				t.Errorf("Tip = %d, want %d", tip, tt.want)
+			}
+		})
+	}
+
+	unknown := TipStrategy{Mode: TipFloorPercentile, Percentile: 60, Source: source}
+	if _, err := unknown.Tip(context.Background(), 0); err == nil {
+		t.Errorf("Tip of the 60th percentile succeeded")
+	}
+}
+
+func TestBundlesUseTipStrategy(t *testing.T) {
+	jito := newFakeJSONRPC(t)
+	_, result := tipAccounts(2)
+	jito.answer("getTipAccounts", result)
+	jito.answer("sendBundle", "bundle-id")
+	rpc := newFakeJSONRPC(t)
+	rpc.answer("getLatestBlockhash", latestBlockhashResult(solana.Hash{1}.String()))
+	client := newTestClient(t, rpc, jito)
+	ctx := context.Background()
+	payer := solana.NewWallet().PrivateKey
+	signers := []solana.PrivateKey{payer}
+
+	// Without a strategy a zero tip adds none
+	txs, err := client.SignBundle(ctx, signers, 0, false, []solana.Instruction{testTransfer(payer.PublicKey())})
+	if err != nil {
+		t.Fatalf("SignBundle: %v", err)
+	}
+	if len(txs) != 1 {
+		t.Errorf("SignBundle without a tip signed %d transactions, want 1", len(txs))
+	}
+
+	client.TipStrategy = &TipStrategy{Mode: TipFloorPercentile, MinTip: MinJitoTip, Percentile: 75, Source: tipFloorServer(t, testTipFloor)}
+	txs, err = client.SignBundle(ctx, signers, 0, false, []solana.Instruction{testTransfer(payer.PublicKey())})
+	if err != nil {
+		t.Fatalf("SignBundle: %v", err)
+	}
+	if len(txs) != 2 {
+		t.Fatalf("SignBundle signed %d transactions, want 2", len(txs))
+	}
+	if tip := tipLamports(t, txs[1]); tip != 50_000 {
+		t.Errorf("SignBundle tipped %d, want the 75th percentile 50000", tip)
+	}
+
+	// A given tip overrides the strategy
+	txs, err = client.SignBundle(ctx, signers, 7000, true, []solana.Instruction{testTransfer(payer.PublicKey())})
+	if err != nil {
+		t.Fatalf("SignBundle: %v", err)
+	}
+	if tip := tipLamports(t, txs[0]); tip != 7000 {
+		t.Errorf("SignBundle tipped %d, want 7000", tip)
+	}
+
+	if _, err := client.SendTxWithJito(ctx, 0, signers, txs[0]); err != nil {
+		t.Fatalf("SendTxWithJito: %v", err)
+	}
+	var params []json.RawMessage
+	var sent []string
+	if err := json.Unmarshal(jito.lastParams("sendBundle"), &params); err != nil || len(params) == 0 {
+		t.Fatalf("invalid sendBundle params %s: %v", jito.lastParams("sendBundle"), err)
+	}
+	if err := json.Unmarshal(params[0], &sent); err != nil || len(sent) != 2 {
+		t.Fatalf("sendBundle sent %s, want 2 transactions", params[0])
+	}
+	raw, err := base64.StdEncoding.DecodeString(sent[1])
+	if err != nil {
+		t.Fatalf("invalid tip transaction: %v", err)
+	}
+	tipTx, err := solana.TransactionFromBytes(raw)
+	if err != nil {
+		t.Fatalf("invalid tip transaction: %v", err)
+	}
+	if tip := tipLamports(t, tipTx); tip != 50_000 {
+		t.Errorf("SendTxWithJito tipped %d, want the 75th percentile 50000", tip)
+	}
+
+	// A strategy that cannot size the tip fails the bundle
+	client.TipStrategy.Source = tipFloorServer(t, "")
+	if _, err := client.SignBundle(ctx, signers, 0, false, []solana.Instruction{testTransfer(payer.PublicKey())}); err == nil {
+		t.Errorf("SignBundle succeeded without a tip floor")
+	}
+	if n := jito.callCount("sendBundle"); n != 1 {
+		t.Errorf("sendBundle called %d times, want 1", n)
+	}
+}
@@ -2,10 +2,11 @@ package sol
 
 import (
 	"context"
+	"encoding/binary"
+	"fmt"
 	"log"
 
 	"github.com/gagliardetto/solana-go"
-	associatedtokenaccount "github.com/gagliardetto/solana-go/programs/associated-token-account"
 	"github.com/gagliardetto/solana-go/rpc"
 )
 
@@ -25,18 +26,19 @@ func (t *Client) SelectOrCreateSPLTokenAccount(ctx context.Context, privateKey s
 		return acc.Value[0].Pubkey, nil
 	}
 
-	// Find ATA address (this will always return a valid PDA)
-	ataAddress, _, err := solana.FindAssociatedTokenAddress(user, tokenMint)
+	// The ATA is derived under the token program owning the mint, which may be Token-2022
+	programs, err := t.GetMintTokenPrograms(ctx, tokenMint)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	tokenProgram := programs[tokenMint]
+	ataAddress, err := FindAssociatedTokenAddress(user, tokenMint, tokenProgram)
 	if err != nil {
 		log.Printf("FindAssociatedTokenAddress err: %v", err)
 		return solana.PublicKey{}, err
 	}
 	instructions := make([]solana.Instruction, 0)
-	createAtaInst, err := associatedtokenaccount.NewCreateInstruction(
-		user,
-		user,
-		tokenMint,
-	).ValidateAndBuild()
+	createAtaInst, err := NewCreateAssociatedTokenAccountInstruction(user, user, tokenMint, tokenProgram)
 	if err != nil {
 		return solana.PublicKey{}, err
 	}
@@ -59,3 +61,14 @@ func (t *Client) SelectOrCreateSPLTokenAccount(ctx context.Context, privateKey s
 		return ataAddress, nil
 	}
 }
+
+// tokenAccountAmountOffset is the offset of the amount in SPL token account data, after the mint and owner
+const tokenAccountAmountOffset = 64
+
+// ParseTokenAccountAmount returns the amount held by an SPL token or Token-2022 account
+func ParseTokenAccountAmount(data []byte) (uint64, error) {
+	if len(data) < tokenAccountAmountOffset+8 {
+		return 0, fmt.Errorf("token account data too short: %d bytes", len(data))
+	}
+	return binary.LittleEndian.Uint64(data[tokenAccountAmountOffset : tokenAccountAmountOffset+8]), nil
+}
@@ -0,0 +1,83 @@
+package sol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// ErrUnsupportedTokenProgram is returned for a mint owned by neither the SPL Token nor the
+// Token-2022 program, or by a protocol that cannot swap mints of the mint's token program
+var ErrUnsupportedTokenProgram = errors.New("unsupported token program")
+
+// IsTokenProgram reports whether program is the SPL Token or the Token-2022 program
+func IsTokenProgram(program solana.PublicKey) bool {
+	return program == solana.TokenProgramID || program == solana.Token2022ProgramID
+}
+
+// FindAssociatedTokenAddress derives the associated token account of owner for a mint of tokenProgram
+func FindAssociatedTokenAddress(owner, mint, tokenProgram solana.PublicKey) (solana.PublicKey, error) {
+	ata, _, err := solana.FindProgramAddress(
+		[][]byte{owner[:], tokenProgram[:], mint[:]},
+		solana.SPLAssociatedTokenAccountProgramID,
+	)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("failed to find associated token address: %w", err)
+	}
+	return ata, nil
+}
+
+// NewCreateAssociatedTokenAccountInstruction creates the associated token account of owner for a mint
+// of tokenProgram, paid by payer. It is idempotent: it succeeds when the account already exists.
+func NewCreateAssociatedTokenAccountInstruction(payer, owner, mint, tokenProgram solana.PublicKey) (solana.Instruction, error) {
+	ata, err := FindAssociatedTokenAddress(owner, mint, tokenProgram)
+	if err != nil {
+		return nil, err
+	}
+	accounts := solana.AccountMetaSlice{
+		solana.NewAccountMeta(payer, true, true),
+		solana.NewAccountMeta(ata, true, false),
+		solana.NewAccountMeta(owner, false, false),
+		solana.NewAccountMeta(mint, false, false),
+		solana.NewAccountMeta(solana.SystemProgramID, false, false),
+		solana.NewAccountMeta(tokenProgram, false, false),
+	}
+	// 1 is the CreateIdempotent instruction of the associated token account program
+	return solana.NewInstruction(solana.SPLAssociatedTokenAccountProgramID, accounts, []byte{1}), nil
+}
+
+// GetMintTokenPrograms returns the token program owning each mint. The owner of a mint never
+// changes, so the programs are cached and only unknown mints are fetched.
+func (c *Client) GetMintTokenPrograms(ctx context.Context, mints ...solana.PublicKey) (map[solana.PublicKey]solana.PublicKey, error) {
+	programs := make(map[solana.PublicKey]solana.PublicKey, len(mints))
+	var missing []solana.PublicKey
+	for _, mint := range mints {
+		if program, ok := c.mintPrograms.Load(mint); ok {
+			programs[mint] = program.(solana.PublicKey)
+		} else {
+			missing = append(missing, mint)
+		}
+	}
+	if len(missing) == 0 {
+		return programs, nil
+	}
+
+	accounts, err := c.GetMultipleAccountsChunked(ctx, missing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mint accounts: %w", err)
+	}
+	for _, mint := range missing {
+		account, ok := accounts[mint]
+		if !ok {
+			return nil, fmt.Errorf("mint %s not found", mint)
+		}
+		if !IsTokenProgram(account.Owner) {
+			return nil, fmt.Errorf("mint %s is owned by %s: %w", mint, account.Owner, ErrUnsupportedTokenProgram)
+		}
+		c.mintPrograms.Store(mint, account.Owner)
+		programs[mint] = account.Owner
+	}
+	return programs, nil
+}
@@ -0,0 +1,244 @@
+package sol
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	// extraAccountMetaSize is the size of one ExtraAccountMeta in the validation account
+	extraAccountMetaSize = 35
+
+	// Seed types of an ExtraAccountMeta PDA
+	seedTypeEnd             = 0
+	seedTypeLiteral         = 1
+	seedTypeInstructionData = 2
+	seedTypeAccountKey      = 3
+	seedTypeAccountData     = 4
+)
+
+// executeDiscriminator identifies the Execute instruction of the transfer hook interface and
+// the extra account metas stored for it
+var executeDiscriminator = func() [8]byte {
+	hash := sha256.Sum256([]byte("spl-transfer-hook-interface:execute"))
+	var discriminator [8]byte
+	copy(discriminator[:], hash[:8])
+	return discriminator
+}()
+
+// ExtraAccountMeta is an account a transfer hook requires besides the ones of the transfer.
+// Discriminator 0 is a fixed address, 1 a PDA of the hook program and 128+i a PDA of the program
+// at account index i; AddressConfig holds the address or the packed seeds.
+type ExtraAccountMeta struct {
+	Discriminator uint8
+	AddressConfig [32]byte
+	IsSigner      bool
+	IsWritable    bool
+}
+
+// FindExtraAccountMetasAddress derives the validation account holding the extra account metas
+// of a transfer hook program for mint
+func FindExtraAccountMetasAddress(mint, hookProgram solana.PublicKey) (solana.PublicKey, error) {
+	address, _, err := solana.FindProgramAddress([][]byte{[]byte("extra-account-metas"), mint[:]}, hookProgram)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("failed to find extra account metas address: %w", err)
+	}
+	return address, nil
+}
+
+// ParseExtraAccountMetas parses the Execute entry of a transfer hook validation account
+func ParseExtraAccountMetas(data []byte) ([]ExtraAccountMeta, error) {
+	for offset := 0; offset+12 <= len(data); {
+		discriminator := data[offset : offset+8]
+		length := int(binary.LittleEndian.Uint32(data[offset+8 : offset+12]))
+		offset += 12
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("extra account metas entry overflows the account")
+		}
+		value := data[offset : offset+length]
+		offset += length
+		if [8]byte(discriminator) != executeDiscriminator {
+			continue
+		}
+
+		if len(value) < 4 {
+			return nil, fmt.Errorf("extra account metas too short: %d bytes", len(value))
+		}
+		count := int(binary.LittleEndian.Uint32(value[0:4]))
+		if len(value) < 4+count*extraAccountMetaSize {
+			return nil, fmt.Errorf("extra account metas hold %d bytes for %d metas", len(value), count)
+		}
+		metas := make([]ExtraAccountMeta, count)
+		for i := range metas {
+			entry := value[4+i*extraAccountMetaSize:]
+			metas[i].Discriminator = entry[0]
+			copy(metas[i].AddressConfig[:], entry[1:33])
+			metas[i].IsSigner = entry[33] != 0
+			metas[i].IsWritable = entry[34] != 0
+		}
+		return metas, nil
+	}
+	return nil, fmt.Errorf("extra account metas of the execute instruction not found")
+}
+
+// ResolveTransferHookAccounts returns the accounts a transfer of amount of mint from source to
+// destination, authorized by owner, must carry for the mint's transfer hook: the extra accounts
+// in order, then the hook program and its validation account. It returns nil for mints without
+// a transfer hook or whose hook has no validation account.
+func (c *Client) ResolveTransferHookAccounts(
+	ctx context.Context,
+	mint *Mint,
+	source, destination, owner solana.PublicKey,
+	amount uint64,
+) (solana.AccountMetaSlice, error) {
+	if mint.TransferHook == nil || mint.TransferHook.ProgramID.IsZero() {
+		return nil, nil
+	}
+	hookProgram := mint.TransferHook.ProgramID
+	validation, err := FindExtraAccountMetasAddress(mint.Address, hookProgram)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := c.GetMultipleAccountsChunked(ctx, []solana.PublicKey{validation})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transfer hook validation account: %w", err)
+	}
+	account, ok := accounts[validation]
+	if !ok {
+		return nil, nil
+	}
+	metas, err := ParseExtraAccountMetas(account.Data)
+	if err != nil {
+		return nil, fmt.Errorf("transfer hook of mint %s: %w", mint.Address, err)
+	}
+
+	// Seeds index the accounts and data of the Execute instruction the token program sends
+	executeAccounts := solana.AccountMetaSlice{
+		solana.NewAccountMeta(source, false, false),
+		solana.NewAccountMeta(mint.Address, false, false),
+		solana.NewAccountMeta(destination, false, false),
+		solana.NewAccountMeta(owner, false, false),
+		solana.NewAccountMeta(validation, false, false),
+	}
+	executeData := make([]byte, 16)
+	copy(executeData[0:8], executeDiscriminator[:])
+	binary.LittleEndian.PutUint64(executeData[8:16], amount)
+
+	resolver := hookResolver{client: c, hookProgram: hookProgram, accounts: executeAccounts, data: executeData}
+	for i, meta := range metas {
+		address, err := resolver.resolve(ctx, meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve extra account %d of mint %s: %w", i, mint.Address, err)
+		}
+		// The transfer is signed by its owner only: the token program never lets a hook account sign
+		resolver.accounts = append(resolver.accounts, solana.NewAccountMeta(address, meta.IsWritable, false))
+	}
+
+	extra := resolver.accounts[len(executeAccounts):]
+	extra = append(extra, solana.NewAccountMeta(hookProgram, false, false), solana.NewAccountMeta(validation, false, false))
+	return extra, nil
+}
+
+// hookResolver resolves extra account metas against the Execute instruction built so far
+type hookResolver struct {
+	client      *Client
+	hookProgram solana.PublicKey
+	accounts    solana.AccountMetaSlice
+	data        []byte
+	// accountData caches the data of accounts read by AccountData seeds
+	accountData map[solana.PublicKey][]byte
+}
+
+// resolve returns the address of meta
+func (r *hookResolver) resolve(ctx context.Context, meta ExtraAccountMeta) (solana.PublicKey, error) {
+	switch {
+	case meta.Discriminator == 0:
+		return solana.PublicKeyFromBytes(meta.AddressConfig[:]), nil
+	case meta.Discriminator == 1:
+		return r.findPDA(ctx, meta.AddressConfig, r.hookProgram)
+	case meta.Discriminator >= 1<<7:
+		index := int(meta.Discriminator - 1<<7)
+		if index >= len(r.accounts) {
+			return solana.PublicKey{}, fmt.Errorf("program account index %d out of range", index)
+		}
+		return r.findPDA(ctx, meta.AddressConfig, r.accounts[index].PublicKey)
+	default:
+		return solana.PublicKey{}, fmt.Errorf("unsupported extra account meta discriminator %d", meta.Discriminator)
+	}
+}
+
+// findPDA unpacks the seeds of config and derives the address under program
+func (r *hookResolver) findPDA(ctx context.Context, config [32]byte, program solana.PublicKey) (solana.PublicKey, error) {
+	var seeds [][]byte
+	for offset := 0; offset < len(config) && config[offset] != seedTypeEnd; {
+		seedType := config[offset]
+		args := config[offset+1:]
+		switch seedType {
+		case seedTypeLiteral:
+			if len(args) < 1 || len(args) < 1+int(args[0]) {
+				return solana.PublicKey{}, fmt.Errorf("literal seed overflows the address config")
+			}
+			seeds = append(seeds, args[1:1+int(args[0])])
+			offset += 2 + int(args[0])
+		case seedTypeInstructionData:
+			if len(args) < 2 {
+				return solana.PublicKey{}, fmt.Errorf("instruction data seed overflows the address config")
+			}
+			start, length := int(args[0]), int(args[1])
+			if start+length > len(r.data) {
+				return solana.PublicKey{}, fmt.Errorf("instruction data seed [%d, %d) out of range", start, start+length)
+			}
+			seeds = append(seeds, r.data[start:start+length])
+			offset += 3
+		case seedTypeAccountKey:
+			if len(args) < 1 || int(args[0]) >= len(r.accounts) {
+				return solana.PublicKey{}, fmt.Errorf("account key seed out of range")
+			}
+			key := r.accounts[args[0]].PublicKey
+			seeds = append(seeds, key[:])
+			offset += 2
+		case seedTypeAccountData:
+			if len(args) < 3 || int(args[0]) >= len(r.accounts) {
+				return solana.PublicKey{}, fmt.Errorf("account data seed out of range")
+			}
+			data, err := r.getAccountData(ctx, r.accounts[args[0]].PublicKey)
+			if err != nil {
+				return solana.PublicKey{}, err
+			}
+			start, length := int(args[1]), int(args[2])
+			if start+length > len(data) {
+				return solana.PublicKey{}, fmt.Errorf("account data seed [%d, %d) out of range", start, start+length)
+			}
+			seeds = append(seeds, data[start:start+length])
+			offset += 4
+		default:
+			return solana.PublicKey{}, fmt.Errorf("unsupported seed type %d", seedType)
+		}
+	}
+	address, _, err := solana.FindProgramAddress(seeds, program)
+	return address, err
+}
+
+// getAccountData fetches the data of an account read by an AccountData seed
+func (r *hookResolver) getAccountData(ctx context.Context, account solana.PublicKey) ([]byte, error) {
+	if data, ok := r.accountData[account]; ok {
+		return data, nil
+	}
+	accounts, err := r.client.GetMultipleAccountsChunked(ctx, []solana.PublicKey{account})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account %s: %w", account, err)
+	}
+	fetched, ok := accounts[account]
+	if !ok {
+		return nil, fmt.Errorf("account %s read by a seed not found", account)
+	}
+	if r.accountData == nil {
+		r.accountData = make(map[solana.PublicKey][]byte)
+	}
+	r.accountData[account] = fetched.Data
+	return fetched.Data, nil
+}
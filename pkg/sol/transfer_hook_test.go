@@ -0,0 +1,190 @@
+package sol
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// extraAccountMetasData returns a validation account holding metas for the Execute instruction,
+// preceded by the entry of another instruction
+func extraAccountMetasData(metas ...ExtraAccountMeta) []byte {
+	other := []byte{1, 2, 3}
+	data := make([]byte, 8, 8+4+len(other))
+	data[0] = 0xff
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(other)))
+	data = append(data, other...)
+
+	data = append(data, executeDiscriminator[:]...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(4+len(metas)*extraAccountMetaSize))
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(metas)))
+	for _, meta := range metas {
+		data = append(data, meta.Discriminator)
+		data = append(data, meta.AddressConfig[:]...)
+		data = append(data, boolByte(meta.IsSigner), boolByte(meta.IsWritable))
+	}
+	return data
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// seedConfig packs seeds into an address config
+func seedConfig(seeds ...[]byte) [32]byte {
+	var config [32]byte
+	offset := 0
+	for _, seed := range seeds {
+		offset += copy(config[offset:], seed)
+	}
+	return config
+}
+
+func TestParseExtraAccountMetas(t *testing.T) {
+	fixed := solana.NewWallet().PublicKey()
+	want := []ExtraAccountMeta{
+		{Discriminator: 0, AddressConfig: fixed, IsWritable: true},
+		{Discriminator: 1, AddressConfig: seedConfig([]byte{seedTypeLiteral, 2, 'a', 'b'}), IsSigner: true},
+	}
+	metas, err := ParseExtraAccountMetas(extraAccountMetasData(want...))
+	if err != nil {
+		t.Fatalf("ParseExtraAccountMetas: %v", err)
+	}
+	if len(metas) != len(want) || metas[0] != want[0] || metas[1] != want[1] {
+		t.Errorf("ParseExtraAccountMetas = %+v, want %+v", metas, want)
+	}
+
+	valid := extraAccountMetasData(want...)
+	onlyOther := valid[:8+4+3]
+	// The Execute entry claims one more meta than it holds
+	short := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(short[8+4+3+12:], 3)
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"no execute entry", onlyOther},
+		{"overflowing entry", valid[:len(valid)-1]},
+		{"missing metas", short},
+	} {
+		if _, err := ParseExtraAccountMetas(tt.data); err == nil {
+			t.Errorf("ParseExtraAccountMetas(%s) accepted invalid data", tt.name)
+		}
+	}
+}
+
+func TestResolveTransferHookAccounts(t *testing.T) {
+	rpc := newFakeJSONRPC(t)
+	client, err := NewClient(context.Background(), rpc.URL, "", 1000)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	hookProgram := solana.NewWallet().PublicKey()
+	mint := &Mint{
+		Address:      solana.NewWallet().PublicKey(),
+		TransferHook: &TransferHook{ProgramID: hookProgram},
+	}
+	source, destination, owner := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	const amount = 123_456
+	validation, err := FindExtraAccountMetasAddress(mint.Address, hookProgram)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The source token account's owner field, read by an AccountData seed
+	sourceOwner := solana.NewWallet().PublicKey()
+	sourceData := make([]byte, tokenAccountDataSize)
+	copy(sourceData[32:64], sourceOwner[:])
+	externalProgram := solana.NewWallet().PublicKey()
+
+	metas := []ExtraAccountMeta{
+		// Account 5: a fixed address, used as the program of the last PDA
+		{Discriminator: 0, AddressConfig: externalProgram},
+		// Account 6: a literal seed
+		{Discriminator: 1, AddressConfig: seedConfig([]byte{seedTypeLiteral, 7}, []byte("counter")), IsWritable: true},
+		// Account 7: the amount from the instruction data
+		{Discriminator: 1, AddressConfig: seedConfig([]byte{seedTypeInstructionData, 8, 8})},
+		// Account 8: the owner and the fixed account from the accounts before it
+		{Discriminator: 1, AddressConfig: seedConfig([]byte{seedTypeAccountKey, 3}, []byte{seedTypeAccountKey, 5})},
+		// Account 9: the owner field of the source account's data
+		{Discriminator: 1, AddressConfig: seedConfig([]byte{seedTypeAccountData, 0, 32, 32}), IsSigner: true, IsWritable: true},
+		// Account 10: a PDA of the program at account 5 over a literal and the mint
+		{Discriminator: 128 + 5, AddressConfig: seedConfig([]byte{seedTypeLiteral, 3}, []byte("ext"), []byte{seedTypeAccountKey, 1})},
+	}
+	rpc.handle("getMultipleAccounts", multipleAccountsHandler(map[solana.PublicKey][]byte{
+		validation: extraAccountMetasData(metas...),
+		source:     sourceData,
+	}))
+
+	pda := func(program solana.PublicKey, seeds ...[]byte) solana.PublicKey {
+		address, _, err := solana.FindProgramAddress(seeds, program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return address
+	}
+	amountSeed := binary.LittleEndian.AppendUint64(nil, amount)
+	want := solana.AccountMetaSlice{
+		solana.NewAccountMeta(externalProgram, false, false),
+		solana.NewAccountMeta(pda(hookProgram, []byte("counter")), true, false),
+		solana.NewAccountMeta(pda(hookProgram, amountSeed), false, false),
+		solana.NewAccountMeta(pda(hookProgram, owner[:], externalProgram[:]), false, false),
+		// Hook accounts never sign the transfer
+		solana.NewAccountMeta(pda(hookProgram, sourceOwner[:]), true, false),
+		solana.NewAccountMeta(pda(externalProgram, []byte("ext"), mint.Address[:]), false, false),
+		solana.NewAccountMeta(hookProgram, false, false),
+		solana.NewAccountMeta(validation, false, false),
+	}
+
+	got, err := client.ResolveTransferHookAccounts(context.Background(), mint, source, destination, owner, amount)
+	if err != nil {
+		t.Fatalf("ResolveTransferHookAccounts: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ResolveTransferHookAccounts returned %d accounts, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != *want[i] {
+			t.Errorf("account %d = %+v, want %+v", i, *got[i], *want[i])
+		}
+	}
+	// The validation account and the source data are fetched once each
+	if n := rpc.callCount("getMultipleAccounts"); n != 2 {
+		t.Errorf("getMultipleAccounts called %d times, want 2", n)
+	}
+}
+
+func TestResolveTransferHookAccountsWithoutHook(t *testing.T) {
+	rpc := newFakeJSONRPC(t)
+	rpc.handle("getMultipleAccounts", multipleAccountsHandler(nil))
+	client, err := NewClient(context.Background(), rpc.URL, "", 1000)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	key := solana.NewWallet().PublicKey()
+
+	for _, tt := range []struct {
+		name  string
+		mint  *Mint
+		calls int
+	}{
+		{"no extension", &Mint{Address: key}, 0},
+		{"no hook program", &Mint{Address: key, TransferHook: &TransferHook{}}, 0},
+		{"no validation account", &Mint{Address: key, TransferHook: &TransferHook{ProgramID: solana.NewWallet().PublicKey()}}, 1},
+	} {
+		before := rpc.callCount("getMultipleAccounts")
+		accounts, err := client.ResolveTransferHookAccounts(context.Background(), tt.mint, key, key, key, 1)
+		if err != nil || accounts != nil {
+			t.Errorf("%s: ResolveTransferHookAccounts = %v, %v, want no accounts", tt.name, accounts, err)
+		}
+		if calls := rpc.callCount("getMultipleAccounts") - before; calls != tt.calls {
+			t.Errorf("%s: getMultipleAccounts called %d times, want %d", tt.name, calls, tt.calls)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package pkg
+
+import (
+	"fmt"
+
+	"cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// TransferFees tracks the Token-2022 transfer fees of a pool's two mints so quotes can deduct
+// them without network calls. Pools call Track with their mints, list DependentAccounts among
+// their own and hand updates of the mints and the clock sysvar to ApplyMint and SetEpoch.
+// A copy shares no mutable state with the original, so it can live in pool snapshots.
+type TransferFees struct {
+	mints   [2]solana.PublicKey
+	loaded  [2]bool
+	configs [2]*sol.TransferFeeConfig
+	epoch   uint64
+	// hasEpoch is set once the clock sysvar was applied
+	hasEpoch bool
+}
+
+// Track sets the mints whose transfer fees are tracked, forgetting the fees of other mints
+func (t *TransferFees) Track(mint0, mint1 solana.PublicKey) {
+	mints := [2]solana.PublicKey{mint0, mint1}
+	if t.mints == mints {
+		return
+	}
+	*t = TransferFees{mints: mints, epoch: t.epoch, hasEpoch: t.hasEpoch}
+}
+
+// DependentAccounts lists the mints not loaded yet, the mints with a transfer fee, whose schedule
+// the fee authority can change, and then the clock sysvar, whose epoch selects the schedule.
+// A mint without the TransferFeeConfig extension is dropped once loaded: it cannot gain it later.
+func (t *TransferFees) DependentAccounts() []solana.PublicKey {
+	var accounts []solana.PublicKey
+	hasFee := false
+	for i, mint := range t.mints {
+		if !t.loaded[i] || t.configs[i] != nil {
+			accounts = append(accounts, mint)
+		}
+		hasFee = hasFee || t.configs[i] != nil
+	}
+	if hasFee {
+		accounts = append(accounts, solana.SysVarClockPubkey)
+	}
+	return accounts
+}
+
+// ApplyMint applies the data of one of the tracked mints
+func (t *TransferFees) ApplyMint(account solana.PublicKey, data []byte) error {
+	for i, mint := range t.mints {
+		if account != mint {
+			continue
+		}
+		parsed, err := sol.ParseMint(account, data)
+		if err != nil {
+			return err
+		}
+		t.configs[i] = parsed.TransferFeeConfig
+		t.loaded[i] = true
+		return nil
+	}
+	return fmt.Errorf("mint %s is not tracked", account)
+}
+
+// SetEpoch sets the current epoch, which selects the transfer fee schedule in effect
+func (t *TransferFees) SetEpoch(epoch uint64) {
+	t.epoch = epoch
+	t.hasEpoch = true
+}
+
+// Loaded reports whether both mints and, when a mint charges a transfer fee, the epoch are known
+func (t *TransferFees) Loaded() bool {
+	for i := range t.mints {
+		if !t.loaded[i] || (t.configs[i] != nil && !t.hasEpoch) {
+			return false
+		}
+	}
+	return true
+}
+
+// fee returns the transfer fee schedule of mint in effect, zero for mints without one
+func (t *TransferFees) fee(mint string) sol.TransferFee {
+	for i, m := range t.mints {
+		if t.configs[i] != nil && m.String() == mint {
+			return t.configs[i].EpochFee(t.epoch)
+		}
+	}
+	return sol.TransferFee{}
+}
+
+// otherMint returns the tracked mint that is not mint
+func (t *TransferFees) otherMint(mint string) string {
+	if t.mints[0].String() == mint {
+		return t.mints[1].String()
+	}
+	return t.mints[0].String()
+}
+
+// transferFee returns the fee withheld from a transfer of amount under fee
+func transferFee(fee sol.TransferFee, amount math.Int) (math.Int, error) {
+	if fee.TransferFeeBasisPoints == 0 {
+		return math.ZeroInt(), nil
+	}
+	if !amount.IsUint64() {
+		return math.Int{}, fmt.Errorf("transfer amount %v exceeds u64", amount)
+	}
+	return math.NewIntFromUint64(fee.Fee(amount.Uint64())), nil
+}
+
+// preFeeAmount returns the amount to transfer under fee so that postFeeAmount arrives
+func preFeeAmount(fee sol.TransferFee, postFeeAmount math.Int) (math.Int, error) {
+	if fee.TransferFeeBasisPoints == 0 {
+		return postFeeAmount, nil
+	}
+	if !postFeeAmount.IsUint64() {
+		return math.Int{}, fmt.Errorf("transfer amount %v exceeds u64", postFeeAmount)
+	}
+	amount, err := fee.PreFeeAmount(postFeeAmount.Uint64())
+	if err != nil {
+		return math.Int{}, err
+	}
+	return math.NewIntFromUint64(amount), nil
+}
+
+// QuoteExactIn wraps an exact-in quote of the pool curve: quote prices the amount that reaches
+// the pool after the input transfer fee, and the output transfer fee is taken from its result.
+// The returned AmountIn is what the user sends and AmountOut what the user receives.
+func (t *TransferFees) QuoteExactIn(inputMint string, amountIn math.Int,
+	quote func(inputMint string, amountIn math.Int) (*QuoteResult, error)) (*QuoteResult, error) {
+	feeIn, err := transferFee(t.fee(inputMint), amountIn)
+	if err != nil {
+		return nil, err
+	}
+	result, err := quote(inputMint, amountIn.Sub(feeIn))
+	if err != nil {
+		return nil, err
+	}
+	feeOut, err := transferFee(t.fee(result.OutputMint), result.AmountOut)
+	if err != nil {
+		return nil, err
+	}
+	result.AmountIn = amountIn
+	result.AmountOut = result.AmountOut.Sub(feeOut)
+	result.TransferFeeIn, result.TransferFeeOut = feeIn, feeOut
+	return result, nil
+}
+
+// QuoteExactOut wraps an exact-out quote of the pool curve: quote is asked for the output
+// before the output transfer fee, and the input is grossed up for the input transfer fee.
+// The returned AmountIn is what the user sends and AmountOut what the user receives.
+func (t *TransferFees) QuoteExactOut(inputMint string, amountOut math.Int,
+	quote func(inputMint string, amountOut math.Int) (*QuoteResult, error)) (*QuoteResult, error) {
+	grossOut, err := preFeeAmount(t.fee(t.otherMint(inputMint)), amountOut)
+	if err != nil {
+		return nil, err
+	}
+	result, err := quote(inputMint, grossOut)
+	if err != nil {
+		return nil, err
+	}
+	grossIn, err := preFeeAmount(t.fee(inputMint), result.AmountIn)
+	if err != nil {
+		return nil, err
+	}
+	result.TransferFeeIn = grossIn.Sub(result.AmountIn)
+	result.TransferFeeOut = grossOut.Sub(amountOut)
+	result.AmountIn = grossIn
+	result.AmountOut = amountOut
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"cosmossdk.io/math"
+	"github.com/gagliardetto/solana-go"
+	"github.com/solana-zh/solroute/pkg/sol"
+)
+
+// transferFeeMintData returns the data of a Token-2022 mint with the TransferFeeConfig extension
+func transferFeeMintData(older, newer sol.TransferFee) []byte {
+	data := make([]byte, 166, 166+4+108)
+	data[45] = 1  // is_initialized
+	data[165] = 1 // account type mint
+	data = binary.LittleEndian.AppendUint16(data, 1)
+	data = binary.LittleEndian.AppendUint16(data, 108)
+	data = append(data, make([]byte, 32*2+8)...)
+	for _, fee := range []sol.TransferFee{older, newer} {
+		data = binary.LittleEndian.AppendUint64(data, fee.Epoch)
+		data = binary.LittleEndian.AppendUint64(data, fee.MaximumFee)
+		data = binary.LittleEndian.AppendUint16(data, fee.TransferFeeBasisPoints)
+	}
+	return data
+}
+
+func TestTransferFeeAmounts(t *testing.T) {
+	fee := sol.TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 100}
+	for _, tt := range []struct {
+		name      string
+		fee       sol.TransferFee
+		amount    int64
+		wantFee   int64
+		wantGross int64
+	}{
+		{"no fee", sol.TransferFee{MaximumFee: 1000}, 10_000, 0, 10_000},
+		{"fee", fee, 9900, 99, 10_000},
+		{"capped", fee, 1_000_000, 1000, 1_001_000},
+		{"whole amount", sol.TransferFee{MaximumFee: 1000, TransferFeeBasisPoints: 10000}, 600, 600, 1600},
+	} {
+		got, err := transferFee(tt.fee, math.NewInt(tt.amount))
+		if err != nil || !got.Equal(math.NewInt(tt.wantFee)) {
+			t.Errorf("%s: transferFee(%d) = %v, %v, want %d", tt.name, tt.amount, got, err, tt.wantFee)
+		}
+		got, err = preFeeAmount(tt.fee, math.NewInt(tt.amount))
+		if err != nil || !got.Equal(math.NewInt(tt.wantGross)) {
+			t.Errorf("%s: preFeeAmount(%d) = %v, %v, want %d", tt.name, tt.amount, got, err, tt.wantGross)
+		}
+	}
+
+	tooLarge := math.NewIntFromUint64(1 << 63).MulRaw(2)
+	if _, err := transferFee(fee, tooLarge); err == nil {
+		t.Errorf("transferFee accepted an amount above u64")
+	}
+	if _, err := preFeeAmount(fee, tooLarge); err == nil {
+		t.Errorf("preFeeAmount accepted an amount above u64")
+	}
+}
+
+// oneToOneQuote quotes a curve that swaps one token for one of the other mint
+func oneToOneQuote(otherMint string) func(string, math.Int) (*QuoteResult, error) {
+	return func(inputMint string, amount math.Int) (*QuoteResult, error) {
+		return &QuoteResult{InputMint: inputMint, OutputMint: otherMint, AmountIn: amount, AmountOut: amount}, nil
+	}
+}
+
+func TestTransferFeesQuotes(t *testing.T) {
+	feeMint, plainMint := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	older := sol.TransferFee{Epoch: 0, MaximumFee: 1_000_000, TransferFeeBasisPoints: 100}
+	newer := sol.TransferFee{Epoch: 10, MaximumFee: 500, TransferFeeBasisPoints: 200}
+
+	var fees TransferFees
+	fees.Track(feeMint, plainMint)
+	if err := fees.ApplyMint(feeMint, transferFeeMintData(older, newer)); err != nil {
+		t.Fatalf("ApplyMint(fee mint): %v", err)
+	}
+	plain := make([]byte, sol.MintAccountDataSize)
+	if err := fees.ApplyMint(plainMint, plain); err != nil {
+		t.Fatalf("ApplyMint(plain mint): %v", err)
+	}
+	if fees.Loaded() {
+		t.Errorf("Loaded before the epoch of a mint with a transfer fee is known")
+	}
+
+	for _, tt := range []struct {
+		name       string
+		epoch      uint64
+		inputMint  solana.PublicKey
+		amount     int64
+		wantFeeIn  int64
+		wantFeeOut int64
+		wantOut    int64
+	}{
+		// Selling the fee mint: the fee is withheld from the input
+		{"older fee in", 9, feeMint, 10_000, 100, 0, 9900},
+		{"newer fee in", 10, feeMint, 10_000, 200, 0, 9800},
+		{"newer fee in capped", 10, feeMint, 100_000, 500, 0, 99_500},
+		// Buying the fee mint: the fee is withheld from the output
+		{"older fee out", 9, plainMint, 10_000, 0, 100, 9900},
+		{"newer fee out", 10, plainMint, 10_000, 0, 200, 9800},
+	} {
+		fees.SetEpoch(tt.epoch)
+		if !fees.Loaded() {
+			t.Fatalf("%s: not Loaded with both mints and the epoch", tt.name)
+		}
+		otherMint := fees.otherMint(tt.inputMint.String())
+		amount := math.NewInt(tt.amount)
+
+		result, err := fees.QuoteExactIn(tt.inputMint.String(), amount, oneToOneQuote(otherMint))
+		if err != nil {
+			t.Fatalf("%s: QuoteExactIn: %v", tt.name, err)
+		}
+		if !result.AmountIn.Equal(amount) || !result.AmountOut.Equal(math.NewInt(tt.wantOut)) ||
+			!result.TransferFeeIn.Equal(math.NewInt(tt.wantFeeIn)) || !result.TransferFeeOut.Equal(math.NewInt(tt.wantFeeOut)) {
+			t.Errorf("%s: QuoteExactIn(%d) = in %v out %v fees %v/%v, want in %d out %d fees %d/%d", tt.name, tt.amount,
+				result.AmountIn, result.AmountOut, result.TransferFeeIn, result.TransferFeeOut, tt.amount, tt.wantOut, tt.wantFeeIn, tt.wantFeeOut)
+		}
+
+		// Asking for the exact-in output back needs the same input
+		result, err = fees.QuoteExactOut(tt.inputMint.String(), math.NewInt(tt.wantOut), oneToOneQuote(otherMint))
+		if err != nil {
+			t.Fatalf("%s: QuoteExactOut: %v", tt.name, err)
+		}
+		if !result.AmountIn.Equal(amount) || !result.AmountOut.Equal(math.NewInt(tt.wantOut)) ||
+			!result.TransferFeeIn.Equal(math.NewInt(tt.wantFeeIn)) || !result.TransferFeeOut.Equal(math.NewInt(tt.wantFeeOut)) {
+			t.Errorf("%s: QuoteExactOut(%d) = in %v out %v fees %v/%v, want in %d out %d fees %d/%d", tt.name, tt.wantOut,
+				result.AmountIn, result.AmountOut, result.TransferFeeIn, result.TransferFeeOut, tt.amount, tt.wantOut, tt.wantFeeIn, tt.wantFeeOut)
+		}
+	}
+
+	if got, want := fees.DependentAccounts(), []solana.PublicKey{feeMint, solana.SysVarClockPubkey}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("DependentAccounts = %v, want the fee mint and the clock %v", got, want)
+	}
+}